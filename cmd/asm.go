@@ -0,0 +1,50 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// asmCommand assembles a source file into a ROM file.
+func asmCommand(arguments []string) int {
+	flags := newFlagSet("asm", "<source file>")
+	machineName := addMachineFlag(flags) // The machine sets the load address of the ROM that labels are relative to
+	outputFilepath := flags.String("o", "", "The file path of the ROM file. Default value \"\" (the source file path with extension \".ch8\").")
+
+	sourceFilepath, ok := parseROMFlags(flags, arguments)
+	if !ok {
+		return 1
+	}
+
+	machine, err := romMachine(*machineName, nil) // A source file is not in the ROM database
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	source, err := os.ReadFile(sourceFilepath)
+	if err != nil {
+		fmt.Printf("could not read source file \"%s\": %s\n", sourceFilepath, err.Error())
+		return 1
+	}
+
+	rom, err := chip8.Assemble(string(source), machine.LoadAddress)
+	if err != nil {
+		fmt.Printf("%s:%s\n", sourceFilepath, strings.TrimPrefix(err.Error(), "line "))
+		return 1
+	}
+
+	if *outputFilepath == "" {
+		*outputFilepath = strings.TrimSuffix(sourceFilepath, filepath.Ext(sourceFilepath)) + ".ch8"
+	}
+	if err := os.WriteFile(*outputFilepath, rom, 0o644); err != nil {
+		fmt.Printf("could not write ROM file \"%s\": %s\n", *outputFilepath, err.Error())
+		return 1
+	}
+
+	fmt.Printf("Wrote ROM file \"%s\" of %d bytes\n", *outputFilepath, len(rom))
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"chip8/pkg/chip8"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// startDebugCommands reads debugger commands, one per line, from the input while the program runs.
+// The commands are carried out at the end of the frame they are read in:
+//
+//	screenshot [file]  writes the screen as a PNG image, by default to the screenshot file path numbered with the frame
+//	quit               stops running the program
+func startDebugCommands(input io.Reader, machine *chip8.Chip8, peripherals *chip8.Peripherals, configuration chip8.Configuration) {
+	commands := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			commands <- scanner.Text()
+		}
+	}()
+
+	machine.AddFrameListener(func(frame uint64, screen *chip8.ScreenBuffer) {
+		for {
+			select {
+			case command := <-commands:
+				runDebugCommand(command, frame, screen, peripherals, configuration)
+			default:
+				return
+			}
+		}
+	})
+}
+
+func runDebugCommand(command string, frame uint64, screen *chip8.ScreenBuffer, peripherals *chip8.Peripherals, configuration chip8.Configuration) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
+	case "screenshot", "s":
+		screenshotFilepath := fmt.Sprintf("%s-%d.png", strings.TrimSuffix(configuration.ScreenshotFilepath, ".png"), frame)
+		if len(fields) > 1 {
+			screenshotFilepath = fields[1]
+		}
+		if err := screen.WritePNG(screenshotFilepath, configuration.ScreenshotScale, configuration.Palette); err != nil {
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Wrote screenshot \"%s\" at frame %d\n", screenshotFilepath, frame)
+		}
+	case "quit", "q":
+		peripherals.RequestQuit()
+	default:
+		fmt.Printf("Unknown debugger command \"%s\", expected \"screenshot [file]\" or \"quit\"\n", fields[0])
+	}
+}
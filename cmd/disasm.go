@@ -0,0 +1,66 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"fmt"
+)
+
+// disasmCommand prints the disassembly of the ROM.
+func disasmCommand(arguments []string) int {
+	flags := newFlagSet("disasm", "<ROM file>")
+	machineFlags := addMachineFlags(flags)
+	everyByte := flags.Bool("every-byte", false, "Disassemble instructions at every byte, not just at even addresses. Some programs have code at odd addresses. Default value false.")
+	modeRomCompatibility := flags.Bool("mode-rom-compatibility", true, "Explain BNNN as jumping with offset V0. Default value true.")
+	modeStrictCosmac := flags.Bool("mode-strict-cosmac", false, "Explain BNNN as jumping with offset V0, as on the COSMAC VIP. Default value false.")
+	frames := flags.Uint64("frames", 0, "Run the ROM headless for the number of frames first, and mark the instructions modified at runtime (self-modifying code). Default value 0 (no run).")
+
+	romFilepath, ok := parseROMFlags(flags, arguments)
+	if !ok {
+		return 1
+	}
+
+	machine, romInfo, err := machineFlags.machine(romFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	configuration := chip8.Configuration{
+		Disassemble:          true,
+		DisassembleEveryByte: *everyByte,
+		ModeRomCompatibility: *modeRomCompatibility,
+		ModeStrictCosmac:     *modeStrictCosmac,
+	}
+
+	var modifiedAddresses map[uint16]bool
+	if *frames > 0 {
+		modifiedAddresses = runForModifiedAddresses(romFilepath, machine, romInfo, !machineFlags.machineChosen(), *frames)
+	}
+
+	fmt.Printf("CHIP-8 disassembly of \"%s\":\n", romFilepath)
+	chip8.DisassembleProgram(romFilepath, machine.LoadAddress, configuration, modifiedAddresses)
+
+	return 0
+}
+
+// runForModifiedAddresses runs the ROM headless for the number of frames, or until an infinite loop,
+// and finds the memory addresses executed as instructions and written at runtime.
+func runForModifiedAddresses(romFilepath string, machine chip8.Machine, romInfo *chip8.ROMInfo, applyROMInfo bool, frames uint64) map[uint16]bool {
+	configuration := chip8.Configuration{ModeRomCompatibility: true, EndOnInfiniteLoop: true, Frames: frames, Headless: true}
+	machine.ApplyQuirks(&configuration)
+	if (romInfo != nil) && applyROMInfo {
+		romInfo.Apply(&configuration)
+	}
+
+	peripherals := chip8.NewHeadlessPeripherals()
+	emulator := chip8.NewChip8ForMachine(&peripherals, machine)
+	emulator.LoadROM(romFilepath)
+	emulator.Run(configuration)
+
+	modifiedAddresses := map[uint16]bool{}
+	for _, address := range emulator.ModifiedAddresses() {
+		modifiedAddresses[address] = true
+	}
+	fmt.Printf("Ran %d instructions, %d bytes of code modified at runtime\n", emulator.Cycles, len(modifiedAddresses))
+	return modifiedAddresses
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// machineFlags are the flags selecting the machine of the ROM: the config file, the machine and the ROM database.
+type machineFlags struct {
+	flags               *flag.FlagSet
+	configFilepath      *string
+	machineName         *string
+	romDatabaseFilepath *string
+}
+
+// addMachineFlags defines the flags selecting the machine of the ROM, the same in every command taking a ROM.
+func addMachineFlags(flags *flag.FlagSet) *machineFlags {
+	return &machineFlags{
+		flags:               flags,
+		configFilepath:      flags.String("config", "", "The file path of a JSON config file with default settings and per-ROM settings, named as the flags. Default value \"\" (\"chip8.json\" in the working directory, or else \"chip8/config.json\" in the user config directory, if existing)."),
+		machineName:         addMachineFlag(flags),
+		romDatabaseFilepath: flags.String("romdb", "", "The file path of a ROM database in the programs.json format of the CHIP-8 database (https://github.com/chip-8/chip-8-database). Default value \"\" (the built-in ROM database)."),
+	}
+}
+
+// addMachineFlag defines the flag of the machine, resolved by romMachine.
+func addMachineFlag(flags *flag.FlagSet) *string {
+	return flags.String("machine", "", "The machine, setting the memory layout (load address, memory size, font location, stack depth) and quirks: \"cosmac-vip\", \"eti-660\", \"schip\" or \"xo-chip\". Default value \"\" (the platform of the ROM in the ROM database, or else \"cosmac-vip\").")
+}
+
+// machine sets the flags not set on the command line from the config file, and resolves the machine of the ROM (see romMachine).
+// The ROM info is nil for unknown ROMs.
+func (m *machineFlags) machine(romFilepath string) (chip8.Machine, *chip8.ROMInfo, error) {
+	if err := applyConfigFile(m.flags, *m.configFilepath, romFilepath); err != nil {
+		return chip8.Machine{}, nil, err
+	}
+
+	romInfo, err := lookupROM(*m.romDatabaseFilepath, romFilepath)
+	if err != nil {
+		return chip8.Machine{}, nil, err
+	}
+
+	machine, err := romMachine(*m.machineName, romInfo)
+	if err != nil {
+		return chip8.Machine{}, nil, err
+	}
+
+	return machine, romInfo, nil
+}
+
+// machineChosen is true if the machine is set in the config file or on the command line, rather than by the ROM database.
+func (m *machineFlags) machineChosen() bool {
+	return *m.machineName != ""
+}
+
+// configurationFlags are the flags of the execution configuration, and the machine and ROM database setting its defaults.
+type configurationFlags struct {
+	*machineFlags
+
+	debug                 *bool
+	modeRomCompatibility  *bool
+	modeStrictCosmac      *bool
+	endOnInfiniteLoop     *bool
+	restartOnInfiniteLoop *bool
+
+	keyWaitRelease    *bool
+	displayWait       *bool
+	wrapSprites       *bool
+	collisionRowCount *bool
+
+	cyclesPerFrame *int
+	frames         *uint64
+	headless       *bool
+	seed           *int64
+
+	screenshotAfter    *uint64
+	screenshotFilepath *string
+	screenshotScale    *int
+	paletteText        *string
+}
+
+// addConfigurationFlags defines the flags of every field of the execution configuration.
+func addConfigurationFlags(flags *flag.FlagSet) *configurationFlags {
+	return &configurationFlags{
+		machineFlags: addMachineFlags(flags),
+
+		debug:                 flags.Bool("debug", false, "Print every executed instruction. Default value false."),
+		modeRomCompatibility:  flags.Bool("mode-rom-compatibility", true, "Execute BNNN with V0 and FX55/FX65 without incrementing I, as most ROMs expect. Default value true, or set by the ROM database."),
+		modeStrictCosmac:      flags.Bool("mode-strict-cosmac", false, "Execute instructions strictly as the COSMAC VIP: 8XY6/8XYE shift VY, FX1E does not set VF. Default value false, or set by the ROM database."),
+		endOnInfiniteLoop:     flags.Bool("end-on-infinite-loop", true, "End the execution when the program jumps to the jump instruction itself. Default value true."),
+		restartOnInfiniteLoop: flags.Bool("restart-on-infinite-loop", false, "Restart the program when it jumps to the jump instruction itself, instead of ending the execution. Default value false."),
+
+		keyWaitRelease:    flags.Bool("quirk-key-wait-release", true, "Make FX0A (wait for key) wait for the key to be pressed and released again, as on the COSMAC VIP. Default value set by the machine."),
+		displayWait:       flags.Bool("quirk-display-wait", true, "Make DXYN (draw sprite) wait for the vertical blank, limiting sprite draws to 60 per second, as on the COSMAC VIP. Many original games depend on it for their speed. Default value set by the machine."),
+		wrapSprites:       flags.Bool("quirk-wrap-sprites", false, "Make sprite pixels beyond the right or bottom screen edge wrap around to the opposite edge, instead of being clipped as on the COSMAC VIP. Default value set by the machine."),
+		collisionRowCount: flags.Bool("quirk-collision-row-count", false, "Make DXYN set VF to the number of sprite rows colliding or clipped at the bottom edge, as on SCHIP. Default value set by the machine."),
+
+		cyclesPerFrame: flags.Int("cycles-per-frame", 6, "The number of instructions executed every 60 Hz frame. Default value 6, or set by the ROM database."),
+		frames:         flags.Uint64("frames", 0, "End the execution after the given number of 60 Hz frames. Default value 0 (no limit)."),
+		headless:       flags.Bool("headless", false, "Run as fast as possible without screen application, for deterministic recordings and batch execution. Default value false."),
+		seed:           flags.Int64("seed", 0, "The seed of the random numbers (CXNN) when running headless or recording, the same seed giving the same execution. Default value 0."),
+
+		screenshotAfter:    flags.Uint64("screenshot-after", 0, "Write a PNG screenshot of the screen after the given number of executed instructions. Default value 0 (no screenshot)."),
+		screenshotFilepath: flags.String("screenshot", "screenshot.png", "The file path of the PNG screenshot. Default value \"screenshot.png\"."),
+		screenshotScale:    flags.Int("screenshot-scale", 8, "The size in image pixels of every screen pixel in screenshots and recordings. Default value 8."),
+		paletteText:        flags.String("palette", "000000,FFFFFF", "Screenshot and recording colors as hexadecimal RGB, background color first. Default value \"000000,FFFFFF\", or set by the ROM database."),
+	}
+}
+
+// configuration is the execution configuration of the ROM: the defaults, then the quirks of the machine,
+// then the quirks, speed and colors of the ROM in the ROM database (unless the machine is chosen), then the flags set
+// in the config file or on the command line. The machine is the chosen machine, or else the machine of the ROM platform.
+// The ROM info is nil for unknown ROMs.
+func (c *configurationFlags) configuration(romFilepath string) (chip8.Configuration, chip8.Machine, *chip8.ROMInfo, error) {
+	machine, romInfo, err := c.machine(romFilepath)
+	if err != nil {
+		return chip8.Configuration{}, chip8.Machine{}, nil, err
+	}
+
+	palette, err := chip8.ParsePalette(*c.paletteText)
+	if err != nil {
+		return chip8.Configuration{}, chip8.Machine{}, nil, err
+	}
+
+	configuration := chip8.Configuration{
+		Debug:                 *c.debug,
+		ModeRomCompatibility:  true,
+		ModeStrictCosmac:      false,
+		EndOnInfiniteLoop:     *c.endOnInfiniteLoop,
+		RestartOnInfiniteLoop: *c.restartOnInfiniteLoop,
+		CyclesPerFrame:        *c.cyclesPerFrame,
+		Frames:                *c.frames,
+		Headless:              *c.headless,
+		Seed:                  *c.seed,
+		ScreenshotAfter:       *c.screenshotAfter,
+		ScreenshotFilepath:    *c.screenshotFilepath,
+		ScreenshotScale:       *c.screenshotScale,
+		Palette:               palette,
+	}
+
+	machine.ApplyQuirks(&configuration)
+	if (romInfo != nil) && !c.machineChosen() {
+		romInfo.Apply(&configuration)
+	}
+
+	c.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mode-rom-compatibility":
+			configuration.ModeRomCompatibility = *c.modeRomCompatibility
+		case "mode-strict-cosmac":
+			configuration.ModeStrictCosmac = *c.modeStrictCosmac
+		case "quirk-key-wait-release":
+			configuration.QuirkKeyWaitRelease = *c.keyWaitRelease
+		case "quirk-display-wait":
+			configuration.QuirkDisplayWait = *c.displayWait
+		case "quirk-wrap-sprites":
+			configuration.QuirkWrapSprites = *c.wrapSprites
+		case "quirk-collision-row-count":
+			configuration.QuirkCollisionRowCount = *c.collisionRowCount
+		case "cycles-per-frame":
+			configuration.CyclesPerFrame = *c.cyclesPerFrame
+		case "palette":
+			configuration.Palette = palette
+		}
+	})
+
+	return configuration, machine, romInfo, nil
+}
+
+// applyConfigFile sets the flags not set on the command line to the settings of the ROM in the config file.
+// Settings of flags the command does not have are skipped, settings no command has are errors.
+func applyConfigFile(flags *flag.FlagSet, configFilepath string, romFilepath string) error {
+	if configFilepath == "" {
+		configFilepath = defaultConfigFilepath()
+		if configFilepath == "" {
+			return nil
+		}
+	}
+
+	configFile, err := chip8.LoadConfigFile(configFilepath)
+	if err != nil {
+		return err
+	}
+
+	romBytes, err := os.ReadFile(romFilepath)
+	if err != nil {
+		return fmt.Errorf("could not read ROM file \"%s\": %w", romFilepath, err)
+	}
+
+	flagsSet := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { flagsSet[f.Name] = true })
+
+	settingFlags := flag.NewFlagSet("settings", flag.ContinueOnError)
+	addConfigurationFlags(settingFlags)
+	addTransportFlags(settingFlags)
+
+	for name, value := range configFile.Settings(romFilepath, romBytes) {
+		if (name == "config") || (settingFlags.Lookup(name) == nil) {
+			return fmt.Errorf("unknown setting \"%s\" in config file \"%s\"", name, configFilepath)
+		}
+		if (flags.Lookup(name) == nil) || flagsSet[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("illegal setting \"%s\" in config file \"%s\": %w", name, configFilepath, err)
+		}
+	}
+
+	return nil
+}
+
+// defaultConfigFilepath is "chip8.json" in the working directory, or else "chip8/config.json" in the user config directory,
+// or "" if neither exists.
+func defaultConfigFilepath() string {
+	candidates := []string{"chip8.json"}
+	if userConfigDirectory, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(userConfigDirectory, "chip8", "config.json"))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// transportFlags are the flags of the display and the frontends serving remote viewers.
+type transportFlags struct {
+	display            *string
+	httpAddress        *string
+	listenAddress      *string
+	screenAddress      *string
+	listenKeyStatePort *int
+	keymapName         *string
+	ttyKeyHold         *time.Duration
+	compression        *bool
+}
+
+// addTransportFlags defines the flags of the display, remote viewers and keymap.
+func addTransportFlags(flags *flag.FlagSet) *transportFlags {
+	return &transportFlags{
+		display:            flags.String("display", "", "The display of screen and source of key input. \"udp\" for the external screen application, \"tty\" for the terminal, \"none\" for no display. Default value \"\" (\"udp\", or \"none\" if serving viewers with -http or -listen)."),
+		httpAddress:        flags.String("http", "", "Serve a browser frontend over HTTP on the given address, to any number of browsers. Format: \":8080\". Default value \"\" (no browser frontend)."),
+		listenAddress:      flags.String("listen", "", "Listen for any number of screen applications connecting to the interpreter. Format: \"tcp://:9000\" or \"unix:///tmp/chip8.sock\". Default value \"\" (no listening)."),
+		screenAddress:      flags.String("screenAddress", "localhost:9999", "The socket address of the screen application. Format: \"127.0.0.1:9999\" (UDP), \"udp://127.0.0.1:9999\", \"tcp://127.0.0.1:9999\" or \"unix:///tmp/chip8.sock\". Default value: \"127.0.0.1:9999\"."),
+		listenKeyStatePort: flags.Int("keystatePort", 9998, "The port where to listen for key press state changes (UDP only, TCP and Unix domain sockets use the screen connection). Format: \"9998\". Default value \"9998\"."),
+		keymapName:         flags.String("keymap", "", "The mapping of keyboard keys to hex keys for the terminal and browser frontends, \"cosmac\" (1234/QWER/ASDF/ZXCV), \"arrows\" (also arrow keys as 2/4/6/8) or a keymap file. Default value \"\" (a keymap file next to the ROM file, like \"roms/TETRIS.keymap\", or the keymap of the ROM database, or else \"cosmac\")."),
+		ttyKeyHold:         flags.Duration("tty-key-hold", 600*time.Millisecond, "How long a key stays pressed after a key stroke in the terminal, which reports key strokes (repeated while held) but no releases. Longer than the key repeat delay of the terminal, so held keys stay pressed. Format: \"600ms\". Default value \"600ms\"."),
+		compression:        flags.Bool("compression", true, "Compress screen updates to screen applications and browsers supporting it. Default value true."),
+	}
+}
+
+// displayName is the display, "udp" by default or "none" when only serving remote viewers.
+func (t *transportFlags) displayName() string {
+	if *t.display != "" {
+		return *t.display
+	}
+	if (*t.httpAddress != "") || (*t.listenAddress != "") {
+		return "none"
+	}
+	return "udp"
+}
+
+// peripherals creates the peripherals, headless or presented by the display and remote viewer frontends, with the keymap of the ROM.
+func (t *transportFlags) peripherals(headless bool, romFilepath string, romInfo *chip8.ROMInfo) (*chip8.Peripherals, error) {
+	keymap, err := selectKeymap(*t.keymapName, romFilepath, romInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var peripherals chip8.Peripherals
+	if headless {
+		peripherals = chip8.NewHeadlessPeripherals()
+	} else {
+		frontends, err := createFrontends(t.displayName(), *t.screenAddress, *t.listenKeyStatePort, *t.httpAddress, *t.listenAddress, *t.ttyKeyHold, *t.compression)
+		if err != nil {
+			return nil, err
+		}
+		peripherals = chip8.NewPeripheralsWithFrontend(frontends...)
+	}
+	peripherals.SetKeymap(keymap)
+
+	return &peripherals, nil
+}
+
+// createFrontends creates the display frontend, and the frontends serving any number of remote viewers.
+func createFrontends(display string, screenAddress string, listenKeyStatePort int, httpAddress string, listenAddress string, ttyKeyHold time.Duration, compression bool) ([]chip8.Frontend, error) {
+	var frontends []chip8.Frontend
+
+	switch display {
+	case "none":
+	case "tty":
+		frontends = append(frontends, chip8.NewTTYFrontend(ttyKeyHold))
+	case "udp":
+		frontend, err := chip8.NewFrontendForAddress(screenAddress, listenKeyStatePort, compression)
+		if err != nil {
+			return nil, err
+		}
+		frontends = append(frontends, frontend)
+	default:
+		return nil, fmt.Errorf("unknown display \"%s\" (expected \"udp\", \"tty\" or \"none\")", display)
+	}
+
+	if httpAddress != "" {
+		fmt.Printf("Browser frontend served at:              http://%s/\n", httpAddress)
+		frontends = append(frontends, chip8.NewWebFrontend(httpAddress, compression))
+	}
+
+	if listenAddress != "" {
+		frontend, err := chip8.NewStreamListenerForAddress(listenAddress, compression)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Listening for screen applications on:    %s\n", listenAddress)
+		frontends = append(frontends, frontend)
+	}
+
+	return frontends, nil
+}
+
+// traceFlags are the flags of the execution trace.
+type traceFlags struct {
+	traceFilepath    *string
+	format           *string
+	addressRangeText *string
+	opcodesText      *string
+}
+
+// addTraceFlags defines the flags of the execution trace file, format and filters.
+func addTraceFlags(flags *flag.FlagSet) *traceFlags {
+	return &traceFlags{
+		traceFilepath:    flags.String("trace", "", "Write a trace record of every executed instruction (cycle, frame, PC, opcode, mnemonic, registers before and after, I, memory writes, key state) to the file. Default value \"\" (no trace)."),
+		format:           flags.String("trace-format", "", "The trace format, \"jsonl\" (one JSON object per line) or \"binary\" (compact big endian records). Default value \"\" (\"binary\" for \"*.bin\" files, otherwise \"jsonl\")."),
+		addressRangeText: flags.String("trace-range", "", "Trace only instructions in the address range. Format: \"0x200-0x2FF\". Default value \"\" (all addresses)."),
+		opcodesText:      flags.String("trace-opcodes", "", "Trace only the instruction types. Format: \"DXYN,FX33\". Default value \"\" (all instructions)."),
+	}
+}
+
+// tracer creates the tracer writing the trace file, nil if not tracing.
+func (t *traceFlags) tracer() (*chip8.Tracer, error) {
+	if *t.traceFilepath == "" {
+		return nil, nil
+	}
+
+	format := chip8.TraceFormatForFilepath(*t.traceFilepath)
+	if *t.format != "" {
+		var err error
+		if format, err = chip8.ParseTraceFormat(*t.format); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := chip8.ParseTraceFilter(*t.addressRangeText, *t.opcodesText)
+	if err != nil {
+		return nil, err
+	}
+
+	return chip8.CreateTracer(*t.traceFilepath, format, filter)
+}
+
+// profileFlags are the flags of the execution profile.
+type profileFlags struct {
+	reportFilepath *string
+	pprofFilepath  *string
+}
+
+// addProfileFlags defines the flags of the profile report and pprof profile files.
+func addProfileFlags(flags *flag.FlagSet) *profileFlags {
+	return &profileFlags{
+		reportFilepath: flags.String("profile", "", "Write a profile report of the executed instructions (waiting for keys and the delay timer, subroutines, call graph and annotated disassembly) to the file. Default value \"\" (no profile report)."),
+		pprofFilepath:  flags.String("pprof", "", "Write a pprof profile of the executed instructions to the file, for \"go tool pprof\". Default value \"\" (no pprof profile)."),
+	}
+}
+
+// profiler creates the profiler, nil if not profiling.
+func (p *profileFlags) profiler() *chip8.Profiler {
+	if (*p.reportFilepath == "") && (*p.pprofFilepath == "") {
+		return nil
+	}
+	return chip8.NewProfiler()
+}
+
+// write writes the profile report and pprof profile of the profiler.
+func (p *profileFlags) write(profiler *chip8.Profiler, romFilepath string) {
+	if *p.reportFilepath != "" {
+		if err := profiler.WriteReport(*p.reportFilepath); err != nil {
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Wrote profile report \"%s\"\n", *p.reportFilepath)
+		}
+	}
+
+	if *p.pprofFilepath != "" {
+		if err := profiler.WritePprof(*p.pprofFilepath, romFilepath); err != nil {
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Wrote pprof profile \"%s\"\n", *p.pprofFilepath)
+		}
+	}
+}
+
+// coverageFlags are the flags of the code coverage report.
+type coverageFlags struct {
+	reportFilepath *string
+}
+
+// addCoverageFlags defines the flag of the coverage report file.
+func addCoverageFlags(flags *flag.FlagSet) *coverageFlags {
+	return &coverageFlags{
+		reportFilepath: flags.String("coverage", "", "Write a coverage report of the ROM addresses executed as instructions and read or written as data, as an annotated listing or as an HTML page (\"*.html\"). Default value \"\" (no coverage report)."),
+	}
+}
+
+// coverage creates the coverage, nil if not reporting coverage.
+func (c *coverageFlags) coverage() *chip8.Coverage {
+	if *c.reportFilepath == "" {
+		return nil
+	}
+	return chip8.NewCoverage()
+}
+
+// write writes the coverage report of the ROM loaded at the load address.
+func (c *coverageFlags) write(coverage *chip8.Coverage, romFilepath string, loadAddress uint16) {
+	rom, err := os.ReadFile(romFilepath)
+	if err == nil {
+		err = coverage.WriteReport(*c.reportFilepath, romFilepath, rom, loadAddress)
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+	} else {
+		fmt.Printf("Wrote coverage report \"%s\"\n", *c.reportFilepath)
+	}
+}
+
+// lookupROM finds the ROM in the ROM database file, or else in the built-in ROM database. Unknown ROMs are nil.
+func lookupROM(romDatabaseFilepath string, romFilepath string) (*chip8.ROMInfo, error) {
+	romDatabase := chip8.DefaultROMDatabase()
+	if romDatabaseFilepath != "" {
+		var err error
+		if romDatabase, err = chip8.LoadROMDatabase(romDatabaseFilepath); err != nil {
+			return nil, err
+		}
+	}
+
+	romBytes, err := os.ReadFile(romFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ROM file \"%s\": %w", romFilepath, err)
+	}
+
+	romInfo, _ := romDatabase.Lookup(romBytes)
+	return romInfo, nil
+}
+
+// romMachine is the machine with the name, or else the machine of the ROM platform, or else the default machine.
+// Every command resolves the machine with it, so a ROM gets the same load address and quirks in every command.
+func romMachine(machineName string, romInfo *chip8.ROMInfo) (chip8.Machine, error) {
+	if machineName != "" {
+		return chip8.MachineByName(machineName)
+	}
+	if romInfo != nil {
+		return romInfo.Machine(), nil
+	}
+	return chip8.DefaultMachine(), nil
+}
+
+// selectKeymap is the keymap with the name, or else the keymap file next to the ROM,
+// or else the keymap suggested by the ROM database, or else the default keymap.
+func selectKeymap(keymapName string, romFilepath string, romInfo *chip8.ROMInfo) (chip8.Keymap, error) {
+	if keymapName != "" {
+		return chip8.SelectKeymap(keymapName)
+	}
+
+	if _, err := os.Stat(chip8.KeymapFilepathForROM(romFilepath)); (err != nil) && (romInfo != nil) {
+		if keymap, suggested := romInfo.Keymap(); suggested {
+			return keymap, nil
+		}
+	}
+
+	return chip8.KeymapForROM(romFilepath)
+}
+
+// parseROMFlags parses the flags of the command, which takes a single ROM file argument.
+func parseROMFlags(flags *flag.FlagSet, arguments []string) (romFilepath string, ok bool) {
+	flags.Parse(arguments)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", false
+	}
+	return flags.Arg(0), true
+}
+
+// newFlagSet creates the flag set of the command, with usage of its arguments.
+func newFlagSet(command string, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: chip8 %s [flags] %s\n", command, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
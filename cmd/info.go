@@ -0,0 +1,111 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// infoCommand prints the metadata of the ROM and its static analysis, to choose the machine before running it.
+func infoCommand(arguments []string) int {
+	flags := newFlagSet("info", "<ROM file>")
+	machineFlags := addMachineFlags(flags)
+
+	romFilepath, ok := parseROMFlags(flags, arguments)
+	if !ok {
+		return 1
+	}
+
+	romBytes, err := os.ReadFile(romFilepath)
+	if err != nil {
+		fmt.Printf("could not read ROM file \"%s\": %s\n", romFilepath, err.Error())
+		return 1
+	}
+
+	machine, romInfo, err := machineFlags.machine(romFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	analysis := chip8.AnalyzeROM(romBytes, machine)
+
+	fmt.Printf("ROM file:        %s\n", romFilepath)
+	fmt.Printf("Size:            %d bytes\n", len(romBytes))
+	fmt.Printf("SHA-1:           %x\n", sha1.Sum(romBytes))
+	if romInfo != nil {
+		release := ""
+		if romInfo.Release != "" {
+			release = ", " + romInfo.Release
+		}
+		fmt.Printf("ROM database:    %s%s (platform %s)\n", romInfo, release, romInfo.Platform)
+		if romInfo.Description != "" {
+			fmt.Printf("                 %s\n", romInfo.Description)
+		}
+	} else {
+		fmt.Printf("ROM database:    not found\n")
+	}
+	fmt.Printf("Analyzed as:     %s, loaded at 0x%03X\n", machine.Name, machine.LoadAddress)
+	fmt.Println()
+
+	var sets []string
+	for _, set := range []chip8.InstructionSet{chip8.InstructionSetChip8, chip8.InstructionSetSCHIP, chip8.InstructionSetXOCHIP, chip8.InstructionSetMachineCode} {
+		if count := analysis.Sets[set]; count > 0 {
+			sets = append(sets, fmt.Sprintf("%d %s", count, set))
+		}
+	}
+	fmt.Printf("Instructions:    %d reachable (%s)\n", len(analysis.Instructions), strings.Join(sets, ", "))
+	fmt.Printf("Platform:        %s (machine \"%s\")\n", analysis.Set(), analysis.Machine())
+	if analysis.Sets[chip8.InstructionSetMachineCode] > 0 {
+		fmt.Printf("                 calls machine code routines (0NNN), not supported by the interpreter\n")
+	}
+	if analysis.MaxCallDepth < 0 {
+		fmt.Printf("Call depth:      unbounded, subroutines call themselves or jump back into their callers (stack depth %d)\n", machine.StackDepth)
+	} else {
+		fmt.Printf("Call depth:      %d (stack depth %d)\n", analysis.MaxCallDepth, machine.StackDepth)
+	}
+	if len(analysis.ComputedJumps) > 0 {
+		fmt.Printf("Computed jumps:  %s (not followed)\n", addressList(analysis.ComputedJumps))
+	}
+	if len(analysis.UnknownOpcodes) > 0 {
+		fmt.Printf("Unknown opcodes: %s\n", addressList(analysis.UnknownOpcodes))
+	}
+	fmt.Println()
+
+	fmt.Println("Quirk sensitive instructions:")
+	for _, quirkUse := range analysis.QuirkUses {
+		if len(quirkUse.Addresses) == 0 {
+			fmt.Printf("  %-56s not used\n", quirkUse.Quirk)
+		} else {
+			fmt.Printf("  %-56s %s at %s\n", quirkUse.Quirk, strings.Join(quirkUse.Opcodes, ", "), addressList(quirkUse.Addresses))
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("Sprite data:")
+	if len(analysis.SpriteRegions) == 0 {
+		fmt.Println("  none found")
+	}
+	for _, region := range analysis.SpriteRegions {
+		fmt.Printf("  0x%03X-0x%03X  %3d bytes\n", region.Start, region.End-1, region.End-region.Start)
+	}
+
+	return 0
+}
+
+// addressList is the addresses in hexadecimal, the first 8 of them.
+func addressList(addresses []uint16) string {
+	const maxAddresses = 8
+
+	var texts []string
+	for i, address := range addresses {
+		if i == maxAddresses {
+			texts = append(texts, fmt.Sprintf("and %d more", len(addresses)-maxAddresses))
+			break
+		}
+		texts = append(texts, fmt.Sprintf("0x%03X", address))
+	}
+	return strings.Join(texts, ", ")
+}
@@ -1,49 +1,50 @@
 package main
 
 import (
-	"chip8/pkg/chip8"
-	"flag"
 	"fmt"
 	"os"
 )
 
+// commands are the subcommands, by name
+var commands = map[string]func(arguments []string) int{
+	"run":       func(arguments []string) int { return runCommand("run", arguments) },
+	"debug":     func(arguments []string) int { return runCommand("debug", arguments) },
+	"disasm":    disasmCommand,
+	"asm":       asmCommand,
+	"info":      infoCommand,
+	"test":      testCommand,
+	"tracediff": tracediffCommand,
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("You need to supply at least 1 argument to the program. The file path to a ROM file.")
+	if len(os.Args) < 2 {
+		printUsage()
 		os.Exit(1)
 	}
 
-	screenAddress := flag.String("screenAddress", "localhost:9999", "The socket address of the screen application. Format: \"127.0.0.1:9999\". Default value: \"127.0.0.1:9999\".")
-	listenKeyStatePort := flag.Int("keystatePort", 9998, "The port where to listen for key press state changes. Format: \"9998\". Default value \"9998\".")
-	flag.Parse() // add this line
-	romFilepath := flag.Arg(0)
-
-	configuration := chip8.Configuration{
-		Disassemble:          false,
-		Debug:                false,
-		EndOnInfiniteLoop:    true,
-		ModeRomCompatibility: true,
-		ModeStrictCosmac:     false,
+	command, found := commands[os.Args[1]]
+	switch {
+	case found:
+		os.Exit(command(os.Args[2:]))
+	case (os.Args[1] == "help") || (os.Args[1] == "-h") || (os.Args[1] == "-help") || (os.Args[1] == "--help"):
+		printUsage()
+	default:
+		// Without a command, the ROM is run (as before there were commands)
+		os.Exit(runCommand("run", os.Args[1:]))
 	}
+}
 
-	if !configuration.Disassemble {
-		fmt.Println()
-		fmt.Printf("CHIP-8 execution of ROM file \"%s\"\n", romFilepath)
-		fmt.Printf("Using screen address:                    %s\n", *screenAddress)
-		fmt.Printf("Listening to key state changes on port:  %d\n", *listenKeyStatePort)
-		fmt.Println()
-		fmt.Printf("Configuration:\n%+v\n", configuration)
-		fmt.Println()
-
-		peripherals := chip8.NewPeripherals(*screenAddress, *listenKeyStatePort)
-		peripherals.StartKeyPadListener()
-
-		machine := chip8.NewChip8(&peripherals)
-		machine.LoadROM(romFilepath)
-		machine.Run(configuration)
-	} else {
-		fmt.Printf("CHIP-8 disassembly of \"%s\":\n", romFilepath)
-		fmt.Printf("%+v\n", configuration)
-		chip8.DisassembleProgram(romFilepath, 0x200, configuration)
-	}
+func printUsage() {
+	fmt.Println("Usage: chip8 <command> [flags] <files>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  run        Run a ROM (the default command)")
+	fmt.Println("  debug      Run a ROM, printing every executed instruction")
+	fmt.Println("  disasm     Print the disassembly of a ROM")
+	fmt.Println("  asm        Assemble a source file into a ROM")
+	fmt.Println("  info       Print the metadata and static analysis of a ROM")
+	fmt.Println("  test       Run a ROM headless and compare the screen with a golden screenshot")
+	fmt.Println("  tracediff  Report the first divergence of a trace from the trace of a reference emulator")
+	fmt.Println()
+	fmt.Println("\"chip8 <command> -h\" prints the flags of the command.")
 }
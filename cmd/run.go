@@ -0,0 +1,200 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// runCommand executes the ROM. The debug command is the run command printing every executed instruction.
+func runCommand(command string, arguments []string) int {
+	flags := newFlagSet(command, "<ROM file>")
+	configurationFlags := addConfigurationFlags(flags)
+	transportFlags := addTransportFlags(flags)
+	traceFlags := addTraceFlags(flags)
+	profileFlags := addProfileFlags(flags)
+	coverageFlags := addCoverageFlags(flags)
+	inputMovieFilepath := flags.String("input", "", "The file path of an input movie with key states to play back frame by frame. Default value \"\" (no input movie).")
+	inputRecordingFilepath := flags.String("record-input", "", "Record the key presses and releases, frame by frame, to an input movie file to be played back with -input. Default value \"\" (no input recording).")
+	recordingFilepath := flags.String("record", "", "Record the screen every frame to an animated GIF (\"*.gif\") or to a PNG sequence (\"frames/frame%05d.png\"). Default value \"\" (no recording).")
+	printConfig := flags.Bool("print-config", false, "Print the effective settings, merged from the machine, ROM database, config file and flags, in the config file format, without running the ROM. Default value false.")
+
+	romFilepath, ok := parseROMFlags(flags, arguments)
+	if !ok {
+		return 1
+	}
+
+	configuration, machineProfile, romInfo, err := configurationFlags.configuration(romFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	if command == "debug" {
+		configuration.Debug = true
+	}
+
+	if *printConfig {
+		return printSettings(configuration, machineProfile, romInfo, romFilepath, transportFlags)
+	}
+
+	fmt.Println()
+	fmt.Printf("CHIP-8 execution of ROM file \"%s\"\n", romFilepath)
+	if romInfo != nil {
+		fmt.Printf("ROM:                                     %s (%s)\n", romInfo, romInfo.Platform)
+	}
+	fmt.Printf("Machine:                                 %s (%s)\n", machineProfile.Name, machineProfile.Description)
+	if !configuration.Headless && (transportFlags.displayName() == "udp") {
+		fmt.Printf("Using screen address:                    %s\n", *transportFlags.screenAddress)
+		fmt.Printf("Listening to key state changes on port:  %d\n", *transportFlags.listenKeyStatePort)
+	}
+	fmt.Println()
+	fmt.Printf("Configuration:\n%+v\n", configuration)
+	fmt.Println()
+
+	peripherals, err := transportFlags.peripherals(configuration.Headless, romFilepath, romInfo)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 2
+	}
+	peripherals.StartKeyPadListener()
+
+	machine := chip8.NewChip8ForMachine(peripherals, machineProfile)
+	machine.LoadROM(romFilepath)
+
+	if *inputMovieFilepath != "" {
+		inputMovie, err := chip8.LoadInputMovie(*inputMovieFilepath)
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+		machine.PlayInputMovie(inputMovie)
+	}
+
+	tracer, err := traceFlags.tracer()
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	if tracer != nil {
+		machine.SetTracer(tracer)
+	}
+
+	profiler := profileFlags.profiler()
+	if profiler != nil {
+		machine.SetProfiler(profiler)
+	}
+
+	coverage := coverageFlags.coverage()
+	if coverage != nil {
+		machine.SetCoverage(coverage)
+	}
+
+	var recorder *chip8.Recorder
+	if *recordingFilepath != "" {
+		recorder = chip8.NewRecorder(configuration.ScreenshotScale, configuration.Palette)
+		machine.SeedRandom(configuration.Seed)
+		machine.AddFrameListener(recorder.CaptureFrame)
+	}
+
+	var inputRecorder *chip8.InputMovieRecorder
+	if *inputRecordingFilepath != "" {
+		inputRecorder = chip8.NewInputMovieRecorder()
+		machine.AddKeyEventListener(inputRecorder.CaptureKeyEvent)
+	}
+
+	if (command == "debug") && (transportFlags.displayName() != "tty") { // The terminal display reads the keys from standard input
+		startDebugCommands(os.Stdin, machine, peripherals, configuration)
+	}
+
+	err = machine.Run(configuration)
+	peripherals.Close()
+
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			fmt.Printf("Could not write trace \"%s\": %s\n", *traceFlags.traceFilepath, err.Error())
+		} else {
+			fmt.Printf("Wrote trace \"%s\" of %d instructions\n", *traceFlags.traceFilepath, machine.Cycles)
+		}
+	}
+
+	if profiler != nil {
+		profileFlags.write(profiler, romFilepath)
+	}
+	if coverage != nil {
+		coverageFlags.write(coverage, romFilepath, machineProfile.LoadAddress)
+	}
+
+	if inputRecorder != nil {
+		if err := inputRecorder.Write(*inputRecordingFilepath); err != nil {
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Wrote input recording \"%s\"\n", *inputRecordingFilepath)
+		}
+	}
+
+	if recorder != nil {
+		if err := recorder.Write(*recordingFilepath); err != nil {
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Wrote recording \"%s\" of %d frames\n", *recordingFilepath, machine.Frame)
+		}
+	}
+
+	if errors.Is(err, chip8.ErrInfiniteLoop) {
+		fmt.Println("Terminated emulator and program on detected infinite loop")
+	} else if err != nil {
+		fmt.Printf("Terminated emulator and program: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// printSettings prints the effective settings of the execution in the config file format.
+func printSettings(configuration chip8.Configuration, machineProfile chip8.Machine, romInfo *chip8.ROMInfo, romFilepath string, transportFlags *transportFlags) int {
+	keymap, err := selectKeymap(*transportFlags.keymapName, romFilepath, romInfo)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	settings := map[string]interface{}{
+		"machine":                   machineProfile.Name,
+		"debug":                     configuration.Debug,
+		"mode-rom-compatibility":    configuration.ModeRomCompatibility,
+		"mode-strict-cosmac":        configuration.ModeStrictCosmac,
+		"end-on-infinite-loop":      configuration.EndOnInfiniteLoop,
+		"restart-on-infinite-loop":  configuration.RestartOnInfiniteLoop,
+		"quirk-key-wait-release":    configuration.QuirkKeyWaitRelease,
+		"quirk-display-wait":        configuration.QuirkDisplayWait,
+		"quirk-wrap-sprites":        configuration.QuirkWrapSprites,
+		"quirk-collision-row-count": configuration.QuirkCollisionRowCount,
+		"cycles-per-frame":          configuration.CyclesPerFrame,
+		"frames":                    configuration.Frames,
+		"headless":                  configuration.Headless,
+		"seed":                      configuration.Seed,
+		"screenshot-after":          configuration.ScreenshotAfter,
+		"screenshot":                configuration.ScreenshotFilepath,
+		"screenshot-scale":          configuration.ScreenshotScale,
+		"palette":                   configuration.Palette.String(),
+		"display":                   transportFlags.displayName(),
+		"http":                      *transportFlags.httpAddress,
+		"listen":                    *transportFlags.listenAddress,
+		"screenAddress":             *transportFlags.screenAddress,
+		"keystatePort":              *transportFlags.listenKeyStatePort,
+		"keymap":                    keymap.Name,
+		"tty-key-hold":              transportFlags.ttyKeyHold.String(),
+		"compression":               *transportFlags.compression,
+	}
+
+	settingsJSON, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	fmt.Println(string(settingsJSON))
+
+	return 0
+}
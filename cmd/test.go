@@ -0,0 +1,106 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// testCommand runs the ROM headless for a number of frames and compares the screen with a golden PNG screenshot.
+func testCommand(arguments []string) int {
+	flags := newFlagSet("test", "-frames <frames> -golden <PNG file> <ROM file>")
+	configurationFlags := addConfigurationFlags(flags)
+	traceFlags := addTraceFlags(flags)
+	profileFlags := addProfileFlags(flags)
+	coverageFlags := addCoverageFlags(flags)
+	goldenFilepath := flags.String("golden", "", "The file path of the PNG screenshot the screen is expected to match after the last frame.")
+	inputMovieFilepath := flags.String("input", "", "The file path of an input movie with key states to play back frame by frame. Default value \"\" (no input movie).")
+	update := flags.Bool("update", false, "Write the screen after the last frame as the golden screenshot, instead of comparing. Default value false.")
+
+	romFilepath, ok := parseROMFlags(flags, arguments)
+	if !ok {
+		return 1
+	}
+	if (*goldenFilepath == "") || (*configurationFlags.frames == 0) {
+		fmt.Println("A test needs the number of frames to run (-frames) and a golden screenshot (-golden).")
+		return 1
+	}
+
+	configuration, machineProfile, _, err := configurationFlags.configuration(romFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	configuration.Headless = true
+
+	peripherals := chip8.NewHeadlessPeripherals()
+	machine := chip8.NewChip8ForMachine(&peripherals, machineProfile)
+	machine.LoadROM(romFilepath)
+
+	if *inputMovieFilepath != "" {
+		inputMovie, err := chip8.LoadInputMovie(*inputMovieFilepath)
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+		machine.PlayInputMovie(inputMovie)
+	}
+
+	tracer, err := traceFlags.tracer()
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	if tracer != nil {
+		machine.SetTracer(tracer)
+		defer tracer.Close()
+	}
+
+	profiler := profileFlags.profiler()
+	if profiler != nil {
+		machine.SetProfiler(profiler)
+	}
+
+	coverage := coverageFlags.coverage()
+	if coverage != nil {
+		machine.SetCoverage(coverage)
+	}
+
+	err = machine.Run(configuration)
+	if profiler != nil {
+		profileFlags.write(profiler, romFilepath)
+	}
+	if coverage != nil {
+		coverageFlags.write(coverage, romFilepath, machineProfile.LoadAddress)
+	}
+	if (err != nil) && !errors.Is(err, chip8.ErrInfiniteLoop) {
+		fmt.Printf("FAIL %s: %s\n", romFilepath, err.Error())
+		return 1
+	}
+	screen := machine.Screen()
+
+	if *update {
+		if err := screen.WritePNG(*goldenFilepath, configuration.ScreenshotScale, configuration.Palette); err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+		fmt.Printf("Wrote golden screenshot \"%s\" after %d frames\n", *goldenFilepath, machine.Frame)
+		return 0
+	}
+
+	differingPixels, err := screen.DiffPNG(*goldenFilepath, configuration.Palette)
+	if err != nil {
+		fmt.Printf("FAIL %s: %s\n", romFilepath, err.Error())
+		return 1
+	}
+	if differingPixels > 0 {
+		actualFilepath := strings.TrimSuffix(*goldenFilepath, ".png") + ".actual.png"
+		screen.WritePNG(actualFilepath, configuration.ScreenshotScale, configuration.Palette)
+		fmt.Printf("FAIL %s: %d pixels differ from \"%s\" after %d frames, actual screen written to \"%s\"\n", romFilepath, differingPixels, *goldenFilepath, machine.Frame, actualFilepath)
+		return 1
+	}
+
+	fmt.Printf("PASS %s: screen matches \"%s\" after %d frames\n", romFilepath, *goldenFilepath, machine.Frame)
+	return 0
+}
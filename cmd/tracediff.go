@@ -0,0 +1,90 @@
+package main
+
+import (
+	"chip8/pkg/chip8"
+	"fmt"
+	"strings"
+)
+
+// tracediffCommand reports the first divergence of our trace from the trace of a reference emulator.
+func tracediffCommand(arguments []string) int {
+	flags := newFlagSet("tracediff", "<our trace file> <reference trace file>")
+	contextSize := flags.Int("context", 5, "The number of records printed before and after the divergence. Default value 5.")
+
+	flags.Parse(arguments)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 1
+	}
+	oursFilepath, referenceFilepath := flags.Arg(0), flags.Arg(1)
+
+	ours, err := chip8.ReadTraceLog(oursFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	reference, err := chip8.ReadTraceLog(referenceFilepath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	divergence := chip8.DiffTraces(ours, reference)
+	if divergence == nil {
+		fmt.Printf("Traces are equal (%d records)\n", len(ours.Records))
+		return 0
+	}
+
+	fmt.Printf("First divergence at record %d", divergence.Index+1)
+	if divergence.Index < len(ours.Records) {
+		record := ours.Records[divergence.Index]
+		fmt.Printf(" (cycle %d, frame %d, PC 0x%03X: %04X %s)", record.Cycle, record.Frame, record.PC, record.Opcode, record.Mnemonic)
+	}
+	fmt.Println()
+	fmt.Printf("%s: ours %s, reference %s\n", divergence.Field, divergence.Ours, divergence.Reference)
+	fmt.Println()
+
+	fmt.Printf("   %-38s  %s\n", "Ours ("+oursFilepath+")", "Reference ("+referenceFilepath+")")
+	for index := divergence.Index - *contextSize; index <= divergence.Index+*contextSize; index++ {
+		if (index < 0) || ((index >= len(ours.Records)) && (index >= len(reference.Records))) {
+			continue
+		}
+
+		marker := " "
+		if index == divergence.Index {
+			marker = ">"
+		}
+		fmt.Printf("%s  %-38s  %s\n", marker, traceRecordLine(ours.Records, index), traceRecordLine(reference.Records, index))
+	}
+	fmt.Println()
+
+	fmt.Println("Registers before the diverging instruction:")
+	fmt.Printf("  ours:       %s\n", traceRegistersLine(ours.Records, divergence.Index))
+	fmt.Printf("  reference:  %s\n", traceRegistersLine(reference.Records, divergence.Index))
+
+	return 1
+}
+
+// traceRecordLine is the cycle and the disassembly of the record, like "#124 0x21A 8346 SHR V3, V4"
+func traceRecordLine(records []chip8.TraceRecord, index int) string {
+	if index >= len(records) {
+		return "-"
+	}
+	record := records[index]
+	return fmt.Sprintf("#%-6d 0x%03X  %04X  %s", record.Cycle, record.PC, record.Opcode, record.Mnemonic)
+}
+
+// traceRegistersLine is the registers of the record, like "V0=00 V1=2A ... VF=01 I=0x300"
+func traceRegistersLine(records []chip8.TraceRecord, index int) string {
+	if index >= len(records) {
+		return "-"
+	}
+	record := records[index]
+
+	registers := make([]string, 0, len(record.V)+1)
+	for register, value := range record.V {
+		registers = append(registers, fmt.Sprintf("V%X=%02X", register, value))
+	}
+	registers = append(registers, fmt.Sprintf("I=0x%03X", record.I))
+	return strings.Join(registers, " ")
+}
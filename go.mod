@@ -2,7 +2,6 @@ module chip8
 
 go 1.19
 
-require (
-	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
-	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
-)
+require github.com/vmihailenco/msgpack/v5 v5.3.5
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
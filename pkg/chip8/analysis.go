@@ -0,0 +1,272 @@
+package chip8
+
+import (
+	"sort"
+)
+
+// ROMAnalysis is the static analysis of a ROM, made by following the control flow of the program from its start address
+// without executing it. Code only reached by computed jumps (BNNN) is not found.
+type ROMAnalysis struct {
+	Size            int
+	LoadAddress     uint16
+	Instructions    map[uint16]Instruction // Instructions are the instructions reachable from the start address, by address
+	Sets            map[InstructionSet]int // Sets are the number of reachable instructions of each instruction set
+	QuirkUses       []QuirkUse             // QuirkUses are the instructions behaving differently depending on each quirk
+	MaxCallDepth    int                    // MaxCallDepth is the deepest nesting of subroutine calls, -1 if unbounded (recursive)
+	ComputedJumps   []uint16               // ComputedJumps are the addresses of BNNN, whose jump targets are not followed
+	SpriteRegions   []MemoryRegion         // SpriteRegions are the memory drawn as sprites, as pointed to by ANNN before DXYN
+	UnknownOpcodes  []uint16               // UnknownOpcodes are the addresses of reachable unknown instructions
+	callGraph       map[uint16][]uint16
+	subroutineDepth map[uint16]int
+}
+
+// QuirkUse is the instructions of the ROM whose behaviour depends on a quirk.
+type QuirkUse struct {
+	Quirk     string
+	Opcodes   []string
+	Addresses []uint16
+}
+
+// MemoryRegion is the memory from the start address up to (not including) the end address.
+type MemoryRegion struct {
+	Start uint16
+	End   uint16
+}
+
+// quirkOpcodes are the instructions depending on each quirk (or mode)
+var quirkOpcodes = []struct {
+	quirk   string
+	opcodes []string
+}{
+	{"shift (8XY6/8XYE shift VY, strict COSMAC mode)", []string{"8XY6", "8XYE"}},
+	{"memory (FX55/FX65 increment I)", []string{"FX55", "FX65"}},
+	{"jump (BNNN offset by V0 or VX)", []string{"BNNN"}},
+	{"index overflow (FX1E sets VF)", []string{"FX1E"}},
+	{"logic (8XY1/8XY2/8XY3 reset VF)", []string{"8XY1", "8XY2", "8XY3"}},
+	{"display wait, sprite wrap and collision count (DXYN)", []string{"DXYN", "DXY0"}},
+	{"key wait release (FX0A)", []string{"FX0A"}},
+}
+
+// AnalyzeROM analyzes the ROM loaded at the load address of the machine.
+func AnalyzeROM(romBytes []byte, machine Machine) *ROMAnalysis {
+	analysis := ROMAnalysis{
+		Size:            len(romBytes),
+		LoadAddress:     machine.LoadAddress,
+		Instructions:    map[uint16]Instruction{},
+		Sets:            map[InstructionSet]int{},
+		MaxCallDepth:    0,
+		callGraph:       map[uint16][]uint16{},
+		subroutineDepth: map[uint16]int{},
+	}
+
+	spriteBytes := map[uint16]bool{}
+	subroutines := []uint16{machine.StartAddress}
+	analyzed := map[uint16]bool{machine.StartAddress: true}
+	for len(subroutines) > 0 {
+		subroutine := subroutines[0]
+		subroutines = subroutines[1:]
+
+		for _, callee := range analysis.followSubroutine(romBytes, subroutine, spriteBytes) {
+			analysis.callGraph[subroutine] = append(analysis.callGraph[subroutine], callee)
+			if !analyzed[callee] {
+				analyzed[callee] = true
+				subroutines = append(subroutines, callee)
+			}
+		}
+	}
+
+	analysis.MaxCallDepth = analysis.callDepth(machine.StartAddress, map[uint16]bool{})
+	analysis.SpriteRegions = memoryRegions(spriteBytes)
+
+	addresses := analysis.addresses()
+	for _, quirkOpcode := range quirkOpcodes {
+		quirkUse := QuirkUse{Quirk: quirkOpcode.quirk}
+		for _, opcode := range quirkOpcode.opcodes {
+			used := false
+			for _, address := range addresses {
+				if analysis.Instructions[address].Opcode == opcode {
+					quirkUse.Addresses = append(quirkUse.Addresses, address)
+					used = true
+				}
+			}
+			if used {
+				quirkUse.Opcodes = append(quirkUse.Opcodes, opcode)
+			}
+		}
+		sort.Slice(quirkUse.Addresses, func(i, j int) bool { return quirkUse.Addresses[i] < quirkUse.Addresses[j] })
+		analysis.QuirkUses = append(analysis.QuirkUses, quirkUse)
+	}
+
+	return &analysis
+}
+
+// followSubroutine follows the control flow from the entry address, without entering called subroutines,
+// and returns the called subroutines. The index register is tracked along straight code to find the sprites drawn.
+func (a *ROMAnalysis) followSubroutine(romBytes []byte, entry uint16, spriteBytes map[uint16]bool) []uint16 {
+	var callees []uint16
+
+	type path struct {
+		address uint16
+		index   int // index is the value of the index register, -1 if unknown
+	}
+	visited := map[path]bool{}
+	paths := []path{{address: entry, index: -1}}
+
+	for len(paths) > 0 {
+		current := paths[len(paths)-1]
+		paths = paths[:len(paths)-1]
+
+		for !visited[current] {
+			visited[current] = true
+
+			offset := int(current.address) - int(a.LoadAddress)
+			if (offset < 0) || (offset+1 >= len(romBytes)) {
+				break // Outside the ROM
+			}
+
+			instruction, known := DecodeInstruction(uint16(romBytes[offset])<<8 | uint16(romBytes[offset+1]))
+			if !known {
+				a.UnknownOpcodes = appendUnique(a.UnknownOpcodes, current.address)
+				break
+			}
+			if _, seen := a.Instructions[current.address]; !seen {
+				a.Instructions[current.address] = instruction
+				a.Sets[instruction.Set]++
+			}
+
+			next := path{address: current.address + instruction.Size(), index: current.index}
+
+			switch instruction.Opcode {
+			case "00EE", "00FD":
+				next.address = current.address // Returned or exited, the path ends
+			case "1NNN":
+				next.address = instruction.NNN
+			case "2NNN":
+				callees = appendUnique(callees, instruction.NNN)
+				next.index = -1 // The subroutine may change the index register
+			case "BNNN":
+				a.ComputedJumps = appendUnique(a.ComputedJumps, current.address)
+				next.address = current.address
+			case "ANNN":
+				next.index = int(instruction.NNN)
+			case "F000":
+				if offset+3 < len(romBytes) {
+					next.index = int(romBytes[offset+2])<<8 | int(romBytes[offset+3])
+				}
+			case "FX1E", "FX29", "FX30", "FX55", "FX65", "5XY2", "5XY3":
+				next.index = -1
+			case "DXYN", "DXY0":
+				if current.index >= 0 {
+					length := uint16(instruction.N)
+					if instruction.Opcode == "DXY0" {
+						length = 32
+					}
+					for spriteAddress := uint16(current.index); spriteAddress < uint16(current.index)+length; spriteAddress++ {
+						spriteBytes[spriteAddress] = true
+					}
+				}
+			}
+
+			if instruction.IsSkip() {
+				paths = append(paths, path{address: next.address + 2, index: next.index})
+			}
+
+			if next.address == current.address {
+				break
+			}
+			current = next
+		}
+	}
+
+	return callees
+}
+
+// callDepth is the deepest nesting of calls from the subroutine, -1 if recursive.
+func (a *ROMAnalysis) callDepth(subroutine uint16, calling map[uint16]bool) int {
+	if calling[subroutine] {
+		return -1
+	}
+	if depth, known := a.subroutineDepth[subroutine]; known {
+		return depth
+	}
+
+	calling[subroutine] = true
+	depth := 0
+	for _, callee := range a.callGraph[subroutine] {
+		calleeDepth := a.callDepth(callee, calling)
+		if calleeDepth < 0 {
+			depth = -1
+			break
+		}
+		if calleeDepth+1 > depth {
+			depth = calleeDepth + 1
+		}
+	}
+	delete(calling, subroutine)
+
+	a.subroutineDepth[subroutine] = depth
+	return depth
+}
+
+// Set is the most extended instruction set used: XO-CHIP, SCHIP or otherwise CHIP-8.
+func (a *ROMAnalysis) Set() InstructionSet {
+	if a.Sets[InstructionSetXOCHIP] > 0 {
+		return InstructionSetXOCHIP
+	}
+	if a.Sets[InstructionSetSCHIP] > 0 {
+		return InstructionSetSCHIP
+	}
+	return InstructionSetChip8
+}
+
+// Machine is the name of the machine suggested by the instruction set used.
+func (a *ROMAnalysis) Machine() string {
+	switch a.Set() {
+	case InstructionSetXOCHIP:
+		return "xo-chip"
+	case InstructionSetSCHIP:
+		return "schip"
+	}
+	if a.LoadAddress == romAddressEti660 {
+		return "eti-660"
+	}
+	return "cosmac-vip"
+}
+
+// addresses are the addresses of the reachable instructions in increasing order.
+func (a *ROMAnalysis) addresses() []uint16 {
+	addresses := make([]uint16, 0, len(a.Instructions))
+	for address := range a.Instructions {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	return addresses
+}
+
+// memoryRegions merges the addresses into consecutive regions.
+func memoryRegions(addresses map[uint16]bool) []MemoryRegion {
+	var regions []MemoryRegion
+	sortedAddresses := make([]uint16, 0, len(addresses))
+	for address := range addresses {
+		sortedAddresses = append(sortedAddresses, address)
+	}
+	sort.Slice(sortedAddresses, func(i, j int) bool { return sortedAddresses[i] < sortedAddresses[j] })
+
+	for _, address := range sortedAddresses {
+		if (len(regions) > 0) && (regions[len(regions)-1].End == address) {
+			regions[len(regions)-1].End++
+		} else {
+			regions = append(regions, MemoryRegion{Start: address, End: address + 1})
+		}
+	}
+	return regions
+}
+
+func appendUnique(addresses []uint16, address uint16) []uint16 {
+	for _, existingAddress := range addresses {
+		if existingAddress == address {
+			return addresses
+		}
+	}
+	return append(addresses, address)
+}
@@ -0,0 +1,89 @@
+package chip8
+
+import (
+	"testing"
+)
+
+func TestDecodeInstruction(t *testing.T) {
+	expectedInstructions := map[uint16]Instruction{
+		0x00E0: {Code: 0x00E0, Opcode: "00E0", Set: InstructionSetChip8, N: 0x0, NN: 0xE0, NNN: 0x0E0, Y: 0xE},
+		0x0123: {Code: 0x0123, Opcode: "0NNN", Set: InstructionSetMachineCode, X: 0x1, Y: 0x2, N: 0x3, NN: 0x23, NNN: 0x123},
+		0x00FF: {Code: 0x00FF, Opcode: "00FF", Set: InstructionSetSCHIP, Y: 0xF, N: 0xF, NN: 0xFF, NNN: 0x0FF},
+		0x8AB6: {Code: 0x8AB6, Opcode: "8XY6", Set: InstructionSetChip8, X: 0xA, Y: 0xB, N: 0x6, NN: 0xB6, NNN: 0xAB6},
+		0xD120: {Code: 0xD120, Opcode: "DXY0", Set: InstructionSetSCHIP, X: 0x1, Y: 0x2, NN: 0x20, NNN: 0x120},
+		0x5122: {Code: 0x5122, Opcode: "5XY2", Set: InstructionSetXOCHIP, X: 0x1, Y: 0x2, N: 0x2, NN: 0x22, NNN: 0x122},
+	}
+
+	for code, expectedInstruction := range expectedInstructions {
+		if instruction, known := DecodeInstruction(code); !known || (instruction != expectedInstruction) {
+			t.Errorf("expected %04X decoded as %+v, got %+v (known %v)", code, expectedInstruction, instruction, known)
+		}
+	}
+
+	for _, unknownCode := range []uint16{0x5121, 0x8128, 0xE19F, 0xF1FF} {
+		if instruction, known := DecodeInstruction(unknownCode); known {
+			t.Errorf("expected %04X to be unknown, got %+v", unknownCode, instruction)
+		}
+	}
+}
+
+func TestAnalyzeROM(t *testing.T) {
+	program := []byte{
+		0x22, 0x08, // 0x200: call 0x208
+		0xF0, 0x0A, // 0x202: wait for key
+		0xB2, 0x00, // 0x204: computed jump
+		0x00, 0x00, // 0x206: (not reached)
+		0xA2, 0x16, // 0x208: I = 0x216
+		0xD0, 0x13, // 0x20A: draw 3 rows of sprite at 0x216
+		0x22, 0x12, // 0x20C: call 0x212
+		0x00, 0xEE, // 0x20E: return
+		0x00, 0x00, // 0x210: (not reached)
+		0x81, 0x26, // 0x212: shift
+		0x00, 0xEE, // 0x214: return
+		0xE0, 0xA0, 0xE0, // 0x216: sprite
+	}
+
+	analysis := AnalyzeROM(program, DefaultMachine())
+
+	if len(analysis.Instructions) != 9 {
+		t.Errorf("expected 9 reachable instructions, got %d", len(analysis.Instructions))
+	}
+	if analysis.MaxCallDepth != 2 {
+		t.Errorf("expected call depth 2, got %d", analysis.MaxCallDepth)
+	}
+	if (len(analysis.ComputedJumps) != 1) || (analysis.ComputedJumps[0] != 0x204) {
+		t.Errorf("expected computed jump at 0x204, got %v", analysis.ComputedJumps)
+	}
+	if (len(analysis.SpriteRegions) != 1) || (analysis.SpriteRegions[0] != MemoryRegion{Start: 0x216, End: 0x219}) {
+		t.Errorf("expected sprite at 0x216-0x218, got %v", analysis.SpriteRegions)
+	}
+	if (analysis.Set() != InstructionSetChip8) || (analysis.Machine() != "cosmac-vip") {
+		t.Errorf("expected CHIP-8 on cosmac-vip, got %s on %s", analysis.Set(), analysis.Machine())
+	}
+
+	usedOpcodes := map[string]uint16{}
+	for _, quirkUse := range analysis.QuirkUses {
+		for i, opcode := range quirkUse.Opcodes {
+			usedOpcodes[opcode] = quirkUse.Addresses[i]
+		}
+	}
+	expectedOpcodes := map[string]uint16{"8XY6": 0x212, "BNNN": 0x204, "DXYN": 0x20A, "FX0A": 0x202}
+	if len(usedOpcodes) != len(expectedOpcodes) {
+		t.Errorf("expected quirk sensitive opcodes %v, got %v", expectedOpcodes, usedOpcodes)
+	}
+	for opcode, address := range expectedOpcodes {
+		if usedOpcodes[opcode] != address {
+			t.Errorf("expected %s at 0x%03X, got 0x%03X", opcode, address, usedOpcodes[opcode])
+		}
+	}
+
+	recursiveProgram := []byte{0x22, 0x00}
+	if depth := AnalyzeROM(recursiveProgram, DefaultMachine()).MaxCallDepth; depth != -1 {
+		t.Errorf("expected unbounded call depth of recursive program, got %d", depth)
+	}
+
+	schipProgram := []byte{0x00, 0xFF, 0x12, 0x02}
+	if analysis := AnalyzeROM(schipProgram, DefaultMachine()); analysis.Machine() != "schip" {
+		t.Errorf("expected schip machine for SCHIP instructions, got %s", analysis.Machine())
+	}
+}
@@ -0,0 +1,286 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Assemble assembles CHIP-8 source into a ROM loaded at the load address.
+//
+// The source has one instruction per line, in the mnemonics of Cowgod's CHIP-8 technical reference (like "LD V0, 0x0C"
+// or "DRW V0, V1, 15"), and "DB"/"DW" for data bytes and words. Lines may start with a label ("loop:") usable in place of
+// any number. Numbers are decimal, hexadecimal ("0x2A" or "#2A") or binary ("0b00101010"). Comments start with ";".
+//
+//	start:  CLS
+//	        LD   I, logo
+//	        DRW  V0, V1, 2
+//	end:    JP   end
+//	logo:   DB   0b11110000, 0x90
+func Assemble(source string, loadAddress uint16) ([]byte, error) {
+	type statement struct {
+		lineNumber int
+		mnemonic   string
+		operands   []string
+	}
+
+	var statements []statement
+	labels := map[string]uint16{}
+	address := loadAddress
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if commentIndex := strings.Index(line, ";"); commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+		line = strings.TrimSpace(line)
+
+		if colonIndex := strings.Index(line, ":"); colonIndex >= 0 {
+			label := strings.TrimSpace(line[:colonIndex])
+			if !isAssemblerLabel(label) {
+				return nil, fmt.Errorf("line %d: illegal label \"%s\"", lineNumber, label)
+			}
+			if _, defined := labels[strings.ToLower(label)]; defined {
+				return nil, fmt.Errorf("line %d: label \"%s\" is already defined", lineNumber, label)
+			}
+			labels[strings.ToLower(label)] = address
+			line = strings.TrimSpace(line[colonIndex+1:])
+		}
+
+		if line == "" {
+			continue
+		}
+
+		mnemonic, operandsText, _ := strings.Cut(line, " ")
+		s := statement{lineNumber: lineNumber, mnemonic: strings.ToUpper(mnemonic)}
+		if operandsText = strings.TrimSpace(operandsText); operandsText != "" {
+			for _, operand := range strings.Split(operandsText, ",") {
+				s.operands = append(s.operands, strings.TrimSpace(operand))
+			}
+		}
+		statements = append(statements, s)
+
+		switch s.mnemonic {
+		case "DB":
+			address += uint16(len(s.operands))
+		case "DW":
+			address += 2 * uint16(len(s.operands))
+		default:
+			address += 2
+		}
+	}
+
+	var rom []byte
+	for _, s := range statements {
+		assembler := statementAssembler{operands: s.operands, labels: labels}
+		var bytes []byte
+		var err error
+
+		switch s.mnemonic {
+		case "DB":
+			for i := range s.operands {
+				bytes = append(bytes, uint8(assembler.value(i, 0xFF)))
+			}
+		case "DW":
+			for i := range s.operands {
+				value := assembler.value(i, 0xFFFF)
+				bytes = append(bytes, uint8(value>>8), uint8(value))
+			}
+		default:
+			var code uint16
+			code, err = assembler.instruction(s.mnemonic)
+			bytes = []byte{uint8(code >> 8), uint8(code)}
+		}
+
+		if err == nil {
+			err = assembler.err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", s.lineNumber, err)
+		}
+		rom = append(rom, bytes...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rom, nil
+}
+
+// statementAssembler encodes the operands of a statement. The first operand error is kept in err.
+type statementAssembler struct {
+	operands []string
+	labels   map[string]uint16
+	err      error
+}
+
+// instruction encodes the instruction, matching the operand kinds to the instruction variants.
+func (a *statementAssembler) instruction(mnemonic string) (uint16, error) {
+	kinds := make([]string, len(a.operands))
+	for i, operand := range a.operands {
+		kinds[i] = operandKind(operand)
+	}
+	signature := mnemonic + " " + strings.Join(kinds, ",")
+
+	switch signature {
+	case "CLS ":
+		return 0x00E0, nil
+	case "RET ":
+		return 0x00EE, nil
+	case "SYS n":
+		return 0x0000 | a.value(0, 0xFFF), nil
+	case "JP n":
+		return 0x1000 | a.value(0, 0xFFF), nil
+	case "CALL n":
+		return 0x2000 | a.value(0, 0xFFF), nil
+	case "SE V,n":
+		return 0x3000 | a.x(0) | a.value(1, 0xFF), nil
+	case "SNE V,n":
+		return 0x4000 | a.x(0) | a.value(1, 0xFF), nil
+	case "SE V,V":
+		return 0x5000 | a.x(0) | a.y(1), nil
+	case "LD V,n":
+		return 0x6000 | a.x(0) | a.value(1, 0xFF), nil
+	case "ADD V,n":
+		return 0x7000 | a.x(0) | a.value(1, 0xFF), nil
+	case "LD V,V":
+		return 0x8000 | a.x(0) | a.y(1), nil
+	case "OR V,V":
+		return 0x8001 | a.x(0) | a.y(1), nil
+	case "AND V,V":
+		return 0x8002 | a.x(0) | a.y(1), nil
+	case "XOR V,V":
+		return 0x8003 | a.x(0) | a.y(1), nil
+	case "ADD V,V":
+		return 0x8004 | a.x(0) | a.y(1), nil
+	case "SUB V,V":
+		return 0x8005 | a.x(0) | a.y(1), nil
+	case "SHR V":
+		return 0x8006 | a.x(0) | a.x(0)>>4, nil
+	case "SHR V,V":
+		return 0x8006 | a.x(0) | a.y(1), nil
+	case "SUBN V,V":
+		return 0x8007 | a.x(0) | a.y(1), nil
+	case "SHL V":
+		return 0x800E | a.x(0) | a.x(0)>>4, nil
+	case "SHL V,V":
+		return 0x800E | a.x(0) | a.y(1), nil
+	case "SNE V,V":
+		return 0x9000 | a.x(0) | a.y(1), nil
+	case "LD I,n":
+		return 0xA000 | a.value(1, 0xFFF), nil
+	case "JP V,n":
+		if a.x(0) != 0 {
+			return 0, fmt.Errorf("jump offset register must be V0, not \"%s\"", a.operands[0])
+		}
+		return 0xB000 | a.value(1, 0xFFF), nil
+	case "RND V,n":
+		return 0xC000 | a.x(0) | a.value(1, 0xFF), nil
+	case "DRW V,V,n":
+		return 0xD000 | a.x(0) | a.y(1) | a.value(2, 0xF), nil
+	case "SKP V":
+		return 0xE09E | a.x(0), nil
+	case "SKNP V":
+		return 0xE0A1 | a.x(0), nil
+	case "LD V,DT":
+		return 0xF007 | a.x(0), nil
+	case "LD V,K":
+		return 0xF00A | a.x(0), nil
+	case "LD DT,V":
+		return 0xF015 | a.x(1), nil
+	case "LD ST,V":
+		return 0xF018 | a.x(1), nil
+	case "ADD I,V":
+		return 0xF01E | a.x(1), nil
+	case "LD F,V":
+		return 0xF029 | a.x(1), nil
+	case "LD B,V":
+		return 0xF033 | a.x(1), nil
+	case "LD [I],V":
+		return 0xF055 | a.x(1), nil
+	case "LD V,[I]":
+		return 0xF065 | a.x(0), nil
+	}
+
+	return 0, fmt.Errorf("unknown instruction \"%s %s\"", mnemonic, strings.Join(a.operands, ", "))
+}
+
+// operandKind is "V" for registers, the name of special operands (like "I" and "DT"), and otherwise "n" for numbers and labels.
+func operandKind(operand string) string {
+	upperOperand := strings.ToUpper(operand)
+	switch upperOperand {
+	case "I", "DT", "ST", "K", "F", "B", "[I]":
+		return upperOperand
+	}
+	if (len(upperOperand) == 2) && (upperOperand[0] == 'V') && strings.ContainsRune("0123456789ABCDEF", rune(upperOperand[1])) {
+		return "V"
+	}
+	return "n"
+}
+
+// x is the register of the operand in the X position of the instruction
+func (a *statementAssembler) x(operandIndex int) uint16 {
+	register, _ := strconv.ParseUint(a.operands[operandIndex][1:], 16, 8)
+	return uint16(register) << 8
+}
+
+// y is the register of the operand in the Y position of the instruction
+func (a *statementAssembler) y(operandIndex int) uint16 {
+	return a.x(operandIndex) >> 4
+}
+
+// value is the number or label address of the operand, which must not be larger than the maximum value
+func (a *statementAssembler) value(operandIndex int, maxValue uint16) uint16 {
+	operand := a.operands[operandIndex]
+
+	value, defined := a.labels[strings.ToLower(operand)]
+	if !defined {
+		text, base := operand, 10
+		switch {
+		case strings.HasPrefix(strings.ToLower(text), "0x"):
+			text, base = text[2:], 16
+		case strings.HasPrefix(text, "#"):
+			text, base = text[1:], 16
+		case strings.HasPrefix(strings.ToLower(text), "0b"):
+			text, base = text[2:], 2
+		}
+
+		number, err := strconv.ParseUint(text, base, 16)
+		if err != nil {
+			if isAssemblerLabel(operand) {
+				a.setErr(fmt.Errorf("undefined label \"%s\"", operand))
+			} else {
+				a.setErr(fmt.Errorf("illegal number \"%s\"", operand))
+			}
+			return 0
+		}
+		value = uint16(number)
+	}
+
+	if value > maxValue {
+		a.setErr(fmt.Errorf("value \"%s\" is larger than 0x%X", operand, maxValue))
+		return 0
+	}
+	return value
+}
+
+func (a *statementAssembler) setErr(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+func isAssemblerLabel(label string) bool {
+	if (label == "") || ((label[0] >= '0') && (label[0] <= '9')) {
+		return false
+	}
+	for _, r := range label {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || (r == '_')) {
+			return false
+		}
+	}
+	return operandKind(label) == "n"
+}
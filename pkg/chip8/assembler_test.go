@@ -0,0 +1,88 @@
+package chip8
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	source := `
+; Draws a sprite and loops forever
+start:  CLS
+        LD   I, sprite
+        LD   V0, 0x0C
+        LD   v1, #08
+        DRW  V0, V1, 2
+        SHR  VA
+        LD   [I], V3
+        LD   V3, [I]
+        JP   V0, start
+end:    JP   end          ; Infinite loop
+sprite: DB   0b11110000, 0x90
+        DW   0x1234
+`
+	expectedROM := []byte{
+		0x00, 0xE0,
+		0xA2, 0x14,
+		0x60, 0x0C,
+		0x61, 0x08,
+		0xD0, 0x12,
+		0x8A, 0xA6,
+		0xF3, 0x55,
+		0xF3, 0x65,
+		0xB2, 0x00,
+		0x12, 0x12,
+		0xF0, 0x90,
+		0x12, 0x34,
+	}
+
+	rom, err := Assemble(source, romAddressDefault)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(rom, expectedROM) {
+		t.Errorf("expected ROM\n% X\ngot\n% X", expectedROM, rom)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	illegalSources := map[string]string{
+		"LD V0, 256":      "line 1: value \"256\" is larger than 0xFF",
+		"\nJP nowhere":    "line 2: undefined label \"nowhere\"",
+		"MOV V0, V1":      "line 1: unknown instruction \"MOV V0, V1\"",
+		"a: CLS\na: RET":  "line 2: label \"a\" is already defined",
+		"DRW V0, V1, 0x1": "",
+	}
+
+	for source, expectedError := range illegalSources {
+		_, err := Assemble(source, romAddressDefault)
+		if (expectedError == "") && (err != nil) {
+			t.Errorf("unexpected error for \"%s\": %s", source, err)
+		} else if (expectedError != "") && ((err == nil) || !strings.Contains(err.Error(), expectedError)) {
+			t.Errorf("expected error \"%s\" for \"%s\", got %v", expectedError, source, err)
+		}
+	}
+}
+
+func TestAssembleMnemonics(t *testing.T) {
+	for _, pattern := range instructionPatterns {
+		if (pattern.set != InstructionSetChip8) && (pattern.set != InstructionSetMachineCode) {
+			continue
+		}
+
+		code := pattern.value | (0x0ABC &^ pattern.mask)
+		instruction, _ := DecodeInstruction(code)
+		if instruction.Opcode == "BNNN" {
+			code &= 0xF0FF // The assembler only knows the jump offset register V0
+			instruction, _ = DecodeInstruction(code)
+		}
+
+		rom, err := Assemble(instruction.Mnemonic(), romAddressDefault)
+		if err != nil {
+			t.Errorf("unexpected error assembling \"%s\": %s", instruction.Mnemonic(), err)
+		} else if !bytes.Equal(rom, []byte{uint8(code >> 8), uint8(code)}) {
+			t.Errorf("expected \"%s\" assembled to %04X, got % X", instruction.Mnemonic(), code, rom)
+		}
+	}
+}
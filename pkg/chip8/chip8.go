@@ -1,6 +1,7 @@
 package chip8
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -12,6 +13,14 @@ const romAddressDefault = 0x200
 const romAddressEti660 = 0x600
 const fontAddressDefault = 0x050
 const flagRegisterIndex = 0xF
+const frameDuration = time.Second / 60 // 60 Hz
+const cyclesPerFrameDefault = 6        // Roughly 360 instructions per second
+
+// ErrInfiniteLoop is returned by Step when a jump to the jump instruction itself is detected (and configured to end execution)
+var ErrInfiniteLoop = errors.New("infinite loop detected")
+
+// ErrUnsupportedInstruction is returned by Step when an instruction can not be executed by the interpreter
+var ErrUnsupportedInstruction = errors.New("unsupported instruction")
 
 type Configuration struct {
 	Debug                 bool // Debug mode prints, in more or less natural language, the instructions performed during the program execution
@@ -20,8 +29,23 @@ type Configuration struct {
 	EndOnInfiniteLoop     bool // EndOnInfiniteLoop ends the program if an infinite loop is detected (some program ends with infinite loop and require restart to run again)
 	RestartOnInfiniteLoop bool // RestartOnInfiniteLoop restarts the program if an infinite loop is detected (some program ends with infinite loop and require restart to run again)
 
+	QuirkKeyWaitRelease    bool // QuirkKeyWaitRelease makes FX0A wait for a key to be pressed and released again (as on the COSMAC VIP), not just pressed
+	QuirkDisplayWait       bool // QuirkDisplayWait makes DXYN wait for the vertical blank, ending the frame, which limits sprite draws to 60 per second (as on the COSMAC VIP)
+	QuirkWrapSprites       bool // QuirkWrapSprites makes sprite pixels beyond the right or bottom screen edge wrap around to the opposite edge, instead of being clipped (clipped as on the COSMAC VIP)
+	QuirkCollisionRowCount bool // QuirkCollisionRowCount makes DXYN set VF to the number of sprite rows colliding or clipped at the bottom edge, instead of 1 for any collision (as on SCHIP)
+
 	Disassemble          bool // Disassemble do execute the ROM program but rather prints it to stdout with, more or less, natural language explanation to each instruction
 	DisassembleEveryByte bool // DisassembleEveryByte try to disassemble instructions at all bytes not just at even addresses. Some programs have parts of the code based at uneven addresses.
+
+	CyclesPerFrame int    // CyclesPerFrame is the number of instructions executed for every 60 Hz frame (0 for default)
+	Frames         uint64 // Frames ends the program execution after the given number of frames (0 for no limit)
+	Headless       bool   // Headless runs the frames as fast as possible instead of in real time, for deterministic batch execution
+	Seed           int64  // Seed is the seed of the CXNN random numbers of headless execution (see Chip8.SeedRandom)
+
+	ScreenshotAfter    uint64  // ScreenshotAfter writes a screenshot of the screen after the given number of executed instructions (0 for no screenshot)
+	ScreenshotFilepath string  // ScreenshotFilepath is the file path of the PNG screenshot
+	ScreenshotScale    int     // ScreenshotScale is the size in image pixels of every screen pixel in the screenshot
+	Palette            Palette // Palette is the colors used for screenshots (nil for default black and white)
 }
 
 type Chip8 struct {
@@ -32,21 +56,59 @@ type Chip8 struct {
 	Timer            uint8
 	SoundTimer       uint8
 	V                []uint8
+	Cycles           uint64 // Cycles is the number of executed instructions
+	Frame            uint64 // Frame is the number of the current 60 Hz frame
 	fontStartAddress uint16
+	loadAddress      uint16 // loadAddress is the memory address ROMs are loaded at
+	startAddress     uint16 // startAddress is the memory address execution starts at
+	rom              []byte // rom is the loaded ROM, reloaded on restart
 	peripherals      *Peripherals
+	screenChanged    bool // screenChanged is set when the screen is drawn, the screen is sent to the peripherals at most once every frame
+	displayWaiting   bool // displayWaiting is set when a sprite is drawn with the display wait quirk, no more instructions are executed until the next frame
+	frameListeners   []func(frame uint64, screen *ScreenBuffer)
+	inputMovie       *InputMovie
+
+	keyEventListeners []func(keyEvent KeyEvent)
+	keyEventQueue     []KeyEvent // keyEventQueue is the key events not yet consumed by a waiting FX0A
+	keyWaiting        bool       // keyWaiting is true while FX0A waits for a key
+	keyWaitKey        int        // keyWaitKey is the key pressed while FX0A waits for its release, keyWaitNone if no key is pressed yet
+
+	tracer      *Tracer
+	traceWrites []TraceMemoryWrite // traceWrites is the memory writes of the traced instruction
+	profiler    *Profiler
+	coverage    *Coverage
+	random      *rand.Rand // random is the source of CXNN random numbers, nil for the shared source
+
+	instructionCache          []decodedInstruction // instructionCache is the decoded instruction at every address executed
+	memoryMarks               []uint8              // memoryMarks marks every byte of memory executed, written and modified at runtime
+	memoryWriters             map[uint16]uint16    // memoryWriters is the address of the instruction last writing every byte written at runtime
+	executingAddress          uint16               // executingAddress is the address of the executing instruction
+	selfModifications         []SelfModification
+	selfModificationListeners []func(selfModification SelfModification)
 }
 
+// NewChip8 creates a COSMAC VIP machine.
 func NewChip8(peripherals *Peripherals) *Chip8 {
+	return NewChip8ForMachine(peripherals, DefaultMachine())
+}
+
+// NewChip8ForMachine creates a machine with the memory layout of the machine, ROMs are loaded and executed at its addresses.
+func NewChip8ForMachine(peripherals *Peripherals, machine Machine) *Chip8 {
 	chip8 := Chip8{
-		Memory:           make([]byte, 0xFFF+1), // 4kB of memory (0x000-0xFFF)
-		PC:               romAddressDefault,
+		Memory:           make([]byte, machine.MemorySize),
+		PC:               machine.StartAddress,
 		I:                0,
-		Stack:            newStack(12), // Original RCA 1802 implementation had 12 levels of nesting
+		Stack:            newStack(machine.StackDepth),
 		Timer:            0,
 		SoundTimer:       0,
 		V:                make([]uint8, 0xF+1), // 16 registers of 8 bit each. Named V0,V1,..,V9,VA,..,VF
-		fontStartAddress: fontAddressDefault,
+		fontStartAddress: machine.FontAddress,
+		loadAddress:      machine.LoadAddress,
+		startAddress:     machine.StartAddress,
 		peripherals:      peripherals,
+		instructionCache: make([]decodedInstruction, machine.MemorySize),
+		memoryMarks:      make([]uint8, machine.MemorySize),
+		memoryWriters:    map[uint16]uint16{},
 	}
 
 	addFont(chip8)
@@ -54,298 +116,520 @@ func NewChip8(peripherals *Peripherals) *Chip8 {
 	return &chip8
 }
 
-func (chip8 *Chip8) Run(configuration Configuration) {
-	var err error
+// Run executes the program, frame by frame, until the configured number of frames is reached (if any) or the program ends.
+// The returned error is ErrInfiniteLoop if the program ended in a detected infinite loop, nil if the configured number
+// of frames was executed or a frontend requested to quit (see Peripherals.RequestQuit), or the error trapped by Step.
+func (chip8 *Chip8) Run(configuration Configuration) error {
+	frameTicker := time.NewTicker(frameDuration)
+	defer frameTicker.Stop()
+
+	if configuration.Headless && (chip8.random == nil) {
+		chip8.SeedRandom(configuration.Seed)
+	}
 
-	go timerCounter(chip8)
+	for (configuration.Frames == 0) || (chip8.Frame < configuration.Frames) {
+		err := chip8.runFrame(configuration)
+		if errors.Is(err, ErrInfiniteLoop) && configuration.RestartOnInfiniteLoop {
+			chip8.restart()
+			chip8.endFrame()
+			err = nil
+		}
 
-	for true {
-		// Processor stage: Fetch
+		if (err != nil) || chip8.peripherals.QuitRequested() {
+			chip8.peripherals.stateLock.Lock()
+			chip8.peripherals.state.sound = false
+			chip8.peripherals.state.keys = 0b0000000000000000
+			chip8.peripherals.stateLock.Unlock()
+			chip8.UpdateSoundAndKeys()
 
-		time.Sleep(time.Duration(3) * time.Millisecond)
+			return err
+		}
 
-		// Chip8 is big endian
-		instructionCode := uint16(chip8.Memory[chip8.PC])<<8 | uint16(chip8.Memory[chip8.PC+1])
-		if configuration.Debug {
-			printInstructionDebugInfo(chip8.PC, instructionCode, configuration)
+		if !configuration.Headless {
+			<-frameTicker.C
 		}
+	}
 
-		// Processor stage: Decode(-ish)
+	return nil
+}
 
-		instructionType := uint8((instructionCode & 0xF000) >> 12)
-		x := uint8((instructionCode & 0x0F00) >> 8)
-		y := uint8((instructionCode & 0x00F0) >> 4)
-		z := uint8((instructionCode & 0x000F) >> 0)
-		n := uint8(instructionCode & 0x000F)
-		nn := uint8(instructionCode & 0x00FF)
-		nnn := instructionCode & 0x0FFF
+// runFrame executes the instructions of one frame and then ends the frame (counts down timers and notifies frame listeners).
+func (chip8 *Chip8) runFrame(configuration Configuration) error {
+	if chip8.inputMovie != nil {
+		if keys, changed := chip8.inputMovie.keysAt(chip8.Frame); changed {
+			chip8.peripherals.UpdateKeys(keys)
+		}
+	}
+	chip8.takeKeyEvents()
 
-		// Processor stage: Execute
+	cyclesPerFrame := configuration.CyclesPerFrame
+	if cyclesPerFrame <= 0 {
+		cyclesPerFrame = cyclesPerFrameDefault
+	}
 
-		chip8.PC += 2
+	for cycle := 0; cycle < cyclesPerFrame; cycle++ {
+		err := chip8.Step(configuration)
 
-		switch instructionType {
-		case 0x0:
-			if nnn == 0x0EE {
-				// 00EE: Return from a subroutine
-				chip8.PC, err = chip8.Stack.Pop()
-				if err != nil {
-					fmt.Printf("Error returning from subroutine (popping return address): %s\n", err.Error())
-				}
-			} else if nnn == 0x0E0 {
-				// 00E0: Clear screen
-				chip8.peripherals.state.screen.Clear()
-				go chip8.UpdateScreen()
+		if (configuration.ScreenshotAfter > 0) && (chip8.Cycles == configuration.ScreenshotAfter) {
+			if err := chip8.Screenshot(configuration.ScreenshotFilepath, configuration.ScreenshotScale, configuration.Palette); err != nil {
+				fmt.Println(err.Error())
 			} else {
-				fmt.Println("Machine code execution not available/not implemented")
-				os.Exit(1)
+				fmt.Printf("Wrote screenshot \"%s\" after %d instructions\n", configuration.ScreenshotFilepath, chip8.Cycles)
 			}
+		}
 
-		case 0x1:
-			// 1NNN: Jump to address NNN
-			if configuration.EndOnInfiniteLoop && ((chip8.PC - 2) == nnn) {
-				fmt.Println("Terminated emulator and program on detected infinite loop")
+		if err != nil {
+			return err
+		}
+
+		if chip8.displayWaiting {
+			chip8.displayWaiting = false
+			break // Wait for the vertical blank, the start of the next frame
+		}
+	}
 
-				chip8.peripherals.state.sound = false
-				chip8.peripherals.state.keys = 0b0000000000000000
-				chip8.UpdateSoundAndKeys()
+	chip8.endFrame()
 
-				os.Exit(0)
-			}
+	return nil
+}
 
-			chip8.PC = nnn
+// endFrame counts down the timers (at 60 Hz), updates the screen if changed during the frame and notifies the frame listeners.
+func (chip8 *Chip8) endFrame() {
+	if chip8.screenChanged {
+		chip8.screenChanged = false
+		chip8.UpdateScreen()
+	}
+
+	if chip8.Timer > 0 {
+		chip8.Timer--
+	}
+	if chip8.SoundTimer > 0 {
+		chip8.SoundTimer--
+	}
+
+	chip8.UpdateSound(chip8.SoundTimer > 0)
+
+	for _, frameListener := range chip8.frameListeners {
+		frameListener(chip8.Frame, &chip8.peripherals.state.screen)
+	}
+
+	chip8.Frame++
+}
+
+// AddFrameListener adds a listener that is called at the end of every frame with the frame number and the screen content.
+func (chip8 *Chip8) AddFrameListener(frameListener func(frame uint64, screen *ScreenBuffer)) {
+	chip8.frameListeners = append(chip8.frameListeners, frameListener)
+}
+
+// PlayInputMovie sets the key states of the keypad frame by frame from the input movie during execution.
+func (chip8 *Chip8) PlayInputMovie(inputMovie *InputMovie) {
+	chip8.inputMovie = inputMovie
+}
+
+// SetProfiler sets the profiler counting the executed instructions (nil for no profiling).
+func (chip8 *Chip8) SetProfiler(profiler *Profiler) {
+	chip8.profiler = profiler
+}
+
+// SetCoverage sets the coverage marking the executed instructions and the data read and written (nil for no coverage).
+func (chip8 *Chip8) SetCoverage(coverage *Coverage) {
+	chip8.coverage = coverage
+}
+
+// SeedRandom makes the CXNN random numbers the same sequence on every execution with the seed, as for recordings.
+// Without a seed, headless execution is seeded with the configured seed and real time execution uses the shared source.
+func (chip8 *Chip8) SeedRandom(seed int64) {
+	chip8.random = rand.New(rand.NewSource(seed))
+}
+
+// SetTracer sets the tracer writing a trace record of every executed instruction (nil for no tracing).
+func (chip8 *Chip8) SetTracer(tracer *Tracer) {
+	chip8.tracer = tracer
+}
+
+// Step executes a single instruction at the program counter.
+//
+// Memory accesses outside the memory range wrap around to the start of memory (as does the program counter).
+// Operations that can not be carried out (stack overflow/underflow, machine code execution, unknown instructions)
+// are trapped and returned as an error, leaving the program counter pointing at the instruction after the trapped one.
+func (chip8 *Chip8) Step(configuration Configuration) error {
+	if (chip8.tracer == nil) && (chip8.profiler == nil) && (chip8.coverage == nil) {
+		err := chip8.step(configuration)
+		if len(chip8.selfModifications) > 0 {
+			chip8.reportSelfModifications(configuration)
+		}
+		return err
+	}
 
-		case 0x2:
-			// 2NNN: Jump to subroutine (see also 00EE)
-			err := chip8.Stack.Push(chip8.PC)
+	return chip8.stepInstrumented(configuration)
+}
+
+// stepInstrumented executes a single instruction, traced, profiled and covered as set up
+func (chip8 *Chip8) stepInstrumented(configuration Configuration) error {
+	pc := chip8.memoryAddress(chip8.PC)
+	instructionCode := chip8.fetch(pc)
+
+	var record TraceRecord
+	if chip8.tracer != nil {
+		instruction, _ := DecodeInstruction(instructionCode)
+		record = TraceRecord{
+			Cycle:    chip8.Cycles,
+			Frame:    chip8.Frame,
+			PC:       pc,
+			Opcode:   instructionCode,
+			Mnemonic: instruction.Mnemonic(),
+			I:        chip8.I,
+			Keys:     chip8.peripherals.pressedKeys(),
+		}
+		copy(record.V[:], chip8.V)
+		chip8.traceWrites = nil
+	}
+
+	err := chip8.step(configuration)
+
+	if len(chip8.selfModifications) > 0 {
+		chip8.reportSelfModifications(configuration)
+	}
+
+	if chip8.profiler != nil {
+		chip8.profiler.count(pc, instructionCode, chip8.PC, chip8.Timer)
+	}
+
+	if chip8.coverage != nil {
+		instruction, _ := DecodeInstruction(instructionCode)
+		chip8.coverage.executed(pc, instruction.Size())
+	}
+
+	if chip8.tracer != nil {
+		copy(record.VAfter[:], chip8.V)
+		record.IAfter = chip8.I
+		record.Writes = chip8.traceWrites
+		chip8.tracer.trace(&record)
+	}
+
+	return err
+}
+
+// step executes a single instruction at the program counter, see Step.
+func (chip8 *Chip8) step(configuration Configuration) error {
+	// Processor stage: Fetch and decode(-ish), from the instruction cache if decoded before
+
+	chip8.PC = chip8.memoryAddress(chip8.PC)
+
+	decoded, cached := chip8.decode(chip8.PC)
+	chip8.executingAddress = chip8.PC
+	if !cached {
+		chip8.markExecuted(chip8.PC)
+	}
+	if configuration.Debug {
+		printInstructionDebugInfo(chip8.PC, decoded.code, configuration)
+	}
+
+	instructionCode := decoded.code
+	instructionType := decoded.instructionType
+	x, y, z := decoded.x, decoded.y, decoded.n
+	n, nn, nnn := decoded.n, decoded.nn, decoded.nnn
+
+	// Processor stage: Execute
+
+	chip8.PC += 2
+	chip8.Cycles++
+
+	switch instructionType {
+	case 0x0:
+		if nnn == 0x0EE {
+			// 00EE: Return from a subroutine
+			returnAddress, err := chip8.Stack.Pop()
 			if err != nil {
-				fmt.Printf("Error jumping to subroutine (pushing return address): %s\n", err.Error())
+				return fmt.Errorf("error returning from subroutine (popping return address) at address 0x%03X: %w", chip8.PC-2, err)
 			}
-			chip8.PC = nnn
+			chip8.PC = returnAddress
+		} else if nnn == 0x0E0 {
+			// 00E0: Clear screen
+			chip8.peripherals.stateLock.Lock()
+			chip8.peripherals.state.screen.Clear()
+			chip8.peripherals.stateLock.Unlock()
+			chip8.screenChanged = true
+		} else {
+			return fmt.Errorf("machine code execution \"0x%04X\" at address 0x%03X not available/not implemented: %w", instructionCode, chip8.PC-2, ErrUnsupportedInstruction)
+		}
 
-		case 0x3:
-			// 3XNN: Skip next instruction if register X equals NN (see also 4XNN)
-			if chip8.V[x] == nn {
-				chip8.PC += 2
-			}
+	case 0x1:
+		// 1NNN: Jump to address NNN
+		if (configuration.EndOnInfiniteLoop || configuration.RestartOnInfiniteLoop) && ((chip8.PC - 2) == nnn) {
+			return ErrInfiniteLoop
+		}
+
+		chip8.PC = nnn
+
+	case 0x2:
+		// 2NNN: Jump to subroutine (see also 00EE)
+		if err := chip8.Stack.Push(chip8.PC); err != nil {
+			return fmt.Errorf("error jumping to subroutine (pushing return address) at address 0x%03X: %w", chip8.PC-2, err)
+		}
+		chip8.PC = nnn
+
+	case 0x3:
+		// 3XNN: Skip next instruction if register X equals NN (see also 4XNN)
+		if chip8.V[x] == nn {
+			chip8.PC += 2
+		}
 
-		case 0x4:
-			// 4XNN: Skip next instruction if register X NOT equals NN (see also 3XNN)
-			if chip8.V[x] != nn {
+	case 0x4:
+		// 4XNN: Skip next instruction if register X NOT equals NN (see also 3XNN)
+		if chip8.V[x] != nn {
+			chip8.PC += 2
+		}
+
+	case 0x5:
+		if z == 0 {
+			// 5XY0: Skip next instruction if register X equals register Y (see also 9XY0)
+			if chip8.V[x] == chip8.V[y] {
 				chip8.PC += 2
 			}
+		}
 
-		case 0x5:
-			if z == 0 {
-				// 5XY0: Skip next instruction if register X equals register Y (see also 9XY0)
-				if chip8.V[x] == chip8.V[y] {
-					chip8.PC += 2
-				}
+	case 0x6:
+		// 6XNN: Set register X to value NN
+		chip8.V[x] = nn
+
+	case 0x7:
+		// 7XNN: Add the value NN to VX.
+		// NOTE: overflow flag is not affected by this instruction if result > 0xFF. If result wraps to zero when overflow i.e. VX = (VX + NN) % 0xFF.
+		chip8.V[x] += nn
+
+	case 0x8:
+		if z == 0x0 {
+			// 8XY0: Set VX to the value of VY
+			chip8.V[x] = chip8.V[y]
+		} else if z == 0x1 {
+			// 8XY1: VX is set to the bitwise/binary logical disjunction (OR) of VX and VY. VY is not affected.
+			chip8.V[x] |= chip8.V[y]
+		} else if z == 0x2 {
+			// 8XY2: VX is set to the bitwise/binary logical conjunction (AND) of VX and VY. VY is not affected.
+			chip8.V[x] &= chip8.V[y]
+		} else if z == 0x3 {
+			// 8XY3: VX is set to the bitwise/binary exclusive OR (XOR) of VX and VY. VY is not affected.
+			chip8.V[x] ^= chip8.V[y]
+		} else if z == 0x4 {
+			// 8XY4: VX is set to the value of VX plus the value of VY. VY is not affected. Carry flag in register VF is set if overflow
+			result := uint16(chip8.V[x]) + uint16(chip8.V[y])
+			if result > 0xFF {
+				chip8.V[flagRegisterIndex] = 1
+			} else {
+				chip8.V[flagRegisterIndex] = 0
+			}
+			chip8.V[x] = uint8(result % 0x100)
+		} else if z == 0x5 {
+			// 8XY5: subtract VY from VX and put the result in VX. VY is not affected.
+			if chip8.V[x] > chip8.V[y] {
+				chip8.V[flagRegisterIndex] = 1
+			} else {
+				chip8.V[flagRegisterIndex] = 0
+			}
+			chip8.V[x] = chip8.V[x] - chip8.V[y]
+		} else if z == 0x6 {
+			// 8XY6: (Strict COSMAC: Copy VY to VX and) shift VX 1 bit to the right. VF is set to the bit that was shifted out.
+			if configuration.ModeStrictCosmac {
+				chip8.V[x] = chip8.V[y]
 			}
+			chip8.V[flagRegisterIndex] = (chip8.V[x] & 0b00000001) >> 0
+			chip8.V[x] = chip8.V[x] >> 1
+		} else if z == 0x7 {
+			// 8XY7: subtract VX from VY and put the result in VX. VY is not affected.
+			if chip8.V[y] > chip8.V[x] {
+				chip8.V[flagRegisterIndex] = 1
+			} else {
+				chip8.V[flagRegisterIndex] = 0
+			}
+			chip8.V[x] = chip8.V[y] - chip8.V[x]
+		} else if z == 0xE {
+			// 8XYE: (Strict COSMAC: Copy VY to VX and) shift VX 1 bit to the left. VF is set to the bit that was shifted out.
+			if configuration.ModeStrictCosmac {
+				chip8.V[x] = chip8.V[y]
+			}
+			chip8.V[flagRegisterIndex] = (chip8.V[x] & 0b10000000) >> 7
+			chip8.V[x] = chip8.V[x] << 1
+		}
 
-		case 0x6:
-			// 6XNN: Set register X to value NN
-			chip8.V[x] = nn
+	case 0x9:
+		if z == 0x0 {
+			// 9XY0: Skip next instruction if register X NOT equals register Y (see also 5XY0)
+			if chip8.V[x] != chip8.V[y] {
+				chip8.PC += 2
+			}
+		}
 
-		case 0x7:
-			// 7XNN: Add the value NN to VX.
-			// NOTE: overflow flag is not affected by this instruction if result > 0xFF. If result wraps to zero when overflow i.e. VX = (VX + NN) % 0xFF.
-			chip8.V[x] += nn
+	case 0xA:
+		// ANNN: Sets the index register I to the value NNN.
+		chip8.I = nnn
+
+	case 0xB:
+		if configuration.ModeStrictCosmac || configuration.ModeRomCompatibility {
+			// BNNN: Jump to the address NNN plus the value in the register V0.
+			chip8.PC = nnn + uint16(chip8.V[0x0])
+		} else {
+			// B(X)NNN: Jump to the address NNN plus the value in the register VX.
+			chip8.PC = nnn + uint16(chip8.V[x])
+		}
 
-		case 0x8:
-			if z == 0x0 {
-				// 8XY0: Set VX to the value of VY
-				chip8.V[x] = chip8.V[y]
-			} else if z == 0x1 {
-				// 8XY1: VX is set to the bitwise/binary logical disjunction (OR) of VX and VY. VY is not affected.
-				chip8.V[x] |= chip8.V[y]
-			} else if z == 0x2 {
-				// 8XY2: VX is set to the bitwise/binary logical conjunction (AND) of VX and VY. VY is not affected.
-				chip8.V[x] &= chip8.V[y]
-			} else if z == 0x3 {
-				// 8XY3: VX is set to the bitwise/binary exclusive OR (XOR) of VX and VY. VY is not affected.
-				chip8.V[x] ^= chip8.V[y]
-			} else if z == 0x4 {
-				// 8XY4: VX is set to the value of VX plus the value of VY. VY is not affected. Carry flag in register VF is set if overflow
-				result := uint16(chip8.V[x]) + uint16(chip8.V[y])
-				if result > 0xFF {
-					chip8.V[flagRegisterIndex] = 1
-				} else {
-					chip8.V[flagRegisterIndex] = 0
-				}
-				chip8.V[x] = uint8(result % 0x100)
-			} else if z == 0x5 {
-				// 8XY5: subtract VY from VX and put the result in VX. VY is not affected.
-				if chip8.V[x] > chip8.V[y] {
-					chip8.V[flagRegisterIndex] = 1
-				} else {
-					chip8.V[flagRegisterIndex] = 0
-				}
-				chip8.V[x] = chip8.V[x] - chip8.V[y]
-			} else if z == 0x6 {
-				// 8XY6: (Strict COSMAC: Copy VY to VX and) shift VX 1 bit to the right. VF is set to the bit that was shifted out.
-				if configuration.ModeStrictCosmac {
-					chip8.V[x] = chip8.V[y]
-				}
-				chip8.V[flagRegisterIndex] = (chip8.V[x] & 0b00000001) >> 0
-				chip8.V[x] = chip8.V[x] >> 1
-			} else if z == 0x7 {
-				// 8XY7: subtract VX from VY and put the result in VX. VY is not affected.
-				if chip8.V[y] > chip8.V[x] {
-					chip8.V[flagRegisterIndex] = 1
-				} else {
-					chip8.V[flagRegisterIndex] = 0
-				}
-				chip8.V[x] = chip8.V[y] - chip8.V[x]
-			} else if z == 0xE {
-				// 8XYE: (Strict COSMAC: Copy VY to VX and) shift VX 1 bit to the left. VF is set to the bit that was shifted out.
-				if configuration.ModeStrictCosmac {
-					chip8.V[x] = chip8.V[y]
+	case 0xC:
+		// CXNN: Generates a random number, binary ANDs it with the value NN, and puts the result in VX.
+		random := rand.Uint32
+		if chip8.random != nil {
+			random = chip8.random.Uint32
+		}
+		chip8.V[x] = uint8(random()&0x000000FF) & nn
+
+	case 0xD:
+		// DXYN: Draw an N pixels tall sprite from the memory location that the I-index register is holding to the screen,
+		// at the horizontal X coordinate in VX and the Y coordinate in VY.
+		// The start position wraps around the screen. Sprite pixels beyond the right or bottom edge are clipped, or wrap around (quirk).
+		// The screen is locked while drawing, frontends serving viewers take snapshots of it on their own goroutines.
+		chip8.peripherals.stateLock.Lock()
+		screen := &chip8.peripherals.state.screen
+		pixelX := int(chip8.V[x] % screen.Width)
+		pixelY := int(chip8.V[y] % screen.Height)
+
+		collidedRows := uint8(0) // collidedRows is the number of sprite rows turning off any pixel
+		clippedRows := uint8(0)  // clippedRows is the number of sprite rows clipped at the bottom edge
+
+		for spriteY := 0; spriteY < int(n); spriteY++ {
+			screenY := pixelY + spriteY
+			if screenY >= int(screen.Height) {
+				if !configuration.QuirkWrapSprites {
+					clippedRows = n - uint8(spriteY)
+					break
 				}
-				chip8.V[flagRegisterIndex] = (chip8.V[x] & 0b10000000) >> 7
-				chip8.V[x] = chip8.V[x] << 1
+				screenY %= int(screen.Height)
 			}
 
-		case 0x9:
-			if z == 0x0 {
-				// 9XY0: Skip next instruction if register X NOT equals register Y (see also 5XY0)
-				if chip8.V[x] != chip8.V[y] {
-					chip8.PC += 2
+			pixelBitValues := chip8.readMemory(chip8.I + uint16(spriteY))
+			rowCollided := false
+			for spriteX := 0; spriteX < 8; spriteX++ {
+				screenX := pixelX + spriteX
+				if screenX >= int(screen.Width) {
+					if !configuration.QuirkWrapSprites {
+						break
+					}
+					screenX %= int(screen.Width)
 				}
-			}
 
-		case 0xA:
-			// ANNN: Sets the index register I to the value NNN.
-			chip8.I = nnn
-
-		case 0xB:
-			if configuration.ModeStrictCosmac || configuration.ModeRomCompatibility {
-				// BNNN: Jump to the address NNN plus the value in the register V0.
-				chip8.PC = nnn + uint16(chip8.V[0x0])
-			} else {
-				// B(X)NNN: Jump to the address NNN plus the value in the register VX.
-				chip8.PC = nnn + uint16(chip8.V[x])
+				pixelValue := (pixelBitValues >> (7 - spriteX)) & 0b00000001
+				if (pixelValue == 1) && (screen.XorPixel(uint8(screenX), uint8(screenY), pixelValue) == 0) {
+					rowCollided = true
+				}
 			}
 
-		case 0xC:
-			// CXNN: Generates a random number, binary ANDs it with the value NN, and puts the result in VX.
-			chip8.V[x] = uint8(rand.Uint32()&0x000000FF) & nn
+			if rowCollided {
+				collidedRows++
+			}
+		}
+		chip8.peripherals.stateLock.Unlock()
 
-		case 0xD:
-			// DXYN: Draw an N pixels tall sprite from the memory location that the I-index register is holding to the screen,
-			// at the horizontal X coordinate in VX and the Y coordinate in VY.
-			pixelX := chip8.V[x] % chip8.peripherals.state.screen.Width
-			pixelY := chip8.V[y] % chip8.peripherals.state.screen.Height
+		if configuration.QuirkCollisionRowCount {
+			chip8.V[flagRegisterIndex] = collidedRows + clippedRows
+		} else if collidedRows > 0 {
+			chip8.V[flagRegisterIndex] = 1
+		} else {
 			chip8.V[flagRegisterIndex] = 0
+		}
 
-			for spriteY := uint8(0); spriteY < n; spriteY++ {
-				pixelBitValues := chip8.Memory[chip8.I+uint16(spriteY)]
-				for spriteX := uint8(0); spriteX < 8; spriteX++ {
-					if (spriteX < chip8.peripherals.state.screen.Width) && (spriteY < chip8.peripherals.state.screen.Height) {
-						pixelValue := (pixelBitValues >> spriteX) & 0b00000001
+		chip8.screenChanged = true
+		chip8.displayWaiting = configuration.QuirkDisplayWait
 
-						resultPixelValue := chip8.peripherals.state.screen.XorPixel(pixelX+(7-spriteX), pixelY+spriteY, pixelValue)
-						if (pixelValue == 1) && (resultPixelValue == 0) {
-							chip8.V[flagRegisterIndex] = 1
-						}
-					}
-				}
+	case 0xE:
+		if nn == 0x9E {
+			// EX9E: Skip next instruction if key denoted by VX is pressed at the moment
+			if chip8.isKeyPressed(chip8.V[x]) {
+				chip8.PC += 2
 			}
+		} else if nn == 0xA1 {
+			// EXA1: Skip next instruction if key denoted by VX is NOT pressed at the moment
+			if !chip8.isKeyPressed(chip8.V[x]) {
+				chip8.PC += 2
+			}
+		}
 
-			go chip8.UpdateScreen()
-
-		case 0xE:
-			if nn == 0x9E {
-				// EX9E: Skip next instruction if key denoted by VX is pressed at the moment
-				if chip8.isKeyPressed(chip8.V[x]) {
-					chip8.PC += 2
-				}
-			} else if nn == 0xA1 {
-				// EXA1: Skip next instruction if key denoted by VX is NOT pressed at the moment
-				if !chip8.isKeyPressed(chip8.V[x]) {
-					chip8.PC += 2
+	case 0xF:
+		if nn == 0x07 {
+			// FX07: Sets VX to the current value of the delay timer
+			chip8.V[x] = chip8.Timer
+		} else if nn == 0x15 {
+			// FX15: Sets the delay timer to the value in VX
+			chip8.Timer = chip8.V[x]
+		} else if nn == 0x18 {
+			// FX18: Sets the sound timer to the value in VX
+			chip8.UpdateSound(chip8.V[x] > 0)
+			chip8.SoundTimer = remappedSoundValue(chip8.V[x])
+			//fmt.Printf("Sound on (value %d, sound timer set to %d, %d msec)\n", chip8.V[x], chip8.SoundTimer, int(math.Round(float64(chip8.SoundTimer)*1000.0/60.0)))
+		} else if nn == 0x1E {
+			// FX1E: Add to index. The index register I will get the value in VX added to it.
+			result := chip8.I + uint16(chip8.V[x])
+
+			if !configuration.ModeStrictCosmac {
+				if int(result) >= len(chip8.Memory) {
+					// Register I would point outside memory range
+					chip8.V[flagRegisterIndex] = 1
+				} else {
+					chip8.V[flagRegisterIndex] = 0
 				}
 			}
-
-		case 0xF:
-			if nn == 0x07 {
-				// FX07: Sets VX to the current value of the delay timer
-				chip8.V[x] = chip8.Timer
-			} else if nn == 0x15 {
-				// FX15: Sets the delay timer to the value in VX
-				chip8.Timer = chip8.V[x]
-			} else if nn == 0x18 {
-				// FX18: Sets the sound timer to the value in VX
-				chip8.UpdateSound(chip8.V[x] > 0)
-				chip8.SoundTimer = remappedSoundValue(chip8.V[x])
-				//fmt.Printf("Sound on (value %d, sound timer set to %d, %d msec)\n", chip8.V[x], chip8.SoundTimer, int(math.Round(float64(chip8.SoundTimer)*1000.0/60.0)))
-			} else if nn == 0x1E {
-				// FX1E: Add to index. The index register I will get the value in VX added to it.
-				result := chip8.I + uint16(chip8.V[x])
-
-				if !configuration.ModeStrictCosmac {
-					if result > 0xFFF {
-						// Register I would point outside memory range
-						chip8.V[flagRegisterIndex] = 1
-					} else {
-						chip8.V[flagRegisterIndex] = 0
-					}
+			chip8.I = chip8.memoryAddress(result)
+		} else if nn == 0x0A {
+			// FX0A: This instruction "blocks", it stops executing instructions and wait for key input. Value of key is stored in VX.
+			// Only keys pressed (and released, depending on quirk) while waiting count, so a held key does not skip ahead.
+			if pressedKeyCode, ended := chip8.waitForKey(configuration); ended {
+				chip8.V[x] = pressedKeyCode
+			} else {
+				chip8.PC -= 2 // Do not advance in program, do this instruction over again (loop)
+			}
+		} else if nn == 0x29 {
+			// FX29: Set index register to point at font character address. The character code is stored in VX
+			// Each character is 5 bytes in height
+			chip8.I = chip8.fontStartAddress + (uint16(chip8.V[x]) * 5)
+		} else if nn == 0x33 {
+			// FX33: Binary-coded decimal conversion
+			// It takes the number in VX and converts it to three decimal digits,
+			// storing these digits in memory at the start address in the index register I.
+			chip8.writeMemory(chip8.I+0, (chip8.V[x]/100)%10)
+			chip8.writeMemory(chip8.I+1, (chip8.V[x]/10)%10)
+			chip8.writeMemory(chip8.I+2, (chip8.V[x]/1)%10)
+		} else if nn == 0x55 {
+			// FX55: Store V registers in memory
+			// The value of each variable register from V0 to VX inclusive
+			// (if X is 0, then only V0) will be stored in successive memory addresses,
+			// starting with the one that’s pointed to by register I.
+			if configuration.ModeStrictCosmac && !configuration.ModeRomCompatibility {
+				for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
+					chip8.writeMemory(chip8.I, chip8.V[i])
+					chip8.I++
 				}
-				chip8.I = result & 0x0FFF
-			} else if nn == 0x0A {
-				// FX0A: This instruction "blocks", it stops executing instructions and wait for key input. Value of key is stored in VX.
-				pressedKeyCode := chip8.getPressedKey()
-				if pressedKeyCode != 0xFF {
-					chip8.V[x] = pressedKeyCode
-				} else {
-					chip8.PC -= 2 // Do not advance in program, do this instruction over again (loop)
+			} else {
+				for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
+					chip8.writeMemory(chip8.I+uint16(i), chip8.V[i])
 				}
-			} else if nn == 0x29 {
-				// FX29: Set index register to point at font character address. The character code is stored in VX
-				// Each character is 5 bytes in height
-				chip8.I = chip8.fontStartAddress + (uint16(chip8.V[x]) * 5)
-			} else if nn == 0x33 {
-				// FX33: Binary-coded decimal conversion
-				// It takes the number in VX and converts it to three decimal digits,
-				// storing these digits in memory at the start address in the index register I.
-				chip8.Memory[chip8.I+0] = (chip8.V[x] / 100) % 10
-				chip8.Memory[chip8.I+1] = (chip8.V[x] / 10) % 10
-				chip8.Memory[chip8.I+2] = (chip8.V[x] / 1) % 10
-			} else if nn == 0x55 {
-				// FX55: Store V registers in memory
-				// The value of each variable register from V0 to VX inclusive
-				// (if X is 0, then only V0) will be stored in successive memory addresses,
-				// starting with the one that’s pointed to by register I.
-				if configuration.ModeStrictCosmac && !configuration.ModeRomCompatibility {
-					for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
-						chip8.Memory[chip8.I] = chip8.V[i]
-						chip8.I++
-					}
-				} else {
-					for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
-						chip8.Memory[chip8.I+uint16(i)] = chip8.V[i]
-					}
+			}
+		} else if nn == 0x65 {
+			// FX65: Load registers from memory
+			// Takes the value stored at the memory addresses and loads them into the variable registers.
+			if configuration.ModeStrictCosmac && !configuration.ModeRomCompatibility {
+				for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
+					chip8.V[i] = chip8.readMemory(chip8.I)
+					chip8.I++
 				}
-			} else if nn == 0x65 {
-				// FX65: Load registers from memory
-				// Takes the value stored at the memory addresses and loads them into the variable registers.
-				if configuration.ModeStrictCosmac && !configuration.ModeRomCompatibility {
-					for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
-						chip8.V[i] = chip8.Memory[chip8.I]
-						chip8.I++
-					}
-				} else {
-					for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
-						chip8.V[i] = chip8.Memory[chip8.I+uint16(i)]
-					}
+			} else {
+				for i := uint8(0); (i <= x) && (i <= 0xF); i++ {
+					chip8.V[i] = chip8.readMemory(chip8.I + uint16(i))
 				}
 			}
-
-		default:
-			panic(fmt.Sprintf("unknown instruction \"0x%X\" at address 0x%X", instructionCode, chip8.PC-2))
 		}
 
+	default:
+		return fmt.Errorf("unknown instruction \"0x%04X\" at address 0x%03X: %w", instructionCode, chip8.PC-2, ErrUnsupportedInstruction)
 	}
+
+	return nil
 }
 
 func remappedSoundValue(soundDelay uint8) uint8 {
@@ -365,17 +649,105 @@ func (chip8 *Chip8) _loadROM(filepath string, startAddress int) {
 		os.Exit(1)
 	}
 
-	for i, romByte := range romBytes {
-		chip8.Memory[startAddress+i] = romByte
+	if err := chip8.loadROMBytes(romBytes, startAddress); err != nil {
+		fmt.Printf("could not load ROM file \"%s\": %s\n", filepath, err.Error())
+		os.Exit(1)
+	}
+}
+
+func (chip8 *Chip8) loadROMBytes(romBytes []byte, startAddress int) error {
+	if startAddress+len(romBytes) > len(chip8.Memory) {
+		return fmt.Errorf("ROM of size %d bytes does not fit in memory when loaded at address 0x%03X", len(romBytes), startAddress)
+	}
+
+	copy(chip8.Memory[startAddress:], romBytes)
+	chip8.rom = romBytes
+	chip8.InvalidateInstructionCache()
+
+	return nil
+}
+
+// restart resets the machine to its state when the ROM was loaded, keeping the frame count, and clears the screen.
+func (chip8 *Chip8) restart() {
+	for i := range chip8.Memory {
+		chip8.Memory[i] = 0x00
+	}
+	addFont(*chip8)
+	copy(chip8.Memory[chip8.loadAddress:], chip8.rom)
+	chip8.InvalidateInstructionCache()
+
+	chip8.PC = chip8.startAddress
+	chip8.I = 0
+	chip8.Stack = newStack(len(chip8.Stack.Stack))
+	chip8.Timer = 0
+	chip8.SoundTimer = 0
+	for i := range chip8.V {
+		chip8.V[i] = 0
+	}
+	chip8.keyWaiting = false
+	chip8.displayWaiting = false
+	for i := range chip8.memoryMarks {
+		chip8.memoryMarks[i] = 0
 	}
+	chip8.memoryWriters = map[uint16]uint16{}
+
+	chip8.peripherals.stateLock.Lock()
+	chip8.peripherals.state.screen.Clear()
+	chip8.peripherals.stateLock.Unlock()
+	chip8.screenChanged = true
 }
 
+// LoadROM loads the ROM at the load address of the machine.
 func (chip8 *Chip8) LoadROM(filepath string) {
-	chip8._loadROM(filepath, romAddressDefault)
+	chip8._loadROM(filepath, int(chip8.loadAddress))
 }
 
+// LoadETI660ROM loads the ROM at the ETI-660 load address, and starts execution there.
 func (chip8 *Chip8) LoadETI660ROM(filepath string) {
+	chip8.loadAddress = romAddressEti660
+	chip8.startAddress = romAddressEti660
 	chip8._loadROM(filepath, romAddressEti660)
+	chip8.PC = romAddressEti660
+}
+
+// memoryAddress wraps an address around the end of memory, any address outside memory continues from address 0x000.
+func (chip8 *Chip8) memoryAddress(address uint16) uint16 {
+	return uint16(int(address) % len(chip8.Memory))
+}
+
+// fetch reads the instruction at the address. Chip8 is big endian.
+func (chip8 *Chip8) fetch(address uint16) uint16 {
+	return uint16(chip8.Memory[chip8.memoryAddress(address)])<<8 | uint16(chip8.Memory[chip8.memoryAddress(address+1)])
+}
+
+// readMemory reads data from memory (instructions are read by fetch).
+func (chip8 *Chip8) readMemory(address uint16) byte {
+	if chip8.coverage != nil {
+		chip8.coverage.accessed(chip8.memoryAddress(address))
+	}
+	return chip8.Memory[chip8.memoryAddress(address)]
+}
+
+func (chip8 *Chip8) writeMemory(address uint16, value byte) {
+	chip8.Memory[chip8.memoryAddress(address)] = value
+	chip8.markWritten(chip8.memoryAddress(address), value)
+	chip8.invalidateInstruction(chip8.memoryAddress(address))
+	if chip8.coverage != nil {
+		chip8.coverage.accessed(chip8.memoryAddress(address))
+	}
+	if chip8.tracer != nil {
+		chip8.traceWrites = append(chip8.traceWrites, TraceMemoryWrite{Address: chip8.memoryAddress(address), Value: value})
+	}
+}
+
+// Screen is the current screen content.
+func (chip8 *Chip8) Screen() *ScreenBuffer {
+	return &chip8.peripherals.state.screen
+}
+
+// Screenshot writes the current screen content as a PNG image to file.
+func (chip8 *Chip8) Screenshot(filepath string, scale int, palette Palette) error {
+	return chip8.peripherals.state.screen.WritePNG(filepath, scale, palette)
 }
 
 func (chip8 *Chip8) UpdateScreen() {
@@ -391,23 +763,9 @@ func (chip8 *Chip8) UpdateSoundAndKeys() {
 	chip8.peripherals.UpdateSoundAndKeys()
 }
 
-func (chip8 *Chip8) getPressedKey() uint8 {
-	keySate := chip8.peripherals.state.keys
-
-	if keySate > 0 {
-		for keyIndex := uint8(0); keyIndex <= 0xF; keyIndex++ {
-			if (keySate>>keyIndex)&0x1 == 1 {
-				return keyIndex
-			}
-		}
-	}
-
-	return 0xFF // return key code of pressed key or 0xFF for "no key pressed"
-}
-
 func (chip8 *Chip8) isKeyPressed(keyCode uint8) bool {
 	// fmt.Printf("Checking for key: %1X    %016b\n", keyCode, chip8.peripherals.state.keys)
-	return (chip8.peripherals.state.keys>>keyCode)&0x1 == 1
+	return (chip8.peripherals.pressedKeys()>>keyCode)&0x1 == 1
 }
 
 func addFont(chip Chip8) {
@@ -434,18 +792,3 @@ func addFont(chip Chip8) {
 		chip.Memory[chip.fontStartAddress+uint16(i)] = b
 	}
 }
-
-func timerCounter(chip8 *Chip8) {
-	var countDownFrequency = 1000 / 60 // 60 Hz
-	for {
-		time.Sleep(time.Duration(countDownFrequency) * time.Millisecond)
-		if chip8.Timer > 0 {
-			chip8.Timer--
-		}
-		if chip8.SoundTimer > 0 {
-			chip8.SoundTimer--
-		}
-
-		chip8.UpdateSound(chip8.SoundTimer > 0)
-	}
-}
@@ -0,0 +1,175 @@
+package chip8
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const fuzzCycleBudget = 10000
+
+// FuzzStep executes arbitrary byte programs for a limited number of cycles.
+// Any program may trap (return an error from Step) but must never crash the interpreter.
+func FuzzStep(f *testing.F) {
+	f.Add([]byte{0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0xD0, 0x1F, 0x12, 0x00}) // Clear, draw sprite, loop
+	f.Add([]byte{0xAF, 0xFF, 0xD0, 0x1F, 0xF0, 0x33, 0xFF, 0x55, 0xFF, 0x65}) // Memory access at end of memory
+	f.Add([]byte{0x22, 0x00})                                                 // Endless recursion (stack overflow)
+	f.Add([]byte{0x00, 0xEE})                                                 // Return without subroutine (stack underflow)
+	f.Add([]byte{0x60, 0xFF, 0xBF, 0xFF})                                     // Jump outside memory
+
+	romFilepaths, _ := filepath.Glob(filepath.Join("..", "..", "roms", "*.ch8"))
+	for _, romFilepath := range romFilepaths {
+		if romBytes, err := os.ReadFile(romFilepath); err == nil {
+			f.Add(romBytes)
+		}
+	}
+
+	configurations := []Configuration{
+		{ModeRomCompatibility: true, EndOnInfiniteLoop: true},
+		{ModeStrictCosmac: true, EndOnInfiniteLoop: true},
+		{},
+	}
+
+	f.Fuzz(func(t *testing.T, program []byte) {
+		for _, configuration := range configurations {
+			peripherals := NewHeadlessPeripherals()
+			machine := NewChip8(&peripherals)
+			if err := machine.loadROMBytes(program, romAddressDefault); err != nil {
+				t.Skip(err)
+			}
+
+			for cycle := 0; cycle < fuzzCycleBudget; cycle++ {
+				if err := machine.Step(configuration); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestStackPushAndPopToLimit(t *testing.T) {
+	s := newStack(12)
+
+	for i := 0; i < 12; i++ {
+		if err := s.Push(uint16(i)); err != nil {
+			t.Fatalf("unexpected error pushing value %d: %s", i, err)
+		}
+	}
+
+	if err := s.Push(12); err == nil {
+		t.Fatalf("expected stack overflow when pushing beyond stack limit")
+	}
+
+	for i := 11; i >= 0; i-- {
+		value, err := s.Pop()
+		if err != nil {
+			t.Fatalf("unexpected error popping value: %s", err)
+		}
+		if value != uint16(i) {
+			t.Fatalf("expected popped value %d, got %d", i, value)
+		}
+	}
+
+	if _, err := s.Pop(); err == nil {
+		t.Fatalf("expected stack underflow when popping empty stack")
+	}
+}
+
+func TestStepMemoryAccessWrapsAroundEndOfMemory(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+
+	machine.V[0] = 123
+	machine.I = 0xFFE
+	machine.Memory[romAddressDefault+0] = 0xF0 // FX33: BCD of V0 at I, I+1, I+2
+	machine.Memory[romAddressDefault+1] = 0x33
+
+	if err := machine.Step(Configuration{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if machine.Memory[0xFFE] != 1 || machine.Memory[0xFFF] != 2 || machine.Memory[0x000] != 3 {
+		t.Fatalf("expected BCD digits 1, 2, 3 at 0xFFE, 0xFFF, 0x000, got %d, %d, %d", machine.Memory[0xFFE], machine.Memory[0xFFF], machine.Memory[0x000])
+	}
+}
+
+func TestDisplayWaitLimitsSpriteDrawsToOnePerFrame(t *testing.T) {
+	for _, displayWait := range []bool{false, true} {
+		peripherals := NewHeadlessPeripherals()
+		machine := NewChip8(&peripherals)
+		machine.loadROMBytes([]byte{0xD0, 0x05, 0x12, 0x00}, romAddressDefault) // Draw sprite, loop
+
+		configuration := Configuration{QuirkDisplayWait: displayWait, CyclesPerFrame: 6, Frames: 60, Headless: true}
+		if err := machine.Run(configuration); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		draws := (machine.Cycles + 1) / 2 // Every other instruction, starting with the first, is a draw
+		expectedDraws := uint64(180)
+		if displayWait {
+			expectedDraws = 60
+		}
+		if draws != expectedDraws {
+			t.Errorf("expected %d sprite draws in 60 frames (display wait %v), got %d", expectedDraws, displayWait, draws)
+		}
+	}
+}
+
+func TestMachineMemoryLayout(t *testing.T) {
+	for _, machineProfile := range Machines {
+		peripherals := NewHeadlessPeripherals()
+		machine := NewChip8ForMachine(&peripherals, machineProfile)
+
+		if (machine.PC != machineProfile.StartAddress) || (len(machine.Memory) != machineProfile.MemorySize) || (len(machine.Stack.Stack) != machineProfile.StackDepth) {
+			t.Errorf("machine %s: unexpected PC 0x%03X, memory size %d or stack depth %d", machineProfile.Name, machine.PC, len(machine.Memory), len(machine.Stack.Stack))
+		}
+		if machine.Memory[machineProfile.FontAddress] != 0xF0 {
+			t.Errorf("machine %s: expected font at 0x%03X", machineProfile.Name, machineProfile.FontAddress)
+		}
+	}
+
+	eti660, _ := MachineByName("eti-660")
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8ForMachine(&peripherals, eti660)
+	machine.loadROMBytes([]byte{0x60, 0x42}, int(machine.loadAddress)) // V0 = 0x42
+	if err := machine.Step(Configuration{}); (err != nil) || (machine.V[0] != 0x42) || (machine.PC != 0x602) {
+		t.Fatalf("expected ETI-660 program to execute from 0x600, got V0=0x%02X PC=0x%03X (%v)", machine.V[0], machine.PC, err)
+	}
+}
+
+func TestRestartOnInfiniteLoop(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes([]byte{0x70, 0x01, 0xA3, 0x00, 0xF0, 0x55, 0x12, 0x06}, romAddressDefault) // V0 += 1, store V0 at 0x300, loop
+
+	configuration := Configuration{RestartOnInfiniteLoop: true, CyclesPerFrame: 4, Frames: 3, Headless: true}
+	if err := machine.Run(configuration); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Every frame runs the program to its infinite loop and restarts it, V0 starting over from 0
+	if (machine.V[0] != 0) || (machine.Memory[0x300] != 0) || (machine.PC != romAddressDefault) || (machine.Frame != 3) {
+		t.Errorf("expected restarted program, got V0=%d [0x300]=%d PC=0x%03X frame %d", machine.V[0], machine.Memory[0x300], machine.PC, machine.Frame)
+	}
+}
+
+// BenchmarkRun reports the instructions per second of 10 seconds of BRIX run headless (without waiting for the frames),
+// in millions (MIPS).
+func BenchmarkRun(b *testing.B) {
+	const frames = 600
+	configuration := Configuration{ModeRomCompatibility: true, CyclesPerFrame: 1000, Frames: frames, Headless: true}
+
+	var instructions uint64
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		peripherals := NewHeadlessPeripherals()
+		machine := loadBenchmarkROM(b, &peripherals)
+		if err := machine.Run(configuration); err != nil {
+			b.Fatal(err)
+		}
+		instructions += machine.Cycles
+	}
+
+	b.ReportMetric(float64(instructions)/time.Since(start).Seconds()/1e6, "MIPS")
+}
@@ -0,0 +1,150 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFile is a shared configuration file of default settings and per-ROM settings, in JSON format.
+// Settings are named as the flags of the run command. ROM sections are matched by file name glob or by SHA-1 hash.
+//
+//	{
+//	  "display": "tty",
+//	  "keymap": "arrows",
+//	  "cycles-per-frame": 10,
+//	  "roms": [
+//	    {"match": "BRIX*.ch8", "palette": "000000,FFB000"},
+//	    {"sha1": "b232ef880bd6060fb45fa6effed7edf0ae95670e", "quirk-wrap-sprites": true}
+//	  ]
+//	}
+type ConfigFile struct {
+	Defaults ConfigSettings      // Defaults are the settings of every ROM
+	ROMs     []ConfigFileSection // ROMs are the ROM sections, in file order
+}
+
+// ConfigSettings are setting values as text, by setting name
+type ConfigSettings map[string]string
+
+// ConfigFileSection are the settings of the ROMs with a file name matching the glob, or with the SHA-1 hash.
+type ConfigFileSection struct {
+	Match    string // Match is a file name glob, like "BRIX*.ch8" (empty for no file name match)
+	SHA1     string // SHA1 is a hexadecimal SHA-1 hash of the ROM (empty for no hash match)
+	Settings ConfigSettings
+}
+
+// LoadConfigFile loads the configuration file.
+func LoadConfigFile(configFilepath string) (*ConfigFile, error) {
+	content, err := os.ReadFile(configFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file \"%s\": %w", configFilepath, err)
+	}
+
+	configFile, err := ParseConfigFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file \"%s\": %w", configFilepath, err)
+	}
+
+	return configFile, nil
+}
+
+// ParseConfigFile parses the JSON content of a configuration file.
+func ParseConfigFile(content []byte) (*ConfigFile, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	var romFields []map[string]json.RawMessage
+	if romsJSON, defined := fields["roms"]; defined {
+		if err := json.Unmarshal(romsJSON, &romFields); err != nil {
+			return nil, fmt.Errorf("\"roms\" is not a list of ROM sections: %w", err)
+		}
+		delete(fields, "roms")
+	}
+
+	defaults, err := parseConfigSettings(fields)
+	if err != nil {
+		return nil, err
+	}
+	configFile := &ConfigFile{Defaults: defaults}
+
+	for i, fields := range romFields {
+		settings, err := parseConfigSettings(fields)
+		if err != nil {
+			return nil, fmt.Errorf("ROM section %d: %w", i+1, err)
+		}
+
+		section := ConfigFileSection{Match: settings["match"], SHA1: strings.ToLower(settings["sha1"]), Settings: settings}
+		delete(settings, "match")
+		delete(settings, "sha1")
+		if (section.Match == "") && (section.SHA1 == "") {
+			return nil, fmt.Errorf("ROM section %d has neither \"match\" nor \"sha1\"", i+1)
+		}
+		if _, err := filepath.Match(section.Match, ""); err != nil {
+			return nil, fmt.Errorf("ROM section %d has illegal match \"%s\": %w", i+1, section.Match, err)
+		}
+
+		configFile.ROMs = append(configFile.ROMs, section)
+	}
+
+	return configFile, nil
+}
+
+// parseConfigSettings converts the JSON strings, numbers and booleans to setting text
+func parseConfigSettings(fields map[string]json.RawMessage) (ConfigSettings, error) {
+	settings := ConfigSettings{}
+
+	for name, value := range fields {
+		value = bytes.TrimSpace(value)
+		switch {
+		case (len(value) > 0) && (value[0] == '"'):
+			var text string
+			if err := json.Unmarshal(value, &text); err != nil {
+				return nil, fmt.Errorf("illegal value of \"%s\": %w", name, err)
+			}
+			settings[name] = text
+		case (len(value) > 0) && ((value[0] == '{') || (value[0] == '[') || (string(value) == "null")):
+			return nil, fmt.Errorf("illegal value of \"%s\": %s (expected a string, number or boolean)", name, value)
+		default:
+			settings[name] = string(value)
+		}
+	}
+
+	return settings, nil
+}
+
+// Settings are the settings of the ROM: the defaults, overridden by every matching ROM section in file order.
+func (c *ConfigFile) Settings(romFilepath string, romBytes []byte) ConfigSettings {
+	settings := ConfigSettings{}
+	for name, value := range c.Defaults {
+		settings[name] = value
+	}
+
+	hash := sha1.Sum(romBytes)
+	romSHA1 := hex.EncodeToString(hash[:])
+
+	for _, section := range c.ROMs {
+		if section.matches(romFilepath, romSHA1) {
+			for name, value := range section.Settings {
+				settings[name] = value
+			}
+		}
+	}
+
+	return settings
+}
+
+// matches is true if the glob matches the file name of the ROM, or the hash is the hash of the ROM
+func (s *ConfigFileSection) matches(romFilepath string, romSHA1 string) bool {
+	if (s.SHA1 != "") && (s.SHA1 == romSHA1) {
+		return true
+	}
+	matched, _ := filepath.Match(s.Match, filepath.Base(romFilepath))
+	return (s.Match != "") && matched
+}
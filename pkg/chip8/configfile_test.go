@@ -0,0 +1,51 @@
+package chip8
+
+import (
+	"testing"
+)
+
+func TestConfigFileSettingsForROM(t *testing.T) {
+	content := `{
+		"display": "tty",
+		"cycles-per-frame": 10,
+		"roms": [
+			{"match": "BRIX*.ch8", "palette": "000000,FFB000", "cycles-per-frame": 15},
+			{"sha1": "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709", "quirk-wrap-sprites": true},
+			{"match": "*.ch8", "cycles-per-frame": 20}
+		]
+	}`
+
+	configFile, err := ParseConfigFile([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	brixSettings := configFile.Settings("roms/BRIX.ch8", []byte{0x12, 0x00})
+	expectedBrixSettings := ConfigSettings{"display": "tty", "cycles-per-frame": "20", "palette": "000000,FFB000"}
+	if len(brixSettings) != len(expectedBrixSettings) {
+		t.Errorf("expected settings %v, got %v", expectedBrixSettings, brixSettings)
+	}
+	for name, expectedValue := range expectedBrixSettings {
+		if brixSettings[name] != expectedValue {
+			t.Errorf("expected setting \"%s\" to be \"%s\", got \"%s\"", name, expectedValue, brixSettings[name])
+		}
+	}
+
+	emptySettings := configFile.Settings("empty.rom", []byte{}) // The SHA-1 hash of no bytes
+	if (emptySettings["quirk-wrap-sprites"] != "true") || (emptySettings["cycles-per-frame"] != "10") {
+		t.Errorf("expected the settings of the hash section, got %v", emptySettings)
+	}
+
+	illegalContents := []string{
+		`{"roms": [{"palette": "000000,FFFFFF"}]}`,
+		`{"roms": [{"match": "[", "palette": "000000,FFFFFF"}]}`,
+		`{"keymap": ["arrows"]}`,
+		`{"roms": {"match": "*.ch8"}}`,
+		`not json`,
+	}
+	for _, illegalContent := range illegalContents {
+		if _, err := ParseConfigFile([]byte(illegalContent)); err == nil {
+			t.Errorf("expected error for %s", illegalContent)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Coverage marks the memory addresses executed as instructions, and the memory addresses read or written as data
+// by DXYN (sprites), FX33 (binary-coded decimals), FX55 and FX65 (registers), see Chip8.SetCoverage.
+type Coverage struct {
+	marks [0x10000]uint8
+}
+
+const (
+	coverageInstruction     = 1 << iota // coverageInstruction marks the start of an executed instruction
+	coverageInstructionByte             // coverageInstructionByte marks the following bytes of an executed instruction
+	coverageData                        // coverageData marks a byte read or written as data
+)
+
+// NewCoverage creates an empty coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// executed marks the instruction at the address executed
+func (c *Coverage) executed(address uint16, size uint16) {
+	c.marks[address] |= coverageInstruction
+	for i := uint16(1); i < size; i++ {
+		c.marks[address+i] |= coverageInstructionByte
+	}
+}
+
+// accessed marks the byte at the address read or written as data
+func (c *Coverage) accessed(address uint16) {
+	c.marks[address] |= coverageData
+}
+
+// Executed is true if an instruction at the address was executed.
+func (c *Coverage) Executed(address uint16) bool {
+	return c.marks[address]&coverageInstruction != 0
+}
+
+// Data is true if the byte at the address was read or written as data.
+func (c *Coverage) Data(address uint16) bool {
+	return c.marks[address]&coverageData != 0
+}
+
+// coverageLine is a line of a coverage listing, an instruction or a data byte
+type coverageLine struct {
+	address     uint16
+	bytes       []byte
+	kind        string // kind is "executed", "data", "code" (decodable instruction not executed) or "unknown"
+	text        string // text is the mnemonic of instructions, or the pixels of data bytes
+	unexplored  string // unexplored describes the never taken outcome of an executed conditional skip
+	instruction bool
+}
+
+// coverageSummary is the number of ROM bytes executed, accessed as data and untouched
+type coverageSummary struct {
+	size, executed, data, untouched int
+	unexploredBranches              int
+}
+
+// lines is the listing of the ROM loaded at the load address. Executed instructions and data bytes are listed as executed
+// and accessed, other bytes are listed as instructions where they decode as instructions, and otherwise as bytes.
+func (c *Coverage) lines(rom []byte, loadAddress uint16) ([]coverageLine, coverageSummary) {
+	var lines []coverageLine
+	summary := coverageSummary{size: len(rom)}
+
+	for offset := 0; offset < len(rom); {
+		address := loadAddress + uint16(offset)
+		line := coverageLine{address: address, bytes: rom[offset : offset+1], kind: "unknown"}
+
+		var instructionCode uint16
+		if offset+1 < len(rom) {
+			instructionCode = uint16(rom[offset])<<8 | uint16(rom[offset+1])
+		}
+		instruction, known := DecodeInstruction(instructionCode)
+		untouched := func(address uint16) bool { return c.marks[address] == 0 }
+
+		switch {
+		case c.Executed(address):
+			line.kind, line.instruction, line.text = "executed", true, instruction.Mnemonic()
+			if instruction.IsSkip() {
+				if !c.Executed(address + 2) {
+					line.unexplored = "never continued with the next instruction"
+				} else if !c.Executed(address + 4) {
+					line.unexplored = "never skipped the next instruction"
+				}
+			}
+		case c.Data(address):
+			line.kind, line.text = "data", bytePixels(rom[offset])
+		case (offset+1 < len(rom)) && known && untouched(address) && untouched(address+1):
+			line.kind, line.instruction, line.text = "code", true, instruction.Mnemonic()
+		default:
+			line.text = bytePixels(rom[offset])
+		}
+
+		if line.instruction {
+			line.bytes = rom[offset:minInt(offset+int(instruction.Size()), len(rom))]
+		}
+		for i := range line.bytes {
+			switch marks := c.marks[address+uint16(i)]; {
+			case marks&(coverageInstruction|coverageInstructionByte) != 0:
+				summary.executed++
+			case marks&coverageData != 0:
+				summary.data++
+			default:
+				summary.untouched++
+			}
+		}
+		if line.unexplored != "" {
+			summary.unexploredBranches++
+		}
+
+		lines = append(lines, line)
+		offset += len(line.bytes)
+	}
+
+	return lines, summary
+}
+
+// bytePixels is the bits of the byte as sprite pixels, like "░░████░░"
+func bytePixels(value byte) string {
+	var pixels strings.Builder
+	for bit := 7; bit >= 0; bit-- {
+		if (value>>bit)&1 == 1 {
+			pixels.WriteString("█")
+		} else {
+			pixels.WriteString("░")
+		}
+	}
+	return pixels.String()
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (s coverageSummary) percentage(bytes int) float64 {
+	if s.size == 0 {
+		return 0
+	}
+	return 100 * float64(bytes) / float64(s.size)
+}
+
+// WriteReport writes the coverage of the ROM loaded at the load address, as an HTML page for "*.html" files
+// and otherwise as an annotated listing.
+func (c *Coverage) WriteReport(reportFilepath string, romFilepath string, rom []byte, loadAddress uint16) error {
+	file, err := os.Create(reportFilepath)
+	if err != nil {
+		return fmt.Errorf("could not create coverage report file \"%s\": %w", reportFilepath, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if strings.EqualFold(filepath.Ext(reportFilepath), ".html") {
+		c.writeHTML(writer, filepath.Base(romFilepath), rom, loadAddress)
+	} else {
+		c.writeListing(writer, filepath.Base(romFilepath), rom, loadAddress)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write coverage report file \"%s\": %w", reportFilepath, err)
+	}
+	return nil
+}
+
+// coverageMarkers are the listing markers of the line kinds
+var coverageMarkers = map[string]string{"executed": ">", "data": "D", "code": "-", "unknown": " "}
+
+func (c *Coverage) writeListing(writer io.Writer, romName string, rom []byte, loadAddress uint16) {
+	lines, summary := c.lines(rom, loadAddress)
+
+	fmt.Fprintf(writer, "Coverage of \"%s\" (%d bytes)\n", romName, summary.size)
+	fmt.Fprintf(writer, "  Executed instructions:  %5d bytes  %5.1f%%\n", summary.executed, summary.percentage(summary.executed))
+	fmt.Fprintf(writer, "  Data read or written:   %5d bytes  %5.1f%%\n", summary.data, summary.percentage(summary.data))
+	fmt.Fprintf(writer, "  Untouched:              %5d bytes  %5.1f%%\n", summary.untouched, summary.percentage(summary.untouched))
+	fmt.Fprintf(writer, "  Unexplored branches:    %5d\n", summary.unexploredBranches)
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "\">\" executed instruction, \"D\" data, \"-\" instruction never executed")
+	fmt.Fprintln(writer)
+
+	for _, line := range lines {
+		fmt.Fprintf(writer, "%s 0x%03X  %-10s  %s", coverageMarkers[line.kind], line.address, fmt.Sprintf("% X", line.bytes), line.text)
+		if line.unexplored != "" {
+			fmt.Fprintf(writer, "    ; unexplored branch: %s", line.unexplored)
+		}
+		fmt.Fprintln(writer)
+	}
+}
+
+func (c *Coverage) writeHTML(writer io.Writer, romName string, rom []byte, loadAddress uint16) {
+	lines, summary := c.lines(rom, loadAddress)
+	title := html.EscapeString(fmt.Sprintf("Coverage of %s", romName))
+
+	fmt.Fprintf(writer, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 1em 0 0; white-space: pre; }
+.executed { background: #c8f0c8; }
+.data { background: #c8dcf8; }
+.code { background: #f8d0d0; }
+.unknown { color: #888; }
+.unexplored { background: #f8ecb0; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`, title, title)
+
+	fmt.Fprintln(writer, "<table>")
+	fmt.Fprintf(writer, "<tr class=\"executed\"><td>Executed instructions</td><td>%d bytes</td><td>%.1f%%</td></tr>\n", summary.executed, summary.percentage(summary.executed))
+	fmt.Fprintf(writer, "<tr class=\"data\"><td>Data read or written</td><td>%d bytes</td><td>%.1f%%</td></tr>\n", summary.data, summary.percentage(summary.data))
+	fmt.Fprintf(writer, "<tr class=\"code\"><td>Untouched</td><td>%d bytes</td><td>%.1f%%</td></tr>\n", summary.untouched, summary.percentage(summary.untouched))
+	fmt.Fprintf(writer, "<tr class=\"unexplored\"><td>Unexplored branches</td><td>%d</td><td></td></tr>\n", summary.unexploredBranches)
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "<p>Green: executed instruction, blue: data, red: instruction never executed.</p>")
+
+	fmt.Fprintln(writer, "<table>")
+	for _, line := range lines {
+		class := line.kind
+		if line.unexplored != "" {
+			class = "unexplored"
+		}
+		fmt.Fprintf(writer, "<tr class=\"%s\"><td>0x%03X</td><td>% X</td><td>%s</td><td>%s</td></tr>\n",
+			class, line.address, line.bytes, html.EscapeString(line.text), html.EscapeString(line.unexplored))
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+	fmt.Fprintln(writer, "</html>")
+}
@@ -0,0 +1,56 @@
+package chip8
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	program := []byte{
+		0xA2, 0x0C, // 0x200: I = 0x20C
+		0xD0, 0x01, // 0x202: draw 1 row of sprite at 0x20C
+		0x30, 0x01, // 0x204: skip if V0 == 1 (never)
+		0x12, 0x0A, // 0x206: jump 0x20A
+		0x00, 0xE0, // 0x208: (never executed)
+		0x12, 0x0A, // 0x20A: infinite loop
+		0xF0, // 0x20C: sprite
+		0xFF, // 0x20D: (untouched)
+	}
+
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes(program, romAddressDefault)
+	coverage := NewCoverage()
+	machine.SetCoverage(coverage)
+
+	if err := machine.Run(Configuration{EndOnInfiniteLoop: true, Headless: true}); err != ErrInfiniteLoop {
+		t.Fatalf("expected infinite loop, got %v", err)
+	}
+
+	for address, expectedExecuted := range map[uint16]bool{0x200: true, 0x201: false, 0x206: true, 0x208: false, 0x20A: true} {
+		if coverage.Executed(address) != expectedExecuted {
+			t.Errorf("expected 0x%03X executed %v", address, expectedExecuted)
+		}
+	}
+	for address, expectedData := range map[uint16]bool{0x20C: true, 0x20D: false, 0x202: false} {
+		if coverage.Data(address) != expectedData {
+			t.Errorf("expected 0x%03X data %v", address, expectedData)
+		}
+	}
+
+	var listing bytes.Buffer
+	coverage.writeListing(&listing, "test.ch8", program, romAddressDefault)
+	for _, expectedLine := range []string{
+		"Executed instructions:     10 bytes   71.4%",
+		"Data read or written:       1 bytes    7.1%",
+		"> 0x204  30 01       SE V0, 0x01    ; unexplored branch: never skipped the next instruction",
+		"- 0x208  00 E0       CLS",
+		"D 0x20C  F0          ████░░░░",
+		"  0x20D  FF          ████████",
+	} {
+		if !strings.Contains(listing.String(), expectedLine) {
+			t.Errorf("expected line \"%s\" in listing\n%s", expectedLine, listing.String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InputMovie is a recording of keypad states, frame by frame, to be played back during (headless) execution.
+//
+// The text format has one key state change per line, the frame number followed by the 16 bit key state bitmask in hexadecimal
+// (bit 0 is key "0" through bit 15 being key "F"). The key state is held until the next change. Lines starting with "#" are comments.
+//
+//	# frame  keys
+//	0        0000
+//	120      0010  # press key "4"
+//	130      0000  # release all keys
+type InputMovie struct {
+	changes []inputMovieChange
+	next    int
+}
+
+type inputMovieChange struct {
+	frame uint64
+	keys  uint16
+}
+
+// LoadInputMovie reads an input movie from file.
+func LoadInputMovie(filepath string) (*InputMovie, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open input movie file \"%s\": %w", filepath, err)
+	}
+	defer file.Close()
+
+	inputMovie := InputMovie{}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if commentIndex := strings.Index(line, "#"); commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("input movie file \"%s\" line %d: expected frame number and key state", filepath, lineNumber)
+		}
+
+		frame, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("input movie file \"%s\" line %d: illegal frame number \"%s\"", filepath, lineNumber, fields[0])
+		}
+
+		keys, err := strconv.ParseUint(fields[1], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("input movie file \"%s\" line %d: illegal key state \"%s\"", filepath, lineNumber, fields[1])
+		}
+
+		inputMovie.changes = append(inputMovie.changes, inputMovieChange{frame: frame, keys: uint16(keys)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read input movie file \"%s\": %w", filepath, err)
+	}
+
+	sort.SliceStable(inputMovie.changes, func(i, j int) bool { return inputMovie.changes[i].frame < inputMovie.changes[j].frame })
+
+	return &inputMovie, nil
+}
+
+// InputMovieRecorder records the key events during execution as an input movie.
+type InputMovieRecorder struct {
+	keys        uint16
+	changes     []inputMovieChange
+	changedKeys uint16 // changedKeys are the keys changed by the last key state change
+}
+
+func NewInputMovieRecorder() *InputMovieRecorder {
+	return &InputMovieRecorder{}
+}
+
+// CaptureKeyEvent records the key state after the key event. Several key events in the same frame make a single key state change,
+// unless a key is both pressed and released in the same frame. The release is then delayed to the next frame, not to lose the key press.
+func (r *InputMovieRecorder) CaptureKeyEvent(keyEvent KeyEvent) {
+	key := uint16(1) << keyEvent.Key
+	if keyEvent.Pressed {
+		r.keys |= key
+	} else {
+		r.keys &^= key
+	}
+
+	lastChange := len(r.changes) - 1
+	if (lastChange >= 0) && (r.changes[lastChange].frame >= keyEvent.Frame) && (r.changedKeys&key == 0) {
+		r.changes[lastChange].keys = r.keys
+		r.changedKeys |= key
+		return
+	}
+
+	frame := keyEvent.Frame
+	if (lastChange >= 0) && (r.changes[lastChange].frame >= frame) {
+		frame = r.changes[lastChange].frame + 1
+	}
+	r.changes = append(r.changes, inputMovieChange{frame: frame, keys: r.keys})
+	r.changedKeys = key
+}
+
+// Write writes the recorded input movie to file.
+func (r *InputMovieRecorder) Write(filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("could not create input movie file \"%s\": %w", filepath, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "# frame  keys")
+	for _, change := range r.changes {
+		fmt.Fprintf(writer, "%-8d %04X\n", change.frame, change.keys)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write input movie file \"%s\": %w", filepath, err)
+	}
+
+	return nil
+}
+
+// keysAt returns the key state for the frame and if the key state changed since the previous call.
+// Frames are expected to be asked for in increasing order.
+func (m *InputMovie) keysAt(frame uint64) (keys uint16, changed bool) {
+	for (m.next < len(m.changes)) && (m.changes[m.next].frame <= frame) {
+		keys = m.changes[m.next].keys
+		changed = true
+		m.next++
+	}
+
+	return keys, changed
+}
@@ -0,0 +1,86 @@
+package chip8
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeInputMovie writes the input movie text to a file, returning the file path
+func writeInputMovie(t *testing.T, text string) string {
+	movieFilepath := filepath.Join(t.TempDir(), "input.movie")
+	if err := os.WriteFile(movieFilepath, []byte(text), 0644); err != nil {
+		t.Fatalf("could not write input movie: %s", err)
+	}
+	return movieFilepath
+}
+
+func TestLoadInputMovie(t *testing.T) {
+	inputMovie, err := LoadInputMovie(writeInputMovie(t, "# frame  keys\n\n130  0000  # release\n0    0000\n120  0010  # press key \"4\"\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		frame   uint64
+		keys    uint16
+		changed bool
+	}{
+		{0, 0x0000, true},
+		{1, 0x0000, false},
+		{125, 0x0010, true},
+		{129, 0x0000, false},
+		{130, 0x0000, true},
+		{200, 0x0000, false},
+	}
+	for _, test := range tests {
+		if keys, changed := inputMovie.keysAt(test.frame); (keys != test.keys) || (changed != test.changed) {
+			t.Errorf("frame %d: expected keys %04X changed %v, got %04X changed %v", test.frame, test.keys, test.changed, keys, changed)
+		}
+	}
+}
+
+func TestLoadInputMovieErrors(t *testing.T) {
+	tests := []struct {
+		text          string
+		expectedError string
+	}{
+		{"0 0000\n120\n", "line 2: expected frame number and key state"},
+		{"0 0000 0010\n", "line 1: expected frame number and key state"},
+		{"-1 0000\n", "line 1: illegal frame number \"-1\""},
+		{"0 10000\n", "line 1: illegal key state \"10000\""},
+		{"0 keys\n", "line 1: illegal key state \"keys\""},
+	}
+	for _, test := range tests {
+		_, err := LoadInputMovie(writeInputMovie(t, test.text))
+		if (err == nil) || !strings.Contains(err.Error(), test.expectedError) {
+			t.Errorf("%q: expected error containing \"%s\", got %v", test.text, test.expectedError, err)
+		}
+	}
+
+	if _, err := LoadInputMovie(filepath.Join(t.TempDir(), "missing.movie")); err == nil {
+		t.Error("expected error for missing input movie file")
+	}
+}
+
+func TestInputMovieRecorderWritesLoadableMovie(t *testing.T) {
+	recorder := NewInputMovieRecorder()
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 5, Key: 0xA, Pressed: true})
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 9, Key: 0xA, Pressed: false})
+
+	movieFilepath := filepath.Join(t.TempDir(), "recorded.movie")
+	if err := recorder.Write(movieFilepath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	inputMovie, err := LoadInputMovie(movieFilepath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if keys, _ := inputMovie.keysAt(5); keys != 0x0400 {
+		t.Errorf("expected key A pressed at frame 5, got %04X", keys)
+	}
+	if keys, changed := inputMovie.keysAt(9); (keys != 0) || !changed {
+		t.Errorf("expected key A released at frame 9, got %04X", keys)
+	}
+}
@@ -0,0 +1,252 @@
+package chip8
+
+import "fmt"
+
+// InstructionSet is the CHIP-8 variant an instruction belongs to.
+type InstructionSet int
+
+const (
+	InstructionSetChip8       InstructionSet = iota // InstructionSetChip8 is the original CHIP-8 instructions
+	InstructionSetMachineCode                       // InstructionSetMachineCode is 0NNN, calling a machine code routine of the host computer
+	InstructionSetSCHIP                             // InstructionSetSCHIP is the instructions added by SUPER-CHIP
+	InstructionSetXOCHIP                            // InstructionSetXOCHIP is the instructions added by XO-CHIP
+)
+
+func (s InstructionSet) String() string {
+	switch s {
+	case InstructionSetMachineCode:
+		return "machine code"
+	case InstructionSetSCHIP:
+		return "SCHIP"
+	case InstructionSetXOCHIP:
+		return "XO-CHIP"
+	default:
+		return "CHIP-8"
+	}
+}
+
+// Instruction is a decoded instruction.
+type Instruction struct {
+	Code   uint16         // Code is the (first) instruction word
+	Opcode string         // Opcode is the instruction pattern, like "8XY6"
+	Set    InstructionSet // Set is the CHIP-8 variant of the instruction
+	X      uint8
+	Y      uint8
+	N      uint8
+	NN     uint8
+	NNN    uint16
+}
+
+// instructionPattern is an instruction matched by the instruction code bits of the mask being equal to the value
+type instructionPattern struct {
+	opcode string
+	mask   uint16
+	value  uint16
+	set    InstructionSet
+}
+
+// instructionPatterns are the known instructions. The first matching pattern decodes the instruction.
+var instructionPatterns = []instructionPattern{
+	{"00E0", 0xFFFF, 0x00E0, InstructionSetChip8},
+	{"00EE", 0xFFFF, 0x00EE, InstructionSetChip8},
+	{"00CN", 0xFFF0, 0x00C0, InstructionSetSCHIP},
+	{"00DN", 0xFFF0, 0x00D0, InstructionSetXOCHIP},
+	{"00FB", 0xFFFF, 0x00FB, InstructionSetSCHIP},
+	{"00FC", 0xFFFF, 0x00FC, InstructionSetSCHIP},
+	{"00FD", 0xFFFF, 0x00FD, InstructionSetSCHIP},
+	{"00FE", 0xFFFF, 0x00FE, InstructionSetSCHIP},
+	{"00FF", 0xFFFF, 0x00FF, InstructionSetSCHIP},
+	{"0NNN", 0xF000, 0x0000, InstructionSetMachineCode},
+	{"1NNN", 0xF000, 0x1000, InstructionSetChip8},
+	{"2NNN", 0xF000, 0x2000, InstructionSetChip8},
+	{"3XNN", 0xF000, 0x3000, InstructionSetChip8},
+	{"4XNN", 0xF000, 0x4000, InstructionSetChip8},
+	{"5XY0", 0xF00F, 0x5000, InstructionSetChip8},
+	{"5XY2", 0xF00F, 0x5002, InstructionSetXOCHIP},
+	{"5XY3", 0xF00F, 0x5003, InstructionSetXOCHIP},
+	{"6XNN", 0xF000, 0x6000, InstructionSetChip8},
+	{"7XNN", 0xF000, 0x7000, InstructionSetChip8},
+	{"8XY0", 0xF00F, 0x8000, InstructionSetChip8},
+	{"8XY1", 0xF00F, 0x8001, InstructionSetChip8},
+	{"8XY2", 0xF00F, 0x8002, InstructionSetChip8},
+	{"8XY3", 0xF00F, 0x8003, InstructionSetChip8},
+	{"8XY4", 0xF00F, 0x8004, InstructionSetChip8},
+	{"8XY5", 0xF00F, 0x8005, InstructionSetChip8},
+	{"8XY6", 0xF00F, 0x8006, InstructionSetChip8},
+	{"8XY7", 0xF00F, 0x8007, InstructionSetChip8},
+	{"8XYE", 0xF00F, 0x800E, InstructionSetChip8},
+	{"9XY0", 0xF00F, 0x9000, InstructionSetChip8},
+	{"ANNN", 0xF000, 0xA000, InstructionSetChip8},
+	{"BNNN", 0xF000, 0xB000, InstructionSetChip8},
+	{"CXNN", 0xF000, 0xC000, InstructionSetChip8},
+	{"DXY0", 0xF00F, 0xD000, InstructionSetSCHIP},
+	{"DXYN", 0xF000, 0xD000, InstructionSetChip8},
+	{"EX9E", 0xF0FF, 0xE09E, InstructionSetChip8},
+	{"EXA1", 0xF0FF, 0xE0A1, InstructionSetChip8},
+	{"F000", 0xFFFF, 0xF000, InstructionSetXOCHIP},
+	{"FN01", 0xF0FF, 0xF001, InstructionSetXOCHIP},
+	{"F002", 0xFFFF, 0xF002, InstructionSetXOCHIP},
+	{"FX07", 0xF0FF, 0xF007, InstructionSetChip8},
+	{"FX0A", 0xF0FF, 0xF00A, InstructionSetChip8},
+	{"FX15", 0xF0FF, 0xF015, InstructionSetChip8},
+	{"FX18", 0xF0FF, 0xF018, InstructionSetChip8},
+	{"FX1E", 0xF0FF, 0xF01E, InstructionSetChip8},
+	{"FX29", 0xF0FF, 0xF029, InstructionSetChip8},
+	{"FX30", 0xF0FF, 0xF030, InstructionSetSCHIP},
+	{"FX33", 0xF0FF, 0xF033, InstructionSetChip8},
+	{"FX3A", 0xF0FF, 0xF03A, InstructionSetXOCHIP},
+	{"FX55", 0xF0FF, 0xF055, InstructionSetChip8},
+	{"FX65", 0xF0FF, 0xF065, InstructionSetChip8},
+	{"FX75", 0xF0FF, 0xF075, InstructionSetSCHIP},
+	{"FX85", 0xF0FF, 0xF085, InstructionSetSCHIP},
+}
+
+// DecodeInstruction decodes the instruction code, false if it is not a known instruction.
+func DecodeInstruction(code uint16) (Instruction, bool) {
+	for _, pattern := range instructionPatterns {
+		if code&pattern.mask == pattern.value {
+			return Instruction{
+				Code:   code,
+				Opcode: pattern.opcode,
+				Set:    pattern.set,
+				X:      uint8((code & 0x0F00) >> 8),
+				Y:      uint8((code & 0x00F0) >> 4),
+				N:      uint8(code & 0x000F),
+				NN:     uint8(code & 0x00FF),
+				NNN:    code & 0x0FFF,
+			}, true
+		}
+	}
+
+	return Instruction{Code: code}, false
+}
+
+// Size is the size in bytes of the instruction, 4 for the long "F000 NNNN" of XO-CHIP and otherwise 2.
+func (i Instruction) Size() uint16 {
+	if i.Opcode == "F000" {
+		return 4
+	}
+	return 2
+}
+
+// IsSkip is true for the instructions conditionally skipping the next instruction.
+func (i Instruction) IsSkip() bool {
+	switch i.Opcode {
+	case "3XNN", "4XNN", "5XY0", "9XY0", "EX9E", "EXA1":
+		return true
+	}
+	return false
+}
+
+// Mnemonic is the instruction in the mnemonics of Cowgod's CHIP-8 technical reference, like "LD V0, 0x0C",
+// as assembled by Assemble. Unknown instructions are data words, like "DW 0xFFFF".
+func (i Instruction) Mnemonic() string {
+	x, y, n, nn, nnn := i.X, i.Y, i.N, i.NN, i.NNN
+
+	switch i.Opcode {
+	case "00E0":
+		return "CLS"
+	case "00EE":
+		return "RET"
+	case "00CN":
+		return fmt.Sprintf("SCD %d", n)
+	case "00DN":
+		return fmt.Sprintf("SCU %d", n)
+	case "00FB":
+		return "SCR"
+	case "00FC":
+		return "SCL"
+	case "00FD":
+		return "EXIT"
+	case "00FE":
+		return "LOW"
+	case "00FF":
+		return "HIGH"
+	case "0NNN":
+		return fmt.Sprintf("SYS 0x%03X", nnn)
+	case "1NNN":
+		return fmt.Sprintf("JP 0x%03X", nnn)
+	case "2NNN":
+		return fmt.Sprintf("CALL 0x%03X", nnn)
+	case "3XNN":
+		return fmt.Sprintf("SE V%X, 0x%02X", x, nn)
+	case "4XNN":
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, nn)
+	case "5XY0":
+		return fmt.Sprintf("SE V%X, V%X", x, y)
+	case "5XY2":
+		return fmt.Sprintf("SAVE V%X, V%X", x, y)
+	case "5XY3":
+		return fmt.Sprintf("LOAD V%X, V%X", x, y)
+	case "6XNN":
+		return fmt.Sprintf("LD V%X, 0x%02X", x, nn)
+	case "7XNN":
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, nn)
+	case "8XY0":
+		return fmt.Sprintf("LD V%X, V%X", x, y)
+	case "8XY1":
+		return fmt.Sprintf("OR V%X, V%X", x, y)
+	case "8XY2":
+		return fmt.Sprintf("AND V%X, V%X", x, y)
+	case "8XY3":
+		return fmt.Sprintf("XOR V%X, V%X", x, y)
+	case "8XY4":
+		return fmt.Sprintf("ADD V%X, V%X", x, y)
+	case "8XY5":
+		return fmt.Sprintf("SUB V%X, V%X", x, y)
+	case "8XY6":
+		return fmt.Sprintf("SHR V%X, V%X", x, y)
+	case "8XY7":
+		return fmt.Sprintf("SUBN V%X, V%X", x, y)
+	case "8XYE":
+		return fmt.Sprintf("SHL V%X, V%X", x, y)
+	case "9XY0":
+		return fmt.Sprintf("SNE V%X, V%X", x, y)
+	case "ANNN":
+		return fmt.Sprintf("LD I, 0x%03X", nnn)
+	case "BNNN":
+		return fmt.Sprintf("JP V0, 0x%03X", nnn)
+	case "CXNN":
+		return fmt.Sprintf("RND V%X, 0x%02X", x, nn)
+	case "DXY0", "DXYN":
+		return fmt.Sprintf("DRW V%X, V%X, %d", x, y, n)
+	case "EX9E":
+		return fmt.Sprintf("SKP V%X", x)
+	case "EXA1":
+		return fmt.Sprintf("SKNP V%X", x)
+	case "F000":
+		return "LD I, long"
+	case "FN01":
+		return fmt.Sprintf("PLANE %d", x)
+	case "F002":
+		return "AUDIO"
+	case "FX07":
+		return fmt.Sprintf("LD V%X, DT", x)
+	case "FX0A":
+		return fmt.Sprintf("LD V%X, K", x)
+	case "FX15":
+		return fmt.Sprintf("LD DT, V%X", x)
+	case "FX18":
+		return fmt.Sprintf("LD ST, V%X", x)
+	case "FX1E":
+		return fmt.Sprintf("ADD I, V%X", x)
+	case "FX29":
+		return fmt.Sprintf("LD F, V%X", x)
+	case "FX30":
+		return fmt.Sprintf("LD HF, V%X", x)
+	case "FX33":
+		return fmt.Sprintf("LD B, V%X", x)
+	case "FX3A":
+		return fmt.Sprintf("PITCH V%X", x)
+	case "FX55":
+		return fmt.Sprintf("LD [I], V%X", x)
+	case "FX65":
+		return fmt.Sprintf("LD V%X, [I]", x)
+	case "FX75":
+		return fmt.Sprintf("LD R, V%X", x)
+	case "FX85":
+		return fmt.Sprintf("LD V%X, R", x)
+	}
+
+	return fmt.Sprintf("DW 0x%04X", i.Code)
+}
@@ -0,0 +1,51 @@
+package chip8
+
+// decodedInstruction is the instruction at an address, fetched and decoded into its fields
+type decodedInstruction struct {
+	code            uint16
+	instructionType uint8
+	x               uint8
+	y               uint8
+	n               uint8
+	nn              uint8
+	nnn             uint16
+	cached          bool // cached is true if the instruction was decoded since the memory at its address was last written
+}
+
+// decode is the decoded instruction at the address, from the instruction cache, and whether it was cached.
+//
+// The cache is invalidated by writes to the memory of the instruction (see writeMemory). A cached instruction has been
+// executed since its memory was last written, so its execution is not self-modifying code.
+func (chip8 *Chip8) decode(address uint16) (*decodedInstruction, bool) {
+	decoded := &chip8.instructionCache[address]
+	if decoded.cached {
+		return decoded, true
+	}
+
+	instructionCode := chip8.fetch(address)
+	*decoded = decodedInstruction{
+		code:            instructionCode,
+		instructionType: uint8((instructionCode & 0xF000) >> 12),
+		x:               uint8((instructionCode & 0x0F00) >> 8),
+		y:               uint8((instructionCode & 0x00F0) >> 4),
+		n:               uint8(instructionCode & 0x000F),
+		nn:              uint8(instructionCode & 0x00FF),
+		nnn:             instructionCode & 0x0FFF,
+		cached:          true,
+	}
+	return decoded, false
+}
+
+// invalidateInstruction drops the cached instructions containing the byte at the address, starting at it or the byte before
+func (chip8 *Chip8) invalidateInstruction(address uint16) {
+	chip8.instructionCache[address].cached = false
+	chip8.instructionCache[chip8.memoryAddress(address-1)].cached = false
+}
+
+// InvalidateInstructionCache drops all decoded instructions. Writes by the program invalidate the instruction cache,
+// but writing instructions to Memory directly, after they have been executed, needs the cache invalidated.
+func (chip8 *Chip8) InvalidateInstructionCache() {
+	for address := range chip8.instructionCache {
+		chip8.instructionCache[address].cached = false
+	}
+}
@@ -0,0 +1,33 @@
+package chip8
+
+import "testing"
+
+func TestInstructionCacheInvalidatedByMemoryWrites(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes([]byte{0x60, 0x42}, romAddressDefault) // V0 = 0x42
+
+	stepAt := func(expectedV0 uint8) {
+		t.Helper()
+		machine.PC = romAddressDefault
+		if err := machine.Step(Configuration{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if machine.V[0] != expectedV0 {
+			t.Errorf("expected V0=0x%02X, got 0x%02X", expectedV0, machine.V[0])
+		}
+	}
+
+	stepAt(0x42)
+
+	machine.writeMemory(romAddressDefault+1, 0x17) // V0 = 0x17, written by the program
+	stepAt(0x17)
+
+	machine.writeMemory(romAddressDefault, 0x61) // V1 = 0x17, the first byte written by the program
+	machine.V[0] = 0
+	stepAt(0x00)
+
+	machine.Memory[romAddressDefault] = 0x60 // V0 = 0x17, written directly
+	machine.InvalidateInstructionCache()
+	stepAt(0x17)
+}
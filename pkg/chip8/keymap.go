@@ -0,0 +1,225 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keymap maps keys of the keyboard to keys of the hex keypad, for the built-in frontends (terminal and browser).
+//
+// Keyboard keys are named by their (lower case) character, like "q" or "1", or by name: "up", "down", "left", "right",
+// "space", "enter" and "tab". Several keyboard keys may map to the same hex key.
+//
+// The text format has one mapping per line, the keyboard key followed by the hex key (or "-" to unmap the keyboard key).
+// Mappings are applied on top of the default COSMAC VIP layout. Lines starting with "#" are comments.
+//
+//	# Tetris
+//	left   5
+//	right  6
+//	up     4
+//	down   7
+type Keymap struct {
+	Name string
+	keys map[string]uint8
+}
+
+// KeymapFileExtension is the file extension of keymap files. A keymap file next to a ROM file, with the same name
+// (like "TETRIS.keymap" for "TETRIS.ch8"), overrides the default keymap for that ROM.
+const KeymapFileExtension = ".keymap"
+
+// keymapNamedKeys are the keyboard keys named rather than given by their character
+var keymapNamedKeys = []string{"up", "down", "left", "right", "space", "enter", "tab"}
+
+// keymapProfiles are the built-in keymaps, by name
+var keymapProfiles = map[string]string{
+	// The COSMAC VIP hex keypad layout mapped onto the left side of a QWERTY keyboard
+	//
+	//	1 2 3 C      1 2 3 4
+	//	4 5 6 D  ->  Q W E R
+	//	7 8 9 E      A S D F
+	//	A 0 B F      Z X C V
+	"cosmac": `
+		1 1  2 2  3 3  4 C
+		q 4  w 5  e 6  r D
+		a 7  s 8  d 9  f E
+		z A  x 0  c B  v F`,
+
+	// The COSMAC VIP layout, plus the arrow keys as the keypad "arrows" 2, 4, 6 and 8 and space as the center key 5
+	"arrows": `
+		up 2  left 4  right 6  down 8  space 5`,
+}
+
+// DefaultKeymap is the COSMAC VIP hex keypad layout mapped onto the left side of a QWERTY keyboard (1234/QWER/ASDF/ZXCV).
+func DefaultKeymap() Keymap {
+	keymap, _ := KeymapProfile("cosmac")
+	return keymap
+}
+
+// KeymapProfile is the built-in keymap with the name, "cosmac" or "arrows".
+func KeymapProfile(name string) (Keymap, error) {
+	profile, exists := keymapProfiles[name]
+	if !exists {
+		return Keymap{}, fmt.Errorf("unknown keymap \"%s\" (expected one of %s, or a keymap file)", name, strings.Join(KeymapProfileNames(), ", "))
+	}
+
+	keymap := Keymap{Name: name, keys: map[string]uint8{}}
+	if name != "cosmac" {
+		keymap = DefaultKeymap()
+		keymap.Name = name
+	}
+
+	if err := keymap.parse(strings.NewReader(profile), name); err != nil {
+		return Keymap{}, err
+	}
+
+	return keymap, nil
+}
+
+// KeymapProfileNames are the names of the built-in keymaps.
+func KeymapProfileNames() []string {
+	names := make([]string, 0, len(keymapProfiles))
+	for name := range keymapProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadKeymap reads a keymap from file, applied on top of the default keymap.
+func LoadKeymap(keymapFilepath string) (Keymap, error) {
+	file, err := os.Open(keymapFilepath)
+	if err != nil {
+		return Keymap{}, fmt.Errorf("could not open keymap file \"%s\": %w", keymapFilepath, err)
+	}
+	defer file.Close()
+
+	keymap := DefaultKeymap()
+	keymap.Name = keymapFilepath
+	if err := keymap.parse(file, "keymap file \""+keymapFilepath+"\""); err != nil {
+		return Keymap{}, err
+	}
+
+	return keymap, nil
+}
+
+// KeymapForROM is the keymap file next to the ROM file, if there is one, otherwise the default keymap.
+func KeymapForROM(romFilepath string) (Keymap, error) {
+	keymapFilepath := KeymapFilepathForROM(romFilepath)
+	if _, err := os.Stat(keymapFilepath); err != nil {
+		return DefaultKeymap(), nil
+	}
+
+	return LoadKeymap(keymapFilepath)
+}
+
+// KeymapFilepathForROM is the file path of the keymap file next to the ROM file, like "roms/TETRIS.keymap" for "roms/TETRIS.ch8".
+func KeymapFilepathForROM(romFilepath string) string {
+	return strings.TrimSuffix(romFilepath, filepath.Ext(romFilepath)) + KeymapFileExtension
+}
+
+// SelectKeymap is the built-in keymap with the name, or else the keymap read from the file with the name.
+func SelectKeymap(nameOrFilepath string) (Keymap, error) {
+	if _, isProfile := keymapProfiles[nameOrFilepath]; isProfile {
+		return KeymapProfile(nameOrFilepath)
+	}
+
+	if _, err := os.Stat(nameOrFilepath); err != nil {
+		return Keymap{}, fmt.Errorf("unknown keymap \"%s\" (expected one of %s, or a keymap file)", nameOrFilepath, strings.Join(KeymapProfileNames(), ", "))
+	}
+
+	return LoadKeymap(nameOrFilepath)
+}
+
+func (k *Keymap) parse(reader io.Reader, source string) error {
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if commentIndex := strings.Index(line, "#"); commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields)%2 != 0 {
+			return fmt.Errorf("%s line %d: expected keyboard key and hex key", source, lineNumber)
+		}
+
+		for i := 0; i < len(fields); i += 2 {
+			keyName := strings.ToLower(fields[i])
+			if !isKeymapKeyName(keyName) {
+				return fmt.Errorf("%s line %d: illegal keyboard key \"%s\" (expected a character or one of %s)", source, lineNumber, fields[i], strings.Join(keymapNamedKeys, ", "))
+			}
+
+			if fields[i+1] == "-" {
+				delete(k.keys, keyName)
+				continue
+			}
+
+			hexKey, err := strconv.ParseUint(fields[i+1], 16, 4)
+			if err != nil {
+				return fmt.Errorf("%s line %d: illegal hex key \"%s\" (expected 0 to F)", source, lineNumber, fields[i+1])
+			}
+			k.keys[keyName] = uint8(hexKey)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read %s: %w", source, err)
+	}
+
+	return nil
+}
+
+func isKeymapKeyName(keyName string) bool {
+	if len(keyName) == 1 {
+		return (keyName[0] > ' ') && (keyName[0] < 0x7f) && (keyName[0] != '#')
+	}
+
+	for _, namedKey := range keymapNamedKeys {
+		if keyName == namedKey {
+			return true
+		}
+	}
+	return false
+}
+
+// HexKey is the hex key the keyboard key maps to.
+func (k Keymap) HexKey(keyName string) (hexKey uint8, mapped bool) {
+	hexKey, mapped = k.keys[keyName]
+	return hexKey, mapped
+}
+
+// Keys are all mappings of keyboard keys to hex keys.
+func (k Keymap) Keys() map[string]uint8 {
+	keys := make(map[string]uint8, len(k.keys))
+	for keyName, hexKey := range k.keys {
+		keys[keyName] = hexKey
+	}
+	return keys
+}
+
+// String is the keymap as a keymap file, mappings sorted by hex key.
+func (k Keymap) String() string {
+	keyNames := make([]string, 0, len(k.keys))
+	for keyName := range k.keys {
+		keyNames = append(keyNames, keyName)
+	}
+	sort.Slice(keyNames, func(i, j int) bool {
+		if k.keys[keyNames[i]] != k.keys[keyNames[j]] {
+			return k.keys[keyNames[i]] < k.keys[keyNames[j]]
+		}
+		return keyNames[i] < keyNames[j]
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n", k.Name)
+	for _, keyName := range keyNames {
+		fmt.Fprintf(&builder, "%-6s %X\n", keyName, k.keys[keyName])
+	}
+	return builder.String()
+}
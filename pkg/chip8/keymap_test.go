@@ -0,0 +1,49 @@
+package chip8
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeymapForROMOverridesDefaultKeymap(t *testing.T) {
+	directory := t.TempDir()
+	romFilepath := filepath.Join(directory, "TETRIS.ch8")
+	keymapText := "# Tetris\nLeft 5\nright 6  up 4\ndown 7\nq -  # q is not used\n"
+	if err := os.WriteFile(filepath.Join(directory, "TETRIS"+KeymapFileExtension), []byte(keymapText), 0o644); err != nil {
+		t.Fatalf("could not write keymap file: %s", err)
+	}
+
+	keymap, err := KeymapForROM(romFilepath)
+	if err != nil {
+		t.Fatalf("could not load keymap: %s", err)
+	}
+
+	expectedKeys := map[string]uint8{"left": 0x5, "right": 0x6, "up": 0x4, "down": 0x7, "w": 0x5, "v": 0xF}
+	for keyName, expectedHexKey := range expectedKeys {
+		if hexKey, mapped := keymap.HexKey(keyName); !mapped || (hexKey != expectedHexKey) {
+			t.Errorf("expected key \"%s\" mapped to %X, got %X (mapped %v)", keyName, expectedHexKey, hexKey, mapped)
+		}
+	}
+	if _, mapped := keymap.HexKey("q"); mapped {
+		t.Errorf("expected key \"q\" to be unmapped")
+	}
+
+	if keymap, _ := KeymapForROM(filepath.Join(directory, "PONG.ch8")); keymap.Name != "cosmac" {
+		t.Errorf("expected default keymap for ROM without keymap file, got \"%s\"", keymap.Name)
+	}
+}
+
+func TestSelectKeymapRejectsIllegalKeymaps(t *testing.T) {
+	if _, err := SelectKeymap("dvorak"); err == nil {
+		t.Errorf("expected error for unknown keymap")
+	}
+
+	keymapFilepath := filepath.Join(t.TempDir(), "illegal.keymap")
+	for _, illegalKeymap := range []string{"q", "q G", "escape 1"} {
+		os.WriteFile(keymapFilepath, []byte(illegalKeymap), 0o644)
+		if _, err := SelectKeymap(keymapFilepath); err == nil {
+			t.Errorf("expected error for keymap \"%s\"", illegalKeymap)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package chip8
+
+// KeyEvent is a key of the hex keypad being pressed or released.
+type KeyEvent struct {
+	Frame   uint64 // Frame is the frame the event was seen by the interpreter
+	Key     uint8  // Key is the key, 0x0 to 0xF
+	Pressed bool   // Pressed is true for a key press, false for a key release
+}
+
+// keyWaitNone is the key being waited for by FX0A while no key has been pressed yet
+const keyWaitNone = -1
+
+// queueKeyEvents queues press and release events for the keys changing between the old and new key state, lowest key first.
+func (p *Peripherals) queueKeyEvents(oldKeys uint16, newKeys uint16) {
+	p.keyEventsLock.Lock()
+	defer p.keyEventsLock.Unlock()
+
+	changedKeys := oldKeys ^ newKeys
+	for key := uint8(0); key <= 0xF; key++ {
+		if (changedKeys>>key)&0x1 == 1 {
+			p.keyEvents = append(p.keyEvents, KeyEvent{Key: key, Pressed: (newKeys>>key)&0x1 == 1})
+		}
+	}
+}
+
+// takeKeyEvents removes and returns the queued key events, in the order they happened.
+func (p *Peripherals) takeKeyEvents() []KeyEvent {
+	p.keyEventsLock.Lock()
+	defer p.keyEventsLock.Unlock()
+
+	keyEvents := p.keyEvents
+	p.keyEvents = nil
+	return keyEvents
+}
+
+// AddKeyEventListener adds a listener that is called with every key event, stamped with the frame it was seen in.
+func (chip8 *Chip8) AddKeyEventListener(keyEventListener func(keyEvent KeyEvent)) {
+	chip8.keyEventListeners = append(chip8.keyEventListeners, keyEventListener)
+}
+
+// takeKeyEvents moves the key events of the peripherals to the key event queue of the interpreter, stamped with the current frame.
+// Key events are queued only while FX0A waits for a key, and are otherwise only seen by key event listeners.
+func (chip8 *Chip8) takeKeyEvents() {
+	for _, keyEvent := range chip8.peripherals.takeKeyEvents() {
+		keyEvent.Frame = chip8.Frame
+
+		for _, keyEventListener := range chip8.keyEventListeners {
+			keyEventListener(keyEvent)
+		}
+
+		if chip8.keyWaiting {
+			chip8.keyEventQueue = append(chip8.keyEventQueue, keyEvent)
+		}
+	}
+}
+
+// waitForKey is FX0A waiting for a key, consuming the queued key events in order.
+// The wait starts on the first call, only key events from then on count. The wait ends when a key is pressed,
+// or when the pressed key is released again if configured (as on the COSMAC VIP).
+// The key is returned, and if the wait ended.
+func (chip8 *Chip8) waitForKey(configuration Configuration) (key uint8, ended bool) {
+	if !chip8.keyWaiting {
+		chip8.keyWaiting = true
+		chip8.keyWaitKey = keyWaitNone
+		chip8.keyEventQueue = nil
+	}
+
+	for len(chip8.keyEventQueue) > 0 {
+		keyEvent := chip8.keyEventQueue[0]
+		chip8.keyEventQueue = chip8.keyEventQueue[1:]
+
+		if (chip8.keyWaitKey == keyWaitNone) && keyEvent.Pressed {
+			chip8.keyWaitKey = int(keyEvent.Key)
+			if !configuration.QuirkKeyWaitRelease {
+				chip8.keyWaiting = false
+				return keyEvent.Key, true
+			}
+		} else if (chip8.keyWaitKey == int(keyEvent.Key)) && !keyEvent.Pressed {
+			chip8.keyWaiting = false
+			return keyEvent.Key, true
+		}
+	}
+
+	return 0, false
+}
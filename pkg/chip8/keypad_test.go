@@ -0,0 +1,78 @@
+package chip8
+
+import "testing"
+
+// newKeyWaitMachine creates a machine with the program "FX0A" (wait for key into V1) followed by an endless loop.
+func newKeyWaitMachine(t *testing.T) (*Chip8, *Peripherals) {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	if err := machine.loadROMBytes([]byte{0xF1, 0x0A, 0x12, 0x02}, romAddressDefault); err != nil {
+		t.Fatalf("could not load program: %s", err)
+	}
+	return machine, &peripherals
+}
+
+// stepFrame sees the key events of the peripherals and executes an instruction, as at the start of a frame.
+func stepFrame(t *testing.T, machine *Chip8, configuration Configuration) {
+	machine.takeKeyEvents()
+	if err := machine.Step(configuration); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	machine.Frame++
+}
+
+func TestKeyWaitEndsOnKeyRelease(t *testing.T) {
+	configuration := Configuration{QuirkKeyWaitRelease: true}
+	machine, peripherals := newKeyWaitMachine(t)
+
+	stepFrame(t, machine, configuration)
+
+	peripherals.UpdateKeys(1 << 0x5)
+	stepFrame(t, machine, configuration)
+	if machine.PC != 0x200 {
+		t.Fatalf("expected FX0A to wait for the key release, PC is 0x%03X", machine.PC)
+	}
+
+	peripherals.UpdateKeys(1<<0x5 | 1<<0x7)
+	peripherals.UpdateKeys(1 << 0x7)
+	stepFrame(t, machine, configuration)
+	if (machine.PC != 0x202) || (machine.V[1] != 0x5) {
+		t.Fatalf("expected key 5 after release, got V1=%X PC=0x%03X", machine.V[1], machine.PC)
+	}
+}
+
+func TestKeyWaitIgnoresKeyHeldBeforeWaiting(t *testing.T) {
+	configuration := Configuration{QuirkKeyWaitRelease: false}
+	machine, peripherals := newKeyWaitMachine(t)
+
+	peripherals.UpdateKeys(1 << 0x3)
+	stepFrame(t, machine, configuration)
+	stepFrame(t, machine, configuration)
+	if machine.PC != 0x200 {
+		t.Fatalf("expected FX0A to ignore key held since before waiting, PC is 0x%03X", machine.PC)
+	}
+
+	peripherals.UpdateKeys(1<<0x3 | 1<<0xA)
+	stepFrame(t, machine, configuration)
+	if (machine.PC != 0x202) || (machine.V[1] != 0xA) {
+		t.Fatalf("expected key A on press, got V1=%X PC=0x%03X", machine.V[1], machine.PC)
+	}
+}
+
+func TestInputMovieRecorderKeepsKeyTapsWithinFrame(t *testing.T) {
+	recorder := NewInputMovieRecorder()
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 10, Key: 0x4, Pressed: true})
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 10, Key: 0x6, Pressed: true})
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 10, Key: 0x4, Pressed: false})
+	recorder.CaptureKeyEvent(KeyEvent{Frame: 30, Key: 0x6, Pressed: false})
+
+	expectedChanges := []inputMovieChange{{frame: 10, keys: 0x0050}, {frame: 11, keys: 0x0040}, {frame: 30, keys: 0x0000}}
+	if len(recorder.changes) != len(expectedChanges) {
+		t.Fatalf("expected %d key state changes, got %+v", len(expectedChanges), recorder.changes)
+	}
+	for i, expectedChange := range expectedChanges {
+		if recorder.changes[i] != expectedChange {
+			t.Errorf("expected key state change %+v, got %+v", expectedChange, recorder.changes[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Machine is the memory layout of a computer (or interpreter) running CHIP-8 programs, and the quirks of its interpreter.
+type Machine struct {
+	Name         string // Name is the name used to select the machine, like "cosmac-vip"
+	Description  string
+	LoadAddress  uint16 // LoadAddress is the memory address the ROM is loaded at
+	StartAddress uint16 // StartAddress is the memory address execution starts at
+	MemorySize   int    // MemorySize is the size of memory in bytes
+	FontAddress  uint16 // FontAddress is the memory address of the hexadecimal digit font
+	StackDepth   int    // StackDepth is the maximum number of nested subroutine calls
+
+	QuirkKeyWaitRelease    bool
+	QuirkDisplayWait       bool
+	QuirkWrapSprites       bool
+	QuirkCollisionRowCount bool
+}
+
+// Machines are the known machines, the first being the default.
+var Machines = []Machine{
+	{
+		Name:                "cosmac-vip",
+		Description:         "RCA COSMAC VIP, the original CHIP-8 computer (1977)",
+		LoadAddress:         romAddressDefault,
+		StartAddress:        romAddressDefault,
+		MemorySize:          0x1000,
+		FontAddress:         fontAddressDefault,
+		StackDepth:          12, // Original RCA 1802 implementation had 12 levels of nesting
+		QuirkKeyWaitRelease: true,
+		QuirkDisplayWait:    true,
+	},
+	{
+		Name:                "eti-660",
+		Description:         "ETI-660 learner's microcomputer, loading programs at 0x600",
+		LoadAddress:         romAddressEti660,
+		StartAddress:        romAddressEti660,
+		MemorySize:          0x1000,
+		FontAddress:         fontAddressDefault,
+		StackDepth:          12,
+		QuirkKeyWaitRelease: true,
+		QuirkDisplayWait:    true,
+	},
+	{
+		Name:                   "schip",
+		Description:            "SUPER-CHIP 1.1 on the HP48 calculators",
+		LoadAddress:            romAddressDefault,
+		StartAddress:           romAddressDefault,
+		MemorySize:             0x1000,
+		FontAddress:            fontAddressDefault,
+		StackDepth:             16,
+		QuirkKeyWaitRelease:    true,
+		QuirkCollisionRowCount: true,
+	},
+	{
+		Name:             "xo-chip",
+		Description:      "XO-CHIP, the CHIP-8 extension of the Octo interpreter, with 64 kB of memory",
+		LoadAddress:      romAddressDefault,
+		StartAddress:     romAddressDefault,
+		MemorySize:       0x10000,
+		FontAddress:      fontAddressDefault,
+		StackDepth:       16,
+		QuirkWrapSprites: true,
+	},
+}
+
+// DefaultMachine is the COSMAC VIP.
+func DefaultMachine() Machine {
+	return Machines[0]
+}
+
+// MachineByName is the known machine with the name.
+func MachineByName(name string) (Machine, error) {
+	names := make([]string, 0, len(Machines))
+	for _, machine := range Machines {
+		if machine.Name == name {
+			return machine, nil
+		}
+		names = append(names, machine.Name)
+	}
+
+	return Machine{}, fmt.Errorf("unknown machine \"%s\" (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// ApplyQuirks sets the quirks of the configuration to the quirks of the machine.
+func (m Machine) ApplyQuirks(configuration *Configuration) {
+	configuration.QuirkKeyWaitRelease = m.QuirkKeyWaitRelease
+	configuration.QuirkDisplayWait = m.QuirkDisplayWait
+	configuration.QuirkWrapSprites = m.QuirkWrapSprites
+	configuration.QuirkCollisionRowCount = m.QuirkCollisionRowCount
+}
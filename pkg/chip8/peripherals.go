@@ -2,18 +2,36 @@ package chip8
 
 import (
 	"fmt"
-	"github.com/vmihailenco/msgpack/v5"
-	"log"
-	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
+// Peripherals is the screen, sound and keypad of the machine, broadcast to any number of frontends.
+// Remote viewers of the frontends join the peripherals, and one of them is the controller whose key input is accepted.
 type Peripherals struct {
-	state                *PeripheralsState
-	screenConnection     net.Conn
-	lock                 sync.Mutex
-	keyStateListenerPort int
+	state       *PeripheralsState
+	stateLock   sync.Mutex // stateLock guards the state, written by the interpreter and by frontends receiving key input on their own goroutines
+	frontends   []Frontend
+	lock        sync.Mutex
+	viewersLock sync.Mutex
+	viewers     []viewerRegistration // viewers are the joined remote viewers, in join order
+	controller  string               // controller is the id of the viewer whose key input is accepted, "" for none
+	keymap      Keymap               // keymap maps keyboard keys to hex keys, for frontends reading the keyboard
+
+	keyEventsLock sync.Mutex
+	keyEvents     []KeyEvent // keyEvents are the key presses and releases not yet seen by the interpreter
+
+	quitRequested atomic.Bool // quitRequested is true if a frontend asked the interpreter to stop running the program
+}
+
+// Frontend presents the screen and sound state to the user (such as a screen application or a terminal)
+// and feeds key presses back to the peripherals.
+type Frontend interface {
+	Start(p *Peripherals) error // Start starts listening for key input, key states are reported per source (viewer) and merged by the peripherals
+	UpdateScreen(state *PeripheralsState)
+	UpdateSoundAndKeys(state *PeripheralsState)
+	Close() error
 }
 
 type PeripheralsState struct {
@@ -22,152 +40,132 @@ type PeripheralsState struct {
 	screen ScreenBuffer // ScreenBuffer is the screen memory, the pixel memory representation
 }
 
-type peripheralStateMessage struct {
-	Sound        bool   `msgpack:"sound"`
-	Keys         uint16 `msgpack:"keys"`
-	Screen       []byte `msgpack:"screen"`
-	ScreenWidth  byte   `msgpack:"screenWidth"`
-	ScreenHeight byte   `msgpack:"screenHeight"`
-}
-
+// NewPeripherals creates peripherals connected to a screen application over UDP.
 func NewPeripherals(screenAddress string, keyStateListenerPort int) Peripherals {
-	screenConnection, err := net.Dial("udp", screenAddress)
+	frontend, err := NewUDPFrontend(screenAddress, keyStateListenerPort, true)
 	if err != nil {
-		fmt.Printf("Could not create screenConnection to screen %v", err)
+		fmt.Println(err.Error())
 		os.Exit(2)
 	}
 
-	state := PeripheralsState{
-		sound:  false,              // No sound
-		keys:   0b0000000000000000, // No keys pressed
-		screen: NewScreenBuffer(),  // Empty (black) screen
-	}
+	return NewPeripheralsWithFrontend(frontend)
+}
 
+// NewPeripheralsWithFrontend creates peripherals presented by all the frontends.
+func NewPeripheralsWithFrontend(frontends ...Frontend) Peripherals {
 	return Peripherals{
-		screenConnection:     screenConnection,
-		keyStateListenerPort: keyStateListenerPort,
-		state:                &state,
+		state:     newPeripheralsState(),
+		frontends: frontends,
+		keymap:    DefaultKeymap(),
 	}
 }
 
-func (p *Peripherals) StartKeyPadListener() {
-	go listenForPeripheralKeyPadInput(p)
+// NewHeadlessPeripherals creates peripherals without any frontend.
+// Screen, sound and key state is kept in memory only, which is useful for testing and batch execution.
+func NewHeadlessPeripherals() Peripherals {
+	return Peripherals{
+		state:  newPeripheralsState(),
+		keymap: DefaultKeymap(),
+	}
 }
 
-func listenForPeripheralKeyPadInput(p *Peripherals) {
-	keyPadMaxDatagramSize := 256
-
-	addr, _ := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", p.keyStateListenerPort))
-	sock, _ := net.ListenUDP("udp", addr)
-	sock.SetReadBuffer(keyPadMaxDatagramSize)
+func newPeripheralsState() *PeripheralsState {
+	return &PeripheralsState{
+		sound:  false,              // No sound
+		keys:   0b0000000000000000, // No keys pressed
+		screen: NewScreenBuffer(),  // Empty (black) screen
+	}
+}
 
-	buffer := make([]byte, keyPadMaxDatagramSize)
+// SetKeymap sets the keymap of frontends reading the keyboard, to be set before the key pad listener is started.
+func (p *Peripherals) SetKeymap(keymap Keymap) {
+	p.keymap = keymap
+}
 
-	// Loop forever reading from the socket
-	for {
-		numBytes, _, err := sock.ReadFromUDP(buffer)
-		if err != nil {
-			log.Fatal("Read from UDP failed:", err)
-		}
+// Keymap is the keymap of frontends reading the keyboard.
+func (p *Peripherals) Keymap() Keymap {
+	return p.keymap
+}
 
-		if numBytes != 2 {
-			log.Fatalf("chip-8 key state listener: illegal input length: %d bytes (expected 2 bytes)", numBytes)
+func (p *Peripherals) StartKeyPadListener() {
+	for _, frontend := range p.frontends {
+		if err := frontend.Start(p); err != nil {
+			fmt.Printf("could not start key pad listener: %s\n", err.Error())
 		}
-
-		keyPadState := (uint16(buffer[0]) << 8) | (uint16(buffer[1]) << 0) // Convert byte input data to key pad state
-		p.UpdateKeys(keyPadState)
 	}
 }
 
 func (p *Peripherals) Close() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	if err := p.screenConnection.Close(); err != nil {
-		fmt.Printf("could close screenConnection: %s\n", err.Error())
+	for _, frontend := range p.frontends {
+		if err := frontend.Close(); err != nil {
+			fmt.Printf("could not close frontend: %s\n", err.Error())
+		}
 	}
 }
 
+// RequestQuit asks the interpreter to stop running the program at the end of the frame, as the user quit in a frontend.
+func (p *Peripherals) RequestQuit() {
+	p.quitRequested.Store(true)
+}
+
+// QuitRequested is true if a frontend asked the interpreter to stop running the program.
+func (p *Peripherals) QuitRequested() bool {
+	return p.quitRequested.Load()
+}
+
 func (p *Peripherals) UpdateSound(newSoundState bool) {
-	if p.state.sound != newSoundState {
-		p.state.sound = newSoundState
+	p.stateLock.Lock()
+	changed := p.state.sound != newSoundState
+	p.state.sound = newSoundState
+	p.stateLock.Unlock()
+
+	if changed {
 		p.UpdateSoundAndKeys()
 	}
 }
 
 func (p *Peripherals) UpdateKeys(newKeysState uint16) {
-	if p.state.keys != newKeysState {
+	p.stateLock.Lock()
+	oldKeysState := p.state.keys
+	p.state.keys = newKeysState
+	p.stateLock.Unlock()
+
+	if oldKeysState != newKeysState {
 		//fmt.Printf("New key state: %016b\n", newKeysState)
-		p.state.keys = newKeysState
+		p.queueKeyEvents(oldKeysState, newKeysState)
 		p.UpdateSoundAndKeys()
 	}
 }
 
 func (p *Peripherals) UpdateSoundAndKeys() {
-	serializedMessage := getSerializedSoundAndKeysMessage(p.state)
-	if _, err := p.screenConnection.Write(serializedMessage); err != nil {
-		fmt.Printf("could not update peripherals sound and key state: %s\n", err.Error())
-		fmt.Println("(is screen application up and running?)")
+	state := p.snapshot()
+	for _, frontend := range p.frontends {
+		frontend.UpdateSoundAndKeys(state)
 	}
 }
 
 func (p *Peripherals) UpdateScreen() {
-	serializedMessage := getSerializedScreenMessage(p.state)
-
-	if _, err := p.screenConnection.Write(serializedMessage); err != nil {
-		fmt.Printf("could not update peripherals screen (plus sound and key) state: %s\n", err.Error())
-		fmt.Println("(is screen application up and running?)")
+	state := p.snapshot()
+	for _, frontend := range p.frontends {
+		frontend.UpdateScreen(state)
 	}
 }
 
-func getSerializedSoundAndKeysMessage(state *PeripheralsState) []byte {
-	message := getSoundAndKeysMessage(state)
-
-	serializedMessage, err := msgpack.Marshal(&message)
-	if err != nil {
-		fmt.Printf("Could not marshal data: %+v\n", message)
-	}
+// snapshot is a copy of the state, taken under the state lock, for frontends to encode while the interpreter runs on.
+func (p *Peripherals) snapshot() *PeripheralsState {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
 
-	return serializedMessage
+	state := *p.state
+	return &state
 }
 
-func getSoundAndKeysMessage(state *PeripheralsState) *peripheralStateMessage {
-	width := state.screen.Width
-	height := state.screen.Height
-
-	// Create struct as soon as possible to capture sound state
-	message := peripheralStateMessage{
-		Sound:        state.sound,
-		Keys:         state.keys,
-		Screen:       nil,
-		ScreenWidth:  width,
-		ScreenHeight: height,
-	}
-
-	return &message
-}
-
-func getSerializedScreenMessage(state *PeripheralsState) []byte {
-	message := getSoundAndKeysMessage(state)
-
-	width := state.screen.Width
-	height := state.screen.Height
-	screenBitBuffer := make([]byte, int(width)*int(height)/8)
-
-	for y := uint8(0); y < height; y++ {
-		for x := uint8(0); x < width; x++ {
-			pixelIndex := int(y)*int(width) + int(x)
-			byteIndex := pixelIndex / 8
-			bitIndex := 7 - pixelIndex%8
-			screenBitBuffer[byteIndex] |= (state.screen.buffer[x][y] & 0b00000001) << bitIndex
-		}
-	}
-
-	message.Screen = screenBitBuffer
-
-	serializedMessage, err := msgpack.Marshal(&message)
-	if err != nil {
-		fmt.Printf("Could not marshal data: %+v\n", message)
-	}
+// pressedKeys is the bitmask of the pressed keys.
+func (p *Peripherals) pressedKeys() uint16 {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
 
-	return serializedMessage
+	return p.state.keys
 }
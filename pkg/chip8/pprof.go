@@ -0,0 +1,205 @@
+package chip8
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// WritePprof writes the profile as a gzipped pprof protocol buffer profile, to be visualized with "go tool pprof".
+// Every executed instruction address is a location and every subroutine a function, with the ROM file as source file
+// and the instruction addresses as line numbers.
+func (p *Profiler) WritePprof(pprofFilepath string, romFilepath string) error {
+	file, err := os.Create(pprofFilepath)
+	if err != nil {
+		return fmt.Errorf("could not create pprof profile file \"%s\": %w", pprofFilepath, err)
+	}
+	defer file.Close()
+
+	if err := p.writePprof(file, filepath.Base(romFilepath)); err != nil {
+		return fmt.Errorf("could not write pprof profile file \"%s\": %w", pprofFilepath, err)
+	}
+	return nil
+}
+
+// Field numbers of the pprof profile.proto messages (https://github.com/google/pprof/blob/main/proto/profile.proto)
+const (
+	pprofProfileSampleType  = 1
+	pprofProfileSample      = 2
+	pprofProfileMapping     = 3
+	pprofProfileLocation    = 4
+	pprofProfileFunction    = 5
+	pprofProfileStringTable = 6
+	pprofProfilePeriodType  = 11
+	pprofProfilePeriod      = 12
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+
+	pprofMappingID           = 1
+	pprofMappingMemoryStart  = 2
+	pprofMappingMemoryLimit  = 3
+	pprofMappingFilename     = 5
+	pprofMappingHasFunctions = 7
+	pprofMappingHasLines     = 9
+
+	pprofLocationID        = 1
+	pprofLocationMappingID = 2
+	pprofLocationAddress   = 3
+	pprofLocationLine      = 4
+
+	pprofLineFunctionID = 1
+	pprofLineLine       = 2
+
+	pprofFunctionID         = 1
+	pprofFunctionName       = 2
+	pprofFunctionSystemName = 3
+	pprofFunctionFilename   = 4
+	pprofFunctionStartLine  = 5
+)
+
+func (p *Profiler) writePprof(writer io.Writer, romName string) error {
+	var profile protobufMessage
+	stringTable := pprofStringTable{indices: map[string]int{"": 0}, table: []string{""}}
+
+	valueType := protobufMessage{}
+	valueType.varint(pprofValueTypeType, uint64(stringTable.index("instructions")))
+	valueType.varint(pprofValueTypeUnit, uint64(stringTable.index("count")))
+	profile.message(pprofProfileSampleType, valueType)
+
+	mapping := protobufMessage{}
+	mapping.varint(pprofMappingID, 1)
+	mapping.varint(pprofMappingMemoryStart, 0)
+	mapping.varint(pprofMappingMemoryLimit, 0x10000)
+	mapping.varint(pprofMappingFilename, uint64(stringTable.index(romName)))
+	mapping.varint(pprofMappingHasFunctions, 1)
+	mapping.varint(pprofMappingHasLines, 1)
+	profile.message(pprofProfileMapping, mapping)
+
+	functionIDs := map[uint16]uint64{}
+	for i, subroutine := range p.subroutineProfiles() {
+		functionIDs[subroutine.Entry] = uint64(i + 1)
+
+		function := protobufMessage{}
+		function.varint(pprofFunctionID, uint64(i+1))
+		function.varint(pprofFunctionName, uint64(stringTable.index(subroutine.Name())))
+		function.varint(pprofFunctionSystemName, uint64(stringTable.index(subroutine.Name())))
+		function.varint(pprofFunctionFilename, uint64(stringTable.index(romName)))
+		function.varint(pprofFunctionStartLine, uint64(subroutine.Entry))
+		profile.message(pprofProfileFunction, function)
+	}
+
+	samples := make([]*profilerSample, 0, len(p.stackSamples))
+	for _, sample := range p.stackSamples {
+		samples = append(samples, sample)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].count > samples[j].count })
+
+	locationIDs := map[profilerLocation]uint64{}
+	for _, sample := range samples {
+		locationIDsOfSample := make([]uint64, len(sample.locations))
+		for i, location := range sample.locations {
+			locationID, exists := locationIDs[location]
+			if !exists {
+				locationID = uint64(len(locationIDs) + 1)
+				locationIDs[location] = locationID
+
+				line := protobufMessage{}
+				line.varint(pprofLineFunctionID, functionIDs[location.entry])
+				line.varint(pprofLineLine, uint64(location.address))
+
+				pprofLocation := protobufMessage{}
+				pprofLocation.varint(pprofLocationID, locationID)
+				pprofLocation.varint(pprofLocationMappingID, 1)
+				pprofLocation.varint(pprofLocationAddress, uint64(location.address))
+				pprofLocation.message(pprofLocationLine, line)
+				profile.message(pprofProfileLocation, pprofLocation)
+			}
+			locationIDsOfSample[i] = locationID
+		}
+
+		pprofSample := protobufMessage{}
+		pprofSample.packedVarints(pprofSampleLocationID, locationIDsOfSample...)
+		pprofSample.packedVarints(pprofSampleValue, sample.count)
+		profile.message(pprofProfileSample, pprofSample)
+	}
+
+	profile.message(pprofProfilePeriodType, valueType)
+	profile.varint(pprofProfilePeriod, 1)
+
+	for _, text := range stringTable.table {
+		profile.bytes(pprofProfileStringTable, []byte(text))
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	if _, err := gzipWriter.Write(profile); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// pprofStringTable is the string table of a pprof profile, strings are referenced by index
+type pprofStringTable struct {
+	indices map[string]int
+	table   []string
+}
+
+func (t *pprofStringTable) index(text string) int {
+	index, exists := t.indices[text]
+	if !exists {
+		index = len(t.table)
+		t.indices[text] = index
+		t.table = append(t.table, text)
+	}
+	return index
+}
+
+// protobufMessage is an encoded protocol buffer message
+type protobufMessage []byte
+
+// Wire types of protocol buffer fields
+const (
+	protobufVarint          = 0
+	protobufLengthDelimited = 2
+)
+
+func (m *protobufMessage) appendVarint(value uint64) {
+	for value >= 0x80 {
+		*m = append(*m, byte(value)|0x80)
+		value >>= 7
+	}
+	*m = append(*m, byte(value))
+}
+
+func (m *protobufMessage) tag(field int, wireType int) {
+	m.appendVarint(uint64(field<<3 | wireType))
+}
+
+func (m *protobufMessage) varint(field int, value uint64) {
+	m.tag(field, protobufVarint)
+	m.appendVarint(value)
+}
+
+func (m *protobufMessage) bytes(field int, value []byte) {
+	m.tag(field, protobufLengthDelimited)
+	m.appendVarint(uint64(len(value)))
+	*m = append(*m, value...)
+}
+
+func (m *protobufMessage) message(field int, message protobufMessage) {
+	m.bytes(field, message)
+}
+
+func (m *protobufMessage) packedVarints(field int, values ...uint64) {
+	var packed protobufMessage
+	for _, value := range values {
+		packed.appendVarint(value)
+	}
+	m.bytes(field, packed)
+}
@@ -0,0 +1,240 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// timerWaitLoopLength is the most instructions between two reads of the delay timer (FX07) at the same address
+// counted as busy-waiting on the delay timer, like the loop "FX07, 3X00, 1NNN"
+const timerWaitLoopLength = 8
+
+// Profiler counts the instructions executed per address and per subroutine, the calls between subroutines
+// and the instructions spent waiting for keys and busy-waiting on the delay timer, see Chip8.SetProfiler.
+//
+// Subroutines are followed by the 2NNN calls and 00EE returns. The program outside subroutines is the "main" subroutine.
+type Profiler struct {
+	Instructions  uint64                        // Instructions is the number of executed instructions
+	KeyWait       uint64                        // KeyWait is the number of instructions executed by FX0A waiting for a key
+	TimerWait     uint64                        // TimerWait is the number of instructions executed in loops reading the delay timer until it runs out
+	Addresses     map[uint16]uint64             // Addresses is the number of instructions executed per address
+	Subroutines   map[uint16]*SubroutineProfile // Subroutines are the profiles of the subroutines, by entry address
+	Calls         map[[2]uint16]uint64          // Calls is the number of calls per caller and callee subroutine entry address
+	opcodes       map[uint16]uint16             // opcodes is the last instruction executed at every address
+	stack         []profilerFrame               // stack is the subroutines being executed, main first
+	stackSamples  map[string]*profilerSample    // stackSamples is the number of instructions executed per call stack
+	timerReadings map[uint16]uint64             // timerReadings is the instruction number of the last delay timer read per address, while the timer runs
+}
+
+// SubroutineProfile is the instructions executed in a subroutine.
+type SubroutineProfile struct {
+	Entry uint16 // Entry is the address of the subroutine
+	Main  bool   // Main is true for the program outside subroutines
+	Self  uint64 // Self is the number of instructions executed in the subroutine itself
+	Total uint64 // Total is the number of instructions executed in the subroutine and the subroutines it calls
+	Calls uint64 // Calls is the number of calls of the subroutine
+}
+
+// Name is "main" for the program outside subroutines, and otherwise the entry address of the subroutine, like "sub_2A0".
+func (s *SubroutineProfile) Name() string {
+	if s.Main {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", s.Entry)
+}
+
+type profilerFrame struct {
+	entry    uint16 // entry is the address of the subroutine
+	callSite uint16 // callSite is the address of the 2NNN calling the subroutine
+}
+
+// profilerSample is the number of instructions executed in a call stack, of the instruction addresses in the
+// subroutines of the call stack, innermost subroutine first
+type profilerSample struct {
+	locations []profilerLocation
+	count     uint64
+}
+
+type profilerLocation struct {
+	address uint16
+	entry   uint16 // entry is the address of the subroutine of the address
+}
+
+// NewProfiler creates a profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		Addresses:     map[uint16]uint64{},
+		Subroutines:   map[uint16]*SubroutineProfile{},
+		Calls:         map[[2]uint16]uint64{},
+		opcodes:       map[uint16]uint16{},
+		stackSamples:  map[string]*profilerSample{},
+		timerReadings: map[uint16]uint64{},
+	}
+}
+
+// count counts the instruction executed at the address, the program counter after the instruction and the delay timer.
+func (p *Profiler) count(address uint16, instructionCode uint16, nextAddress uint16, timer uint8) {
+	if len(p.stack) == 0 {
+		p.stack = append(p.stack, profilerFrame{entry: address})
+		p.Subroutines[address] = &SubroutineProfile{Entry: address, Main: true}
+	}
+
+	p.Instructions++
+	p.Addresses[address]++
+	p.opcodes[address] = instructionCode
+
+	p.Subroutines[p.stack[len(p.stack)-1].entry].Self++
+	counted := map[uint16]bool{}
+	for _, frame := range p.stack {
+		if !counted[frame.entry] { // Recursive subroutines are counted once
+			counted[frame.entry] = true
+			p.Subroutines[frame.entry].Total++
+		}
+	}
+	p.countStackSample(address)
+
+	instruction, _ := DecodeInstruction(instructionCode)
+	switch instruction.Opcode {
+	case "2NNN":
+		if nextAddress == instruction.NNN { // Not trapped by a stack overflow
+			caller := p.stack[len(p.stack)-1].entry
+			p.stack = append(p.stack, profilerFrame{entry: instruction.NNN, callSite: address})
+			if p.Subroutines[instruction.NNN] == nil {
+				p.Subroutines[instruction.NNN] = &SubroutineProfile{Entry: instruction.NNN}
+			}
+			p.Subroutines[instruction.NNN].Calls++
+			p.Calls[[2]uint16{caller, instruction.NNN}]++
+		}
+	case "00EE":
+		if len(p.stack) > 1 {
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+	case "FX0A":
+		if nextAddress == address {
+			p.KeyWait++
+		}
+	case "FX07":
+		if lastReading, reading := p.timerReadings[address]; reading && (p.Instructions-lastReading <= timerWaitLoopLength) {
+			p.TimerWait += p.Instructions - lastReading
+		}
+		if timer > 0 {
+			p.timerReadings[address] = p.Instructions
+		} else {
+			delete(p.timerReadings, address)
+		}
+	}
+}
+
+func (p *Profiler) countStackSample(address uint16) {
+	locations := make([]profilerLocation, 0, len(p.stack))
+	locations = append(locations, profilerLocation{address: address, entry: p.stack[len(p.stack)-1].entry})
+	for i := len(p.stack) - 1; i > 0; i-- {
+		locations = append(locations, profilerLocation{address: p.stack[i].callSite, entry: p.stack[i-1].entry})
+	}
+
+	var key strings.Builder
+	for _, location := range locations {
+		fmt.Fprintf(&key, "%04X%04X", location.address, location.entry)
+	}
+
+	sample, exists := p.stackSamples[key.String()]
+	if !exists {
+		sample = &profilerSample{locations: locations}
+		p.stackSamples[key.String()] = sample
+	}
+	sample.count++
+}
+
+// subroutineProfiles are the subroutines, main first and then by entry address
+func (p *Profiler) subroutineProfiles() []*SubroutineProfile {
+	subroutines := make([]*SubroutineProfile, 0, len(p.Subroutines))
+	for _, subroutine := range p.Subroutines {
+		subroutines = append(subroutines, subroutine)
+	}
+	sort.Slice(subroutines, func(i, j int) bool {
+		if subroutines[i].Main != subroutines[j].Main {
+			return subroutines[i].Main
+		}
+		return subroutines[i].Entry < subroutines[j].Entry
+	})
+	return subroutines
+}
+
+// percentage is the share of the executed instructions
+func (p *Profiler) percentage(instructions uint64) float64 {
+	if p.Instructions == 0 {
+		return 0
+	}
+	return 100 * float64(instructions) / float64(p.Instructions)
+}
+
+// WriteReport writes the profile as text: the waiting, the subroutines, the call graph and the annotated disassembly
+// of the executed instructions.
+func (p *Profiler) WriteReport(reportFilepath string) error {
+	file, err := os.Create(reportFilepath)
+	if err != nil {
+		return fmt.Errorf("could not create profile report file \"%s\": %w", reportFilepath, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	p.writeReport(writer)
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write profile report file \"%s\": %w", reportFilepath, err)
+	}
+	return nil
+}
+
+func (p *Profiler) writeReport(writer io.Writer) {
+	fmt.Fprintf(writer, "Profile of %d executed instructions\n", p.Instructions)
+	fmt.Fprintln(writer)
+
+	fmt.Fprintln(writer, "Waiting:")
+	fmt.Fprintf(writer, "  Waiting for key (FX0A):       %10d  %5.1f%%\n", p.KeyWait, p.percentage(p.KeyWait))
+	fmt.Fprintf(writer, "  Busy-waiting on delay timer:  %10d  %5.1f%%\n", p.TimerWait, p.percentage(p.TimerWait))
+	fmt.Fprintln(writer)
+
+	fmt.Fprintln(writer, "Subroutines:")
+	fmt.Fprintf(writer, "  %-10s %8s  %10s %7s  %10s %7s\n", "", "calls", "self", "", "total", "")
+	for _, subroutine := range p.subroutineProfiles() {
+		fmt.Fprintf(writer, "  %-10s %8d  %10d %6.1f%%  %10d %6.1f%%\n", subroutine.Name(), subroutine.Calls,
+			subroutine.Self, p.percentage(subroutine.Self), subroutine.Total, p.percentage(subroutine.Total))
+	}
+	fmt.Fprintln(writer)
+
+	fmt.Fprintln(writer, "Call graph:")
+	calls := make([][2]uint16, 0, len(p.Calls))
+	for call := range p.Calls {
+		calls = append(calls, call)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return (calls[i][0] < calls[j][0]) || ((calls[i][0] == calls[j][0]) && (calls[i][1] < calls[j][1]))
+	})
+	for _, call := range calls {
+		fmt.Fprintf(writer, "  %-10s -> %-10s %8d calls\n", p.Subroutines[call[0]].Name(), p.Subroutines[call[1]].Name(), p.Calls[call])
+	}
+	fmt.Fprintln(writer)
+
+	fmt.Fprintln(writer, "Annotated disassembly of the executed instructions:")
+	addresses := make([]uint16, 0, len(p.Addresses))
+	for address := range p.Addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+
+	for i, address := range addresses {
+		if subroutine, entry := p.Subroutines[address]; entry {
+			fmt.Fprintf(writer, "%s:\n", subroutine.Name())
+		} else if (i > 0) && (address > addresses[i-1]+2) {
+			fmt.Fprintln(writer, "  ...")
+		}
+
+		instruction, _ := DecodeInstruction(p.opcodes[address])
+		fmt.Fprintf(writer, "  0x%03X  %04X  %-20s %10d %6.1f%%  %s\n", address, instruction.Code, instruction.Mnemonic(),
+			p.Addresses[address], p.percentage(p.Addresses[address]), strings.Repeat("#", int(p.percentage(p.Addresses[address])/2)))
+	}
+}
@@ -0,0 +1,67 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	program := []byte{
+		0x22, 0x0A, // 0x200: call 0x20A
+		0xF0, 0x07, // 0x202: V0 = delay timer
+		0x30, 0x00, // 0x204: skip if V0 == 0
+		0x12, 0x02, // 0x206: jump 0x202
+		0x12, 0x08, // 0x208: infinite loop
+		0x60, 0x03, // 0x20A: V0 = 3
+		0xF0, 0x15, // 0x20C: delay timer = V0
+		0x00, 0xEE, // 0x20E: return
+	}
+
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes(program, romAddressDefault)
+	profiler := NewProfiler()
+	machine.SetProfiler(profiler)
+
+	configuration := Configuration{EndOnInfiniteLoop: true, CyclesPerFrame: 3, Headless: true}
+	if err := machine.Run(configuration); err != ErrInfiniteLoop {
+		t.Fatalf("expected infinite loop, got %v", err)
+	}
+
+	if (profiler.Instructions != machine.Cycles) || (profiler.Addresses[0x202] != 3) || (profiler.Addresses[0x20A] != 1) {
+		t.Errorf("unexpected instruction counts %d of %d, %v", profiler.Instructions, machine.Cycles, profiler.Addresses)
+	}
+
+	main, subroutine := profiler.Subroutines[0x200], profiler.Subroutines[0x20A]
+	if (main == nil) || (subroutine == nil) || !main.Main || (subroutine.Name() != "sub_20A") {
+		t.Fatalf("expected main and subroutine 0x20A, got %v", profiler.Subroutines)
+	}
+	if (subroutine.Calls != 1) || (subroutine.Self != 3) || (subroutine.Total != 3) || (main.Total != profiler.Instructions) || (main.Self != profiler.Instructions-3) {
+		t.Errorf("unexpected subroutine profiles %+v and %+v", main, subroutine)
+	}
+	if profiler.Calls[[2]uint16{0x200, 0x20A}] != 1 {
+		t.Errorf("expected call from main to 0x20A, got %v", profiler.Calls)
+	}
+
+	// The delay timer is read 3 times (values 2, 1 and 0), the 2 loops between the readings are waiting
+	if profiler.TimerWait != 6 {
+		t.Errorf("expected 6 instructions waiting on the delay timer, got %d", profiler.TimerWait)
+	}
+
+	var pprofProfile bytes.Buffer
+	if err := profiler.writePprof(&pprofProfile, "test.ch8"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	gzipReader, err := gzip.NewReader(&pprofProfile)
+	if err != nil {
+		t.Fatalf("expected gzipped profile: %s", err)
+	}
+	profile, _ := io.ReadAll(gzipReader)
+	for _, expectedString := range []string{"instructions", "main", "sub_20A", "test.ch8"} {
+		if !bytes.Contains(profile, []byte(expectedString)) {
+			t.Errorf("expected \"%s\" in the string table of the profile", expectedString)
+		}
+	}
+}
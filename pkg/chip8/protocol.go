@@ -0,0 +1,212 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The screen/keypad wire protocol between the interpreter and viewers (screen applications).
+// See documentation/protocol.adoc for the specification.
+
+// protocolVersion is the version of the wire protocol, messages of any other version are rejected
+const protocolVersion = 1
+
+const (
+	messageTypeHello = "hello" // messageTypeHello announces the sender and its capabilities, sent by both interpreter and viewer
+	messageTypeState = "state" // messageTypeState is the sound, key and (optionally) screen state, sent by the interpreter
+	messageTypeKeys  = "keys"  // messageTypeKeys is the key state, sent by the viewer
+	messageTypeAck   = "ack"   // messageTypeAck acknowledges the receipt of a screen, sent by the viewer
+)
+
+// legacyKeysDatagramSize is the size of the unversioned key state datagram, a big endian 16 bit key bitmask
+const legacyKeysDatagramSize = 2
+
+var errMalformedMessage = errors.New("malformed message")
+
+// messageHeader is part of every message.
+// Seq is the sequence number of the message, increasing by one for every message sent by the sender (wrapping around at 2^32).
+type messageHeader struct {
+	Version uint8  `msgpack:"version" json:"version"`
+	Type    string `msgpack:"type" json:"type"`
+	Seq     uint32 `msgpack:"seq" json:"seq"`
+}
+
+type helloMessage struct {
+	messageHeader
+	Name         string   `msgpack:"name" json:"name"`                         // Name of the sender (informational)
+	ScreenWidth  byte     `msgpack:"screenWidth" json:"screenWidth"`           // ScreenWidth is the largest supported screen width in pixels
+	ScreenHeight byte     `msgpack:"screenHeight" json:"screenHeight"`         // ScreenHeight is the largest supported screen height in pixels
+	Planes       byte     `msgpack:"planes" json:"planes"`                     // Planes is the number of supported bit planes (colors are 2^planes)
+	Audio        bool     `msgpack:"audio" json:"audio"`                       // Audio is true if sound (buzzer) is supported
+	Encodings    []string `msgpack:"encodings" json:"encodings"`               // Encodings are the supported screen encodings and compressions
+	Role         string   `msgpack:"role,omitempty" json:"role,omitempty"`     // Role is the requested (viewer) or granted (interpreter) role, "controller", "player" or "spectator"
+	Keypad       uint16   `msgpack:"keypad,omitempty" json:"keypad,omitempty"` // Keypad is the bitmask of keys owned by a player
+}
+
+type peripheralStateMessage struct {
+	messageHeader
+	Sound        bool   `msgpack:"sound" json:"sound"`
+	Keys         uint16 `msgpack:"keys" json:"keys"`
+	Screen       []byte `msgpack:"screen" json:"screen"`
+	ScreenWidth  byte   `msgpack:"screenWidth" json:"screenWidth"`
+	ScreenHeight byte   `msgpack:"screenHeight" json:"screenHeight"`
+	Encoding     string `msgpack:"encoding,omitempty" json:"encoding,omitempty"`       // Encoding of the screen, "full" or "xor" (absent is "full")
+	Base         uint32 `msgpack:"base,omitempty" json:"base,omitempty"`               // Base is the sequence number of the message holding the screen an "xor" encoded screen is relative to
+	Compression  string `msgpack:"compression,omitempty" json:"compression,omitempty"` // Compression of the screen, "deflate" or absent for no compression
+}
+
+type keysMessage struct {
+	messageHeader
+	Keys uint16 `msgpack:"keys" json:"keys"`
+}
+
+type ackMessage struct {
+	messageHeader
+	Ack uint32 `msgpack:"ack" json:"ack"` // Ack is the sequence number of the received state message holding a screen
+}
+
+// viewerMessage is any message received from a viewer, Hello, Keys or Ack is set depending on message type
+type viewerMessage struct {
+	Header messageHeader
+	Hello  *helloMessage
+	Keys   *keysMessage
+	Ack    *ackMessage
+}
+
+func newMessageHeader(messageType string, seq uint32) messageHeader {
+	return messageHeader{Version: protocolVersion, Type: messageType, Seq: seq}
+}
+
+func (h *messageHeader) setSeq(seq uint32) {
+	h.Seq = seq
+}
+
+// nextSequence is the sequence number following seq, skipping 0 (that denotes unsequenced messages) on wrap around
+func nextSequence(seq uint32) uint32 {
+	seq++
+	if seq == 0 {
+		seq = 1
+	}
+	return seq
+}
+
+// getHelloMessage is the interpreter capabilities
+func getHelloMessage(state *PeripheralsState) *helloMessage {
+	return &helloMessage{
+		messageHeader: newMessageHeader(messageTypeHello, 0),
+		Name:          "chip8",
+		ScreenWidth:   state.screen.Width,
+		ScreenHeight:  state.screen.Height,
+		Planes:        1,
+		Audio:         true,
+		Encodings:     []string{screenEncodingFull, screenEncodingXor, screenCompressionDeflate},
+	}
+}
+
+func getSoundAndKeysMessage(state *PeripheralsState) *peripheralStateMessage {
+	width := state.screen.Width
+	height := state.screen.Height
+
+	// Create struct as soon as possible to capture sound state
+	message := peripheralStateMessage{
+		messageHeader: newMessageHeader(messageTypeState, 0),
+		Sound:         state.sound,
+		Keys:          state.keys,
+		Screen:        nil,
+		ScreenWidth:   width,
+		ScreenHeight:  height,
+	}
+
+	return &message
+}
+
+func getScreenMessage(state *PeripheralsState) *peripheralStateMessage {
+	message := getSoundAndKeysMessage(state)
+
+	width := state.screen.Width
+	height := state.screen.Height
+	screenBitBuffer := make([]byte, int(width)*int(height)/8)
+
+	for y := uint8(0); y < height; y++ {
+		for x := uint8(0); x < width; x++ {
+			pixelIndex := int(y)*int(width) + int(x)
+			byteIndex := pixelIndex / 8
+			bitIndex := 7 - pixelIndex%8
+			screenBitBuffer[byteIndex] |= (state.screen.buffer[x][y] & 0b00000001) << bitIndex
+		}
+	}
+
+	message.Screen = screenBitBuffer
+
+	return message
+}
+
+func marshalMessage(message interface{}) ([]byte, error) {
+	return msgpack.Marshal(message)
+}
+
+func serializeMessage(message interface{}) []byte {
+	serializedMessage, err := msgpack.Marshal(message)
+	if err != nil {
+		fmt.Printf("Could not marshal data: %+v\n", message)
+	}
+
+	return serializedMessage
+}
+
+// decodeViewerDatagram decodes a msgpack datagram received from a viewer.
+// A datagram of exactly 2 bytes is the legacy (unversioned) big endian key state bitmask, and is decoded as an unsequenced keys message.
+func decodeViewerDatagram(datagram []byte) (*viewerMessage, error) {
+	if len(datagram) == legacyKeysDatagramSize {
+		keys := keysMessage{
+			messageHeader: newMessageHeader(messageTypeKeys, 0),
+			Keys:          (uint16(datagram[0]) << 8) | (uint16(datagram[1]) << 0),
+		}
+		return &viewerMessage{Header: keys.messageHeader, Keys: &keys}, nil
+	}
+
+	return decodeViewerMessage(datagram, msgpack.Unmarshal)
+}
+
+// decodeViewerMessage decodes a message received from a viewer, serialized in the format read by unmarshal (msgpack or JSON).
+func decodeViewerMessage(data []byte, unmarshal func(data []byte, v interface{}) error) (*viewerMessage, error) {
+	header := messageHeader{}
+	if err := unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("%w: %d bytes is not a protocol message: %s", errMalformedMessage, len(data), err.Error())
+	}
+
+	if header.Version != protocolVersion {
+		return nil, fmt.Errorf("%w: unsupported protocol version %d (expected version %d)", errMalformedMessage, header.Version, protocolVersion)
+	}
+
+	message := viewerMessage{Header: header}
+
+	switch header.Type {
+	case messageTypeHello:
+		message.Hello = &helloMessage{}
+		if err := unmarshal(data, message.Hello); err != nil {
+			return nil, fmt.Errorf("%w: illegal hello message: %s", errMalformedMessage, err.Error())
+		}
+	case messageTypeKeys:
+		message.Keys = &keysMessage{}
+		if err := unmarshal(data, message.Keys); err != nil {
+			return nil, fmt.Errorf("%w: illegal keys message: %s", errMalformedMessage, err.Error())
+		}
+	case messageTypeAck:
+		message.Ack = &ackMessage{}
+		if err := unmarshal(data, message.Ack); err != nil {
+			return nil, fmt.Errorf("%w: illegal ack message: %s", errMalformedMessage, err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown message type \"%s\"", errMalformedMessage, header.Type)
+	}
+
+	return &message, nil
+}
+
+// isNewerSequence reports if sequence number seq comes after sequence number previous,
+// using serial number arithmetic to handle wrap around (a sequence number is newer if at most 2^31 ahead).
+func isNewerSequence(seq, previous uint32) bool {
+	return int32(seq-previous) > 0
+}
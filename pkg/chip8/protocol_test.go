@@ -0,0 +1,39 @@
+package chip8
+
+import (
+	"errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"testing"
+)
+
+func TestDecodeViewerDatagram(t *testing.T) {
+	message, err := decodeViewerDatagram([]byte{0x12, 0x34})
+	if (err != nil) || (message.Keys == nil) || (message.Keys.Keys != 0x1234) || (message.Header.Seq != 0) {
+		t.Fatalf("expected legacy key state 0x1234, got %+v (%v)", message, err)
+	}
+
+	datagram, _ := msgpack.Marshal(&keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 7), Keys: 0x8001})
+	message, err = decodeViewerDatagram(datagram)
+	if (err != nil) || (message.Keys == nil) || (message.Keys.Keys != 0x8001) || (message.Header.Seq != 7) {
+		t.Fatalf("expected keys message with key state 0x8001, got %+v (%v)", message, err)
+	}
+
+	unsupportedVersion, _ := msgpack.Marshal(&keysMessage{messageHeader: messageHeader{Version: 99, Type: messageTypeKeys, Seq: 1}})
+	unknownType, _ := msgpack.Marshal(&messageHeader{Version: protocolVersion, Type: "launch", Seq: 1})
+
+	for _, malformedDatagram := range [][]byte{{}, {0x01}, {0x01, 0x02, 0x03}, unsupportedVersion, unknownType} {
+		if _, err := decodeViewerDatagram(malformedDatagram); !errors.Is(err, errMalformedMessage) {
+			t.Errorf("expected datagram %v to be rejected as malformed, got %v", malformedDatagram, err)
+		}
+	}
+}
+
+func TestIsNewerSequence(t *testing.T) {
+	if !isNewerSequence(2, 1) || isNewerSequence(1, 2) || isNewerSequence(5, 5) {
+		t.Fatalf("unexpected sequence order")
+	}
+
+	if !isNewerSequence(1, 0xFFFFFFFF) || (nextSequence(0xFFFFFFFF) != 1) {
+		t.Fatalf("unexpected sequence wrap around")
+	}
+}
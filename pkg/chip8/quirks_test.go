@@ -0,0 +1,57 @@
+package chip8
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// quirksTestROMFilepath is the quirks test of the sprite drawing, assembled from roms/test/quirks.asm
+const quirksTestROMFilepath = "../../roms/test/quirks.ch8"
+
+// TestQuirksROM runs the quirks test ROM for the machines and compares the quirks detected with the documented sprite
+// drawing of the platforms (see roms/test/quirks.txt).
+func TestQuirksROM(t *testing.T) {
+	if _, err := os.Stat(quirksTestROMFilepath); err != nil {
+		t.Fatalf("quirks test ROM \"%s\" not available: %s", quirksTestROMFilepath, err)
+	}
+
+	tests := []struct {
+		machine         string
+		clipping        uint8 // clipping is 1 if sprites wrap around the right and bottom edges, 0 if clipped
+		collisionRows   uint8 // collisionRows is VF after drawing a 4 rows sprite over itself
+		bottomClip      uint8 // bottomClip is VF after drawing a 4 rows sprite with 2 rows below the bottom edge
+		displayWait     uint8 // displayWait is 1 if a sprite draw waits for the vertical blank
+		startPosWrapped uint8 // startPosWrapped is 1 if the sprite start position wraps around the screen
+	}{
+		{"cosmac-vip", 0, 1, 0, 1, 1},
+		{"schip", 0, 4, 2, 0, 1},
+		{"xo-chip", 1, 1, 0, 0, 1},
+	}
+
+	for _, test := range tests {
+		machineProfile, err := MachineByName(test.machine)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		configuration := Configuration{ModeRomCompatibility: true, EndOnInfiniteLoop: true, CyclesPerFrame: 1000, Frames: 60, Headless: true}
+		machineProfile.ApplyQuirks(&configuration)
+
+		peripherals := NewHeadlessPeripherals()
+		machine := NewChip8ForMachine(&peripherals, machineProfile)
+		machine.LoadROM(quirksTestROMFilepath)
+
+		if err := machine.Run(configuration); !errors.Is(err, ErrInfiniteLoop) {
+			t.Fatalf("%s: expected the quirks test to end in its final loop, got error %v", test.machine, err)
+		}
+
+		expected := []uint8{test.clipping, test.collisionRows, test.bottomClip, test.displayWait, test.startPosWrapped}
+		names := []string{"clipping", "collision rows", "bottom clip", "display wait", "start position"}
+		for i, name := range names {
+			if machine.V[i] != expected[i] {
+				t.Errorf("%s: %s (V%X): expected %d, got %d", test.machine, name, i, expected[i], machine.V[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+)
+
+// gifMinimumDelay is the shortest GIF frame delay, in 1/100 of a second, shown as is by browsers
+const gifMinimumDelay = 2
+
+// Recorder captures the screen every frame. Identical consecutive frames are stored only once together with their duration.
+// The recording can be written as an animated GIF or as a sequence of PNG images (one image per 60 Hz frame).
+type Recorder struct {
+	scale      int
+	palette    Palette
+	screens    []ScreenBuffer
+	durations  []int // durations are the number of frames each screen is shown
+	firstFrame uint64
+}
+
+// NewRecorder creates a recorder of images where every screen pixel is scale by scale image pixels in size.
+func NewRecorder(scale int, palette Palette) *Recorder {
+	return &Recorder{
+		scale:   scale,
+		palette: palette,
+	}
+}
+
+// CaptureFrame records the screen content of a frame. It can be used directly as a frame listener.
+func (r *Recorder) CaptureFrame(frame uint64, screen *ScreenBuffer) {
+	if len(r.screens) == 0 {
+		r.firstFrame = frame
+	} else if r.screens[len(r.screens)-1] == *screen {
+		r.durations[len(r.durations)-1]++
+		return
+	}
+
+	r.screens = append(r.screens, *screen)
+	r.durations = append(r.durations, 1)
+}
+
+// WriteGIF writes the recording as an animated GIF.
+func (r *Recorder) WriteGIF(filepath string) error {
+	if len(r.screens) == 0 {
+		return fmt.Errorf("could not write recording \"%s\": no frames recorded", filepath)
+	}
+
+	animation := gif.GIF{}
+
+	// GIF frame delays are in 1/100 of a second, frames are 1/60 of a second. Browsers show delays shorter than
+	// gifMinimumDelay much slower, so every delay is at least gifMinimumDelay. The delays make up for the time
+	// rounded off, or added by the minimum, before them, to not drift from real time.
+	frameCount := 0
+	gifTime := 0 // gifTime is the time of the GIF frames so far, in 1/100 of a second
+	for i, screen := range r.screens {
+		frameCount += r.durations[i]
+		endTime := int(math.Round(float64(frameCount) * 100.0 / 60.0))
+		delay := endTime - gifTime
+		if delay < gifMinimumDelay {
+			delay = gifMinimumDelay
+		}
+		gifTime += delay
+
+		animation.Image = append(animation.Image, screen.Image(r.scale, r.palette))
+		animation.Delay = append(animation.Delay, delay)
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("could not create recording file \"%s\": %w", filepath, err)
+	}
+	defer file.Close()
+
+	if err := gif.EncodeAll(file, &animation); err != nil {
+		return fmt.Errorf("could not write recording file \"%s\": %w", filepath, err)
+	}
+
+	return nil
+}
+
+// WritePNGSequence writes the recording as one PNG image per frame.
+// The filepath pattern must contain a formatting verb for the frame number, for example "frames/frame%05d.png".
+func (r *Recorder) WritePNGSequence(filepathPattern string) error {
+	if !strings.Contains(filepathPattern, "%") {
+		return fmt.Errorf("PNG sequence file path \"%s\" lacks frame number formatting verb (like \"%%05d\")", filepathPattern)
+	}
+
+	frame := r.firstFrame
+	for i, screen := range r.screens {
+		var img image.Image = screen.Image(r.scale, r.palette)
+
+		for n := 0; n < r.durations[i]; n++ {
+			filepath := fmt.Sprintf(filepathPattern, frame)
+			if err := writePNG(filepath, img); err != nil {
+				return err
+			}
+			frame++
+		}
+	}
+
+	return nil
+}
+
+// Write writes the recording as an animated GIF if the file path ends with ".gif", otherwise as a PNG sequence.
+func (r *Recorder) Write(filepath string) error {
+	if strings.HasSuffix(strings.ToLower(filepath), ".gif") {
+		return r.WriteGIF(filepath)
+	}
+
+	return r.WritePNGSequence(filepath)
+}
+
+func writePNG(filepath string, img image.Image) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("could not create image file \"%s\": %w", filepath, err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return fmt.Errorf("could not write image file \"%s\": %w", filepath, err)
+	}
+
+	return nil
+}
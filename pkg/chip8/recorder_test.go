@@ -0,0 +1,133 @@
+package chip8
+
+import (
+	"bytes"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordScreens records a screen with the pixel (i, 0) set for every number of frames
+func recordScreens(frameCounts ...int) *Recorder {
+	recorder := NewRecorder(1, DefaultPalette)
+	frame := uint64(10)
+	for i, frames := range frameCounts {
+		screen := NewScreenBuffer()
+		screen.XorPixel(uint8(i), 0, 1)
+		for n := 0; n < frames; n++ {
+			recorder.CaptureFrame(frame, &screen)
+			frame++
+		}
+	}
+	return recorder
+}
+
+func TestRecorderStoresIdenticalFramesOnce(t *testing.T) {
+	recorder := recordScreens(3, 1, 2)
+
+	if (len(recorder.screens) != 3) || (recorder.firstFrame != 10) {
+		t.Fatalf("expected 3 screens from frame 10, got %d screens from frame %d", len(recorder.screens), recorder.firstFrame)
+	}
+	for i, expectedDuration := range []int{3, 1, 2} {
+		if recorder.durations[i] != expectedDuration {
+			t.Errorf("expected screen %d shown for %d frames, got %d", i, expectedDuration, recorder.durations[i])
+		}
+	}
+}
+
+func TestRecorderWriteGIFDelays(t *testing.T) {
+	recorder := recordScreens(1, 1, 1, 6, 60)
+	gifFilepath := filepath.Join(t.TempDir(), "recording.gif")
+	if err := recorder.Write(gifFilepath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	file, err := os.Open(gifFilepath)
+	if err != nil {
+		t.Fatalf("could not open recording: %s", err)
+	}
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatalf("could not decode recording: %s", err)
+	}
+
+	// One frame screens are shown for the minimum delay, the next screens make up for the time added
+	expectedDelays := []int{2, 2, 2, 9, 100}
+	if len(animation.Delay) != len(expectedDelays) {
+		t.Fatalf("expected delays %v, got %v", expectedDelays, animation.Delay)
+	}
+	totalDelay := 0
+	for i, delay := range animation.Delay {
+		if delay != expectedDelays[i] {
+			t.Errorf("expected delays %v, got %v", expectedDelays, animation.Delay)
+			break
+		}
+		totalDelay += delay
+	}
+	if totalDelay != 115 { // 69 frames at 60 Hz
+		t.Errorf("expected total delay 115, got %d", totalDelay)
+	}
+}
+
+func TestRecorderWriteGIFWithoutFrames(t *testing.T) {
+	if err := NewRecorder(1, DefaultPalette).WriteGIF(filepath.Join(t.TempDir(), "recording.gif")); err == nil {
+		t.Fatal("expected error writing empty recording")
+	}
+}
+
+func TestRecorderWritePNGSequence(t *testing.T) {
+	recorder := recordScreens(2, 1)
+	directory := t.TempDir()
+	if err := recorder.Write(filepath.Join(directory, "frame%03d.png")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(directory, "*.png"))
+	if len(files) != 3 {
+		t.Fatalf("expected one PNG per frame, got %v", files)
+	}
+	for _, name := range []string{"frame010.png", "frame011.png", "frame012.png"} {
+		if _, err := os.Stat(filepath.Join(directory, name)); err != nil {
+			t.Errorf("expected frame image \"%s\": %s", name, err)
+		}
+	}
+
+	if err := recorder.WritePNGSequence(filepath.Join(directory, "frame.png")); err == nil {
+		t.Error("expected error for file path without frame number verb")
+	}
+}
+
+// recordHeadless records a headless execution of BRIX with the seed as GIF, returning the GIF file.
+func recordHeadless(t *testing.T, seed int64) []byte {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.LoadROM("../../roms/BRIX.ch8")
+
+	recorder := NewRecorder(1, DefaultPalette)
+	machine.AddFrameListener(recorder.CaptureFrame)
+	if err := machine.Run(Configuration{ModeRomCompatibility: true, Frames: 600, Headless: true, Seed: seed}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	gifFilepath := filepath.Join(t.TempDir(), "recording.gif")
+	if err := recorder.WriteGIF(gifFilepath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	gifBytes, err := os.ReadFile(gifFilepath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return gifBytes
+}
+
+func TestHeadlessRecordingIsReproducible(t *testing.T) {
+	first := recordHeadless(t, 7)
+	if second := recordHeadless(t, 7); !bytes.Equal(first, second) {
+		t.Error("expected identical recordings of headless executions with the same seed")
+	}
+	if other := recordHeadless(t, 8); bytes.Equal(first, other) {
+		t.Error("expected different recordings of headless executions with different seeds")
+	}
+}
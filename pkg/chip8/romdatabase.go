@@ -0,0 +1,287 @@
+package chip8
+
+import (
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The ROM database uses the JSON format of the community CHIP-8 database (https://github.com/chip-8/chip-8-database):
+// programs.json is a list of programs with their ROMs by SHA-1 hash, and platforms.json is the platforms with their quirks.
+
+//go:embed romdb
+var romDatabaseContent embed.FS
+
+// ROMDatabase is metadata of known ROMs, keyed by the SHA-1 hash of the ROM.
+type ROMDatabase struct {
+	programs  []romDatabaseProgram
+	platforms map[string]romDatabasePlatform
+	hashes    map[string]int // hashes are the index of the program by ROM hash
+}
+
+type romDatabaseProgram struct {
+	Title       string                    `json:"title"`
+	Description string                    `json:"description"`
+	Authors     []string                  `json:"authors"`
+	Release     string                    `json:"release"`
+	ROMs        map[string]romDatabaseROM `json:"roms"`
+}
+
+type romDatabaseROM struct {
+	File            string                       `json:"file"`
+	Platforms       []string                     `json:"platforms"`
+	QuirkyPlatforms map[string]romDatabaseQuirks `json:"quirkyPlatforms"`
+	Tickrate        int                          `json:"tickrate"`
+	Keys            map[string]int               `json:"keys"`
+	Colors          *struct {
+		Pixels []string `json:"pixels"`
+	} `json:"colors"`
+}
+
+type romDatabasePlatform struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	DefaultTickrate int               `json:"defaultTickrate"`
+	Quirks          romDatabaseQuirks `json:"quirks"`
+}
+
+// romDatabaseQuirks are the quirks of a platform, or the quirks of a ROM overriding its platform (absent quirks are not overridden)
+type romDatabaseQuirks struct {
+	Shift                 *bool `json:"shift"`                 // Shift is true if 8XY6/8XYE shift VX in place, rather than VY into VX
+	MemoryIncrementByX    *bool `json:"memoryIncrementByX"`    // MemoryIncrementByX is true if FX55/FX65 increment I by X, rather than X+1
+	MemoryLeaveIUnchanged *bool `json:"memoryLeaveIUnchanged"` // MemoryLeaveIUnchanged is true if FX55/FX65 leave I unchanged
+	Wrap                  *bool `json:"wrap"`                  // Wrap is true if sprites wrap around the screen edges, rather than being clipped
+	Jump                  *bool `json:"jump"`                  // Jump is true if BNNN jumps to NNN plus VX (X being the highest nibble of NNN), rather than V0
+	Vblank                *bool `json:"vblank"`                // Vblank is true if DXYN waits for the vertical blank
+	Logic                 *bool `json:"logic"`                 // Logic is true if 8XY1/8XY2/8XY3 reset VF (not supported by the interpreter)
+}
+
+// ROMInfo is the database metadata of a ROM.
+type ROMInfo struct {
+	SHA1        string
+	Title       string
+	Description string
+	Authors     []string
+	Release     string
+	Platform    string // Platform is the platform id of the database, like "originalChip8"
+	Tickrate    int    // Tickrate is the number of instructions per frame, 0 if unknown
+	Palette     Palette
+	Keys        map[string]uint8 // Keys are the hex keys of the game buttons, like "left" and "a" (as in the database)
+	quirks      romDatabaseQuirks
+}
+
+// DefaultROMDatabase is the ROM database embedded in the interpreter.
+func DefaultROMDatabase() *ROMDatabase {
+	programs, _ := romDatabaseContent.ReadFile("romdb/programs.json")
+	database, err := newROMDatabase(programs)
+	if err != nil {
+		panic(err) // The embedded database is verified by the tests
+	}
+	return database
+}
+
+// LoadROMDatabase reads a ROM database from a programs.json file of the community CHIP-8 database.
+// The platforms are those of the embedded database.
+func LoadROMDatabase(programsFilepath string) (*ROMDatabase, error) {
+	programs, err := os.ReadFile(programsFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ROM database \"%s\": %w", programsFilepath, err)
+	}
+
+	database, err := newROMDatabase(programs)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ROM database \"%s\": %w", programsFilepath, err)
+	}
+	return database, nil
+}
+
+func newROMDatabase(programs []byte) (*ROMDatabase, error) {
+	database := ROMDatabase{
+		platforms: map[string]romDatabasePlatform{},
+		hashes:    map[string]int{},
+	}
+
+	if err := json.Unmarshal(programs, &database.programs); err != nil {
+		return nil, fmt.Errorf("illegal programs: %w", err)
+	}
+
+	platformsContent, _ := romDatabaseContent.ReadFile("romdb/platforms.json")
+	var platforms []romDatabasePlatform
+	if err := json.Unmarshal(platformsContent, &platforms); err != nil {
+		return nil, fmt.Errorf("illegal platforms: %w", err)
+	}
+	for _, platform := range platforms {
+		database.platforms[platform.ID] = platform
+	}
+
+	for programIndex, program := range database.programs {
+		for hash := range program.ROMs {
+			database.hashes[strings.ToLower(hash)] = programIndex
+		}
+	}
+
+	return &database, nil
+}
+
+// Lookup finds the metadata of the ROM, by its SHA-1 hash.
+func (d *ROMDatabase) Lookup(romBytes []byte) (*ROMInfo, bool) {
+	hashBytes := sha1.Sum(romBytes)
+	hash := hex.EncodeToString(hashBytes[:])
+
+	programIndex, found := d.hashes[hash]
+	if !found {
+		return nil, false
+	}
+
+	program := d.programs[programIndex]
+	rom := romByHash(program.ROMs, hash)
+
+	info := ROMInfo{
+		SHA1:        hash,
+		Title:       program.Title,
+		Description: program.Description,
+		Authors:     program.Authors,
+		Release:     program.Release,
+		Tickrate:    rom.Tickrate,
+		Keys:        map[string]uint8{},
+	}
+
+	if len(rom.Platforms) > 0 {
+		info.Platform = rom.Platforms[0]
+		platform := d.platforms[info.Platform]
+		info.quirks = platform.Quirks
+		if info.Tickrate == 0 {
+			info.Tickrate = platform.DefaultTickrate
+		}
+		info.quirks = info.quirks.overriddenBy(rom.QuirkyPlatforms[info.Platform])
+	}
+
+	for button, hexKey := range rom.Keys {
+		if (hexKey >= 0) && (hexKey <= 0xF) {
+			info.Keys[button] = uint8(hexKey)
+		}
+	}
+
+	if (rom.Colors != nil) && (len(rom.Colors.Pixels) >= 2) {
+		colors := make([]string, 0, len(rom.Colors.Pixels))
+		for _, color := range rom.Colors.Pixels {
+			colors = append(colors, strings.TrimPrefix(color, "#"))
+		}
+		if palette, err := ParsePalette(strings.Join(colors, ",")); err == nil {
+			info.Palette = palette
+		}
+	}
+
+	return &info, true
+}
+
+func romByHash(roms map[string]romDatabaseROM, hash string) romDatabaseROM {
+	for romHash, rom := range roms {
+		if strings.ToLower(romHash) == hash {
+			return rom
+		}
+	}
+	return romDatabaseROM{}
+}
+
+func (q romDatabaseQuirks) overriddenBy(overrides romDatabaseQuirks) romDatabaseQuirks {
+	for _, quirk := range []struct{ quirk, override **bool }{
+		{&q.Shift, &overrides.Shift},
+		{&q.MemoryIncrementByX, &overrides.MemoryIncrementByX},
+		{&q.MemoryLeaveIUnchanged, &overrides.MemoryLeaveIUnchanged},
+		{&q.Wrap, &overrides.Wrap},
+		{&q.Jump, &overrides.Jump},
+		{&q.Vblank, &overrides.Vblank},
+		{&q.Logic, &overrides.Logic},
+	} {
+		if *quirk.override != nil {
+			*quirk.quirk = *quirk.override
+		}
+	}
+	return q
+}
+
+// romPlatformMachines are the machines of the platforms of the community CHIP-8 database, by platform id
+var romPlatformMachines = map[string]string{
+	"originalChip8": "cosmac-vip",
+	"hybridVIP":     "cosmac-vip",
+	"modernChip8":   "cosmac-vip",
+	"chip8x":        "cosmac-vip",
+	"chip48":        "schip",
+	"superchip1":    "schip",
+	"superchip":     "schip",
+	"megachip8":     "schip",
+	"xochip":        "xo-chip",
+}
+
+// Machine is the machine of the ROM platform, the default machine for unknown platforms.
+func (i *ROMInfo) Machine() Machine {
+	machine, err := MachineByName(romPlatformMachines[i.Platform])
+	if err != nil {
+		return DefaultMachine()
+	}
+	return machine
+}
+
+// Apply sets the quirks, speed and colors of the ROM in the configuration.
+//
+// The shift, memory and jump quirks of the database map onto the interpreter modes: strict COSMAC mode shifts VY into VX,
+// and ROM compatibility mode leaves I unchanged by FX55/FX65 and jumps to NNN plus V0 in BNNN.
+// The logic quirk (VF reset by 8XY1/8XY2/8XY3) is not supported.
+func (i *ROMInfo) Apply(configuration *Configuration) {
+	quirks := i.quirks
+
+	if quirks.Vblank != nil {
+		configuration.QuirkDisplayWait = *quirks.Vblank
+	}
+	if quirks.Wrap != nil {
+		configuration.QuirkWrapSprites = *quirks.Wrap
+	}
+	if quirks.Shift != nil {
+		configuration.ModeStrictCosmac = !*quirks.Shift
+	}
+
+	jump := (quirks.Jump != nil) && *quirks.Jump
+	memoryIncrements := (quirks.MemoryLeaveIUnchanged != nil) && !*quirks.MemoryLeaveIUnchanged
+	if (quirks.Jump != nil) || (quirks.MemoryLeaveIUnchanged != nil) {
+		configuration.ModeRomCompatibility = !jump && !(configuration.ModeStrictCosmac && memoryIncrements)
+	}
+
+	if i.Tickrate > 0 {
+		configuration.CyclesPerFrame = i.Tickrate
+	}
+	if i.Palette != nil {
+		configuration.Palette = i.Palette
+	}
+}
+
+// Keymap is the keymap suggested by the ROM buttons: the default keymap, plus the arrow keys for the
+// directional buttons and space and enter for the "a" and "b" buttons.
+func (i *ROMInfo) Keymap() (Keymap, bool) {
+	if len(i.Keys) == 0 {
+		return Keymap{}, false
+	}
+
+	keyNames := map[string]string{"up": "up", "down": "down", "left": "left", "right": "right", "a": "space", "b": "enter"}
+
+	keymap := DefaultKeymap()
+	keymap.Name = i.Title
+	for button, hexKey := range i.Keys {
+		if keyName, mapped := keyNames[button]; mapped {
+			keymap.keys[keyName] = hexKey
+		}
+	}
+	return keymap, true
+}
+
+// String is the title and authors of the ROM.
+func (i *ROMInfo) String() string {
+	if len(i.Authors) == 0 {
+		return i.Title
+	}
+	return fmt.Sprintf("%s [%s]", i.Title, strings.Join(i.Authors, ", "))
+}
@@ -0,0 +1,124 @@
+package chip8
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestROMDatabaseConfiguresKnownROM(t *testing.T) {
+	romBytes, err := os.ReadFile("../../roms/BRIX.ch8")
+	if err != nil {
+		t.Fatalf("could not read ROM: %s", err)
+	}
+
+	romInfo, found := DefaultROMDatabase().Lookup(romBytes)
+	if !found {
+		t.Fatalf("expected BRIX.ch8 in the ROM database")
+	}
+	if (romInfo.Title != "Brix") || (romInfo.Platform != "originalChip8") || (romInfo.Machine().Name != "cosmac-vip") {
+		t.Errorf("expected Brix on the COSMAC VIP, got %s on %s (%s)", romInfo, romInfo.Platform, romInfo.Machine().Name)
+	}
+
+	configuration := Configuration{ModeRomCompatibility: true, CyclesPerFrame: 6}
+	romInfo.Apply(&configuration)
+	if !configuration.QuirkDisplayWait || configuration.QuirkWrapSprites || !configuration.ModeStrictCosmac || configuration.ModeRomCompatibility {
+		t.Errorf("expected the quirks of the COSMAC VIP, got %+v", configuration)
+	}
+	if configuration.CyclesPerFrame != 15 {
+		t.Errorf("expected 15 cycles per frame, got %d", configuration.CyclesPerFrame)
+	}
+
+	keymap, suggested := romInfo.Keymap()
+	if !suggested {
+		t.Fatalf("expected a suggested keymap")
+	}
+	for keyName, expectedHexKey := range map[string]uint8{"left": 0x4, "right": 0x6, "q": 0x4, "e": 0x6} {
+		if hexKey, mapped := keymap.HexKey(keyName); !mapped || (hexKey != expectedHexKey) {
+			t.Errorf("expected key \"%s\" mapped to %X, got %X (mapped %v)", keyName, expectedHexKey, hexKey, mapped)
+		}
+	}
+
+	if _, found := DefaultROMDatabase().Lookup([]byte{0x12, 0x00}); found {
+		t.Errorf("expected unknown ROM not to be found")
+	}
+}
+
+func TestLoadROMDatabaseWithQuirkyPlatformAndColors(t *testing.T) {
+	programs := `[{"title": "Test", "authors": ["A", "B"], "roms": {"DA39A3EE5E6B4B0D3255BFEF95601890AFD80709": {
+		"platforms": ["modernChip8"], "quirkyPlatforms": {"modernChip8": {"wrap": true}}, "tickrate": 20,
+		"colors": {"pixels": ["#102030", "#405060"]}}}}]`
+	programsFilepath := filepath.Join(t.TempDir(), "programs.json")
+	os.WriteFile(programsFilepath, []byte(programs), 0o644)
+
+	romDatabase, err := LoadROMDatabase(programsFilepath)
+	if err != nil {
+		t.Fatalf("could not load ROM database: %s", err)
+	}
+
+	romInfo, found := romDatabase.Lookup([]byte{}) // The SHA-1 hash of no bytes, in upper case in the database
+	if !found {
+		t.Fatalf("expected ROM in the ROM database")
+	}
+	if romInfo.String() != "Test [A, B]" {
+		t.Errorf("expected \"Test [A, B]\", got \"%s\"", romInfo)
+	}
+
+	configuration := Configuration{}
+	romInfo.Apply(&configuration)
+	if !configuration.QuirkWrapSprites || configuration.QuirkDisplayWait || configuration.ModeStrictCosmac || !configuration.ModeRomCompatibility {
+		t.Errorf("expected the quirks of modern CHIP-8 with wrapping sprites, got %+v", configuration)
+	}
+	if configuration.CyclesPerFrame != 20 {
+		t.Errorf("expected 20 cycles per frame, got %d", configuration.CyclesPerFrame)
+	}
+	if len(configuration.Palette) != 2 {
+		t.Errorf("expected the 2 colors of the ROM, got %v", configuration.Palette)
+	} else if red, _, _, _ := configuration.Palette[1].RGBA(); red>>8 != 0x40 {
+		t.Errorf("expected foreground color 405060, got %v", configuration.Palette[1])
+	}
+	if _, suggested := romInfo.Keymap(); suggested {
+		t.Errorf("expected no keymap for ROM without keys")
+	}
+
+	os.WriteFile(programsFilepath, []byte("{"), 0o644)
+	if _, err := LoadROMDatabase(programsFilepath); err == nil {
+		t.Errorf("expected error for illegal ROM database")
+	}
+}
+
+func TestROMDatabasePlatformsHaveMachines(t *testing.T) {
+	database := DefaultROMDatabase()
+	for _, id := range []string{"originalChip8", "hybridVIP", "modernChip8", "chip8x", "chip48", "superchip1", "superchip", "megachip8", "xochip"} {
+		if _, defined := database.platforms[id]; !defined {
+			t.Errorf("expected platform \"%s\" in platforms.json", id)
+		}
+	}
+
+	for id, platform := range database.platforms {
+		machineName, mapped := romPlatformMachines[id]
+		if !mapped {
+			t.Errorf("expected a machine for platform \"%s\"", id)
+		}
+		if machine := (&ROMInfo{Platform: id}).Machine(); machine.Name != machineName {
+			t.Errorf("expected platform \"%s\" on machine %s, got %s", id, machineName, machine.Name)
+		}
+		if platform.DefaultTickrate <= 0 {
+			t.Errorf("expected a tickrate for platform \"%s\"", id)
+		}
+	}
+
+	for _, program := range database.programs {
+		for hash, rom := range program.ROMs {
+			for _, platform := range rom.Platforms {
+				if _, defined := database.platforms[platform]; !defined {
+					t.Errorf("ROM %s of \"%s\": unknown platform \"%s\"", hash, program.Title, platform)
+				}
+			}
+		}
+	}
+
+	if machine := (&ROMInfo{Platform: "unknown"}).Machine(); machine.Name != DefaultMachine().Name {
+		t.Errorf("expected the default machine for an unknown platform, got %s", machine.Name)
+	}
+}
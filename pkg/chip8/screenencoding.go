@@ -0,0 +1,131 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/flate"
+)
+
+const (
+	screenEncodingFull = "full" // screenEncodingFull is the complete screen, a keyframe
+	screenEncodingXor  = "xor"  // screenEncodingXor is the screen XOR:ed with the acknowledged screen of the base message
+
+	screenCompressionDeflate = "deflate" // screenCompressionDeflate is raw deflate (RFC 1951) compression of the (encoded) screen
+)
+
+// screenKeyframeInterval is the maximum number of screen updates between keyframes (full screens)
+const screenKeyframeInterval = 120
+
+// screenHistorySize is the number of sent screens kept as possible base for XOR delta encoding
+const screenHistorySize = 64
+
+// screenEncoder encodes the screen of state messages to a viewer, as keyframes or as XOR deltas against the latest screen
+// acknowledged by the viewer, optionally compressed. Encodings are only used if the viewer announced support for them.
+//
+// XOR deltas are only sent deflate compressed: uncompressed they are the size of the full screen, and only the runs
+// of zero bytes of unchanged screen areas make them compress well.
+type screenEncoder struct {
+	xor               bool              // xor is true if the viewer supports XOR delta encoding
+	deflate           bool              // deflate is true if the viewer supports deflate compression (and compression is enabled)
+	compression       bool              // compression enables deflate compression for viewers supporting it
+	history           map[uint32][]byte // history is the raw screens recently sent, by message sequence number
+	historySeqs       []uint32          // historySeqs is the sequence numbers in history, oldest first
+	acknowledgedSeq   uint32            // acknowledgedSeq is the sequence number of the latest screen acknowledged by the viewer (0 for none)
+	updatesSinceKey   int               // updatesSinceKey is the number of screen updates since the latest keyframe
+	compressionBuffer bytes.Buffer      // compressionBuffer is reused between compressions
+	compressionWriter *flate.Writer     // compressionWriter is reused between compressions
+}
+
+func newScreenEncoder(compression bool) *screenEncoder {
+	return &screenEncoder{
+		compression: compression,
+		history:     map[uint32][]byte{},
+	}
+}
+
+// negotiate enables the encodings supported by the viewer, and restarts encoding with a keyframe.
+func (e *screenEncoder) negotiate(hello *helloMessage) {
+	e.xor = false
+	e.deflate = false
+	for _, encoding := range hello.Encodings {
+		switch encoding {
+		case screenEncodingXor:
+			e.xor = true
+		case screenCompressionDeflate:
+			e.deflate = e.compression
+		}
+	}
+
+	e.history = map[uint32][]byte{}
+	e.historySeqs = nil
+	e.acknowledgedSeq = 0
+}
+
+// acknowledge registers that the viewer received the screen of the message with the sequence number.
+func (e *screenEncoder) acknowledge(seq uint32) {
+	if _, known := e.history[seq]; !known {
+		return
+	}
+
+	if (e.acknowledgedSeq == 0) || isNewerSequence(seq, e.acknowledgedSeq) {
+		e.acknowledgedSeq = seq
+	}
+}
+
+// encode encodes the screen of a state message that has its sequence number set.
+func (e *screenEncoder) encode(message *peripheralStateMessage) {
+	if message.Screen == nil {
+		return
+	}
+
+	screen := message.Screen
+	e.remember(message.Seq, screen)
+
+	message.Encoding = screenEncodingFull
+	baseScreen, hasBase := e.history[e.acknowledgedSeq]
+	if e.xor && e.deflate && hasBase && (len(baseScreen) == len(screen)) && (e.updatesSinceKey < screenKeyframeInterval) {
+		delta := make([]byte, len(screen))
+		for i := range screen {
+			delta[i] = screen[i] ^ baseScreen[i]
+		}
+
+		message.Encoding = screenEncodingXor
+		message.Base = e.acknowledgedSeq
+		message.Screen = delta
+		e.updatesSinceKey++
+	} else {
+		e.updatesSinceKey = 0
+	}
+
+	if e.deflate {
+		message.Screen = e.compress(message.Screen)
+		message.Compression = screenCompressionDeflate
+	}
+}
+
+func (e *screenEncoder) remember(seq uint32, screen []byte) {
+	e.history[seq] = screen
+	e.historySeqs = append(e.historySeqs, seq)
+
+	if len(e.historySeqs) > screenHistorySize {
+		oldestSeq := e.historySeqs[0]
+		e.historySeqs = e.historySeqs[1:]
+		delete(e.history, oldestSeq)
+		if oldestSeq == e.acknowledgedSeq {
+			e.acknowledgedSeq = 0
+		}
+	}
+}
+
+func (e *screenEncoder) compress(data []byte) []byte {
+	e.compressionBuffer.Reset()
+	if e.compressionWriter == nil {
+		e.compressionWriter, _ = flate.NewWriter(&e.compressionBuffer, flate.BestSpeed)
+	} else {
+		e.compressionWriter.Reset(&e.compressionBuffer)
+	}
+
+	e.compressionWriter.Write(data)
+	e.compressionWriter.Close()
+
+	return append([]byte(nil), e.compressionBuffer.Bytes()...)
+}
@@ -0,0 +1,170 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+)
+
+// byteCountingFrontend counts the bytes of the serialized messages a viewer would receive.
+// The viewer acknowledges every screen immediately.
+type byteCountingFrontend struct {
+	encoder *screenEncoder
+	seq     uint32
+	bytes   int
+}
+
+func (f *byteCountingFrontend) Start(p *Peripherals) error { return nil }
+func (f *byteCountingFrontend) Close() error               { return nil }
+
+func (f *byteCountingFrontend) UpdateScreen(state *PeripheralsState) {
+	f.send(getScreenMessage(state))
+}
+
+func (f *byteCountingFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.send(getSoundAndKeysMessage(state))
+}
+
+func (f *byteCountingFrontend) send(message *peripheralStateMessage) {
+	f.seq = nextSequence(f.seq)
+	message.setSeq(f.seq)
+	f.encoder.encode(message)
+	f.bytes += len(serializeMessage(message))
+	f.encoder.acknowledge(message.Seq)
+}
+
+func loadBenchmarkROM(b *testing.B, peripherals *Peripherals) *Chip8 {
+	machine := NewChip8(peripherals)
+	machine.SeedRandom(1) // The same game every run
+	romBytes := loadByteFile("../../roms/BRIX.ch8")
+	if err := machine.loadROMBytes(romBytes, romAddressDefault); err != nil {
+		b.Fatal(err)
+	}
+	return machine
+}
+
+// BenchmarkScreenUpdateBytes reports the bytes per second sent to a viewer during 10 seconds of BRIX,
+// for screen updates on every draw instruction (as before frame coalescing) and once per frame with the different encodings.
+func BenchmarkScreenUpdateBytes(b *testing.B) {
+	const frames = 600
+	configuration := Configuration{ModeRomCompatibility: true, CyclesPerFrame: 15}
+
+	b.Run("every-draw-full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			frontend := &byteCountingFrontend{encoder: newScreenEncoder(false)}
+			peripherals := NewPeripheralsWithFrontend(frontend)
+			machine := loadBenchmarkROM(b, &peripherals)
+
+			for frame := 0; frame < frames; frame++ {
+				for cycle := 0; cycle < configuration.CyclesPerFrame; cycle++ {
+					instructionCode := uint16(machine.readMemory(machine.PC))<<8 | uint16(machine.readMemory(machine.PC+1))
+					if err := machine.Step(configuration); err != nil {
+						b.Fatal(err)
+					}
+					if (instructionCode&0xF000 == 0xD000) || (instructionCode == 0x00E0) {
+						peripherals.UpdateScreen()
+					}
+				}
+				machine.screenChanged = false
+				machine.endFrame()
+			}
+
+			b.ReportMetric(float64(frontend.bytes)/(frames/60.0), "bytes/s")
+		}
+	})
+
+	encodings := []struct {
+		name      string
+		encodings []string
+	}{
+		{"frame-full", nil},
+		{"frame-xor", []string{screenEncodingXor}},
+		{"frame-deflate", []string{screenCompressionDeflate}},
+		{"frame-xor-deflate", []string{screenEncodingXor, screenCompressionDeflate}},
+	}
+
+	for _, encoding := range encodings {
+		b.Run(encoding.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				frontend := &byteCountingFrontend{encoder: newScreenEncoder(true)}
+				frontend.encoder.negotiate(&helloMessage{Encodings: encoding.encodings})
+				peripherals := NewPeripheralsWithFrontend(frontend)
+				machine := loadBenchmarkROM(b, &peripherals)
+
+				configuration.Frames = frames
+				configuration.Headless = true
+				if err := machine.Run(configuration); err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportMetric(float64(frontend.bytes)/(frames/60.0), "bytes/s")
+			}
+		})
+	}
+}
+
+func TestScreenEncoderXorDeltaAgainstAcknowledgedScreen(t *testing.T) {
+	encoder := newScreenEncoder(true)
+	encoder.negotiate(&helloMessage{Encodings: []string{screenEncodingXor, screenCompressionDeflate}})
+
+	first := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 1), Screen: []byte{0x0F, 0xF0}}
+	encoder.encode(first)
+	if first.Encoding != screenEncodingFull {
+		t.Fatalf("expected first screen as keyframe, got encoding \"%s\"", first.Encoding)
+	}
+
+	// Not acknowledged, still a keyframe
+	second := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 2), Screen: []byte{0xFF, 0xF0}}
+	encoder.encode(second)
+	if second.Encoding != screenEncodingFull {
+		t.Fatalf("expected unacknowledged screen as keyframe, got encoding \"%s\"", second.Encoding)
+	}
+
+	encoder.acknowledge(1)
+	third := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 3), Screen: []byte{0xFF, 0xF1}}
+	encoder.encode(third)
+	if (third.Encoding != screenEncodingXor) || (third.Base != 1) || (third.Compression != screenCompressionDeflate) {
+		t.Fatalf("expected deflated XOR delta against screen 1, got %+v", third)
+	}
+
+	delta, err := io.ReadAll(flate.NewReader(bytes.NewReader(third.Screen)))
+	if err != nil {
+		t.Fatalf("could not inflate screen: %s", err)
+	}
+	if !bytes.Equal(delta, []byte{0xF0, 0x01}) {
+		t.Fatalf("unexpected XOR delta %v", delta)
+	}
+}
+
+func TestScreenEncoderXorDeltaOnlyCompressed(t *testing.T) {
+	for _, compression := range []bool{false, true} {
+		encoder := newScreenEncoder(compression)
+		encoder.negotiate(&helloMessage{Encodings: []string{screenEncodingXor, screenCompressionDeflate}})
+
+		first := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 1), Screen: []byte{0x0F, 0xF0}}
+		encoder.encode(first)
+		encoder.acknowledge(1)
+		second := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 2), Screen: []byte{0xFF, 0xF0}}
+		encoder.encode(second)
+
+		expectedEncoding := screenEncodingFull
+		if compression {
+			expectedEncoding = screenEncodingXor
+		}
+		if second.Encoding != expectedEncoding {
+			t.Errorf("expected encoding \"%s\" with compression %v, got \"%s\"", expectedEncoding, compression, second.Encoding)
+		}
+	}
+
+	encoder := newScreenEncoder(true)
+	encoder.negotiate(&helloMessage{Encodings: []string{screenEncodingXor}})
+	first := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 1), Screen: []byte{0x0F, 0xF0}}
+	encoder.encode(first)
+	encoder.acknowledge(1)
+	second := &peripheralStateMessage{messageHeader: newMessageHeader(messageTypeState, 2), Screen: []byte{0xFF, 0xF0}}
+	encoder.encode(second)
+	if (second.Encoding != screenEncodingFull) || (second.Compression != "") {
+		t.Errorf("expected uncompressed keyframe for viewer without deflate, got %+v", second)
+	}
+}
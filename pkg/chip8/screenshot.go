@@ -0,0 +1,131 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Palette maps pixel values to colors. Index 0 is the background color and index 1 the foreground color.
+// Further indices are reserved for additional bit planes (XO-CHIP).
+type Palette []color.Color
+
+// DefaultPalette is black background and white foreground
+var DefaultPalette = Palette{
+	color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xFF},
+	color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+}
+
+// ParsePalette parses a comma separated list of hexadecimal RGB colors, background color first. Example: "000000,FFFFFF".
+func ParsePalette(paletteText string) (Palette, error) {
+	var palette Palette
+
+	for _, colorText := range strings.Split(paletteText, ",") {
+		colorText = strings.TrimPrefix(strings.TrimSpace(colorText), "#")
+
+		rgb, err := strconv.ParseUint(colorText, 16, 32)
+		if (err != nil) || (len(colorText) != 6) {
+			return nil, fmt.Errorf("illegal color \"%s\" in palette \"%s\" (expected hexadecimal RGB format \"RRGGBB\")", colorText, paletteText)
+		}
+
+		palette = append(palette, color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb >> 0), A: 0xFF})
+	}
+
+	if len(palette) < 2 {
+		return nil, fmt.Errorf("palette \"%s\" need at least a background and a foreground color", paletteText)
+	}
+
+	return palette, nil
+}
+
+// String is the palette as a comma separated list of hexadecimal RGB colors, as parsed by ParsePalette.
+func (p Palette) String() string {
+	if len(p) < 2 {
+		p = DefaultPalette
+	}
+
+	colorTexts := make([]string, len(p))
+	for i, c := range p {
+		red, green, blue, _ := c.RGBA()
+		colorTexts[i] = fmt.Sprintf("%02X%02X%02X", red>>8, green>>8, blue>>8)
+	}
+	return strings.Join(colorTexts, ",")
+}
+
+// Image renders the screen buffer to an image where every screen pixel is scale by scale image pixels in size.
+func (s *ScreenBuffer) Image(scale int, palette Palette) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	if len(palette) < 2 {
+		palette = DefaultPalette
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, int(s.Width)*scale, int(s.Height)*scale), color.Palette(palette))
+
+	for y := 0; y < int(s.Height); y++ {
+		for x := 0; x < int(s.Width); x++ {
+			colorIndex := s.Value(uint8(x), uint8(y))
+			if int(colorIndex) >= len(palette) {
+				colorIndex = 1
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				offset := img.PixOffset(x*scale, y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[offset+dx] = colorIndex
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the screen buffer as a PNG image to file.
+func (s *ScreenBuffer) WritePNG(filepath string, scale int, palette Palette) error {
+	return writePNG(filepath, s.Image(scale, palette))
+}
+
+// DiffPNG compares the screen buffer with a PNG screenshot (of any scale) and returns the number of differing screen pixels.
+// Screenshot pixels of the background color of the palette are unlit, any other color is lit.
+func (s *ScreenBuffer) DiffPNG(filepath string, palette Palette) (int, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return 0, fmt.Errorf("could not open image file \"%s\": %w", filepath, err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("could not read image file \"%s\": %w", filepath, err)
+	}
+
+	bounds := img.Bounds()
+	scale := bounds.Dx() / int(s.Width)
+	if (scale < 1) || (bounds.Dx() != int(s.Width)*scale) || (bounds.Dy() != int(s.Height)*scale) {
+		return 0, fmt.Errorf("image file \"%s\" of size %dx%d is not a screenshot of the %dx%d screen", filepath, bounds.Dx(), bounds.Dy(), s.Width, s.Height)
+	}
+
+	if len(palette) < 2 {
+		palette = DefaultPalette
+	}
+	backgroundR, backgroundG, backgroundB, _ := palette[0].RGBA()
+
+	differingPixels := 0
+	for y := 0; y < int(s.Height); y++ {
+		for x := 0; x < int(s.Width); x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*scale+scale/2, bounds.Min.Y+y*scale+scale/2).RGBA()
+			lit := (r != backgroundR) || (g != backgroundG) || (b != backgroundB)
+			if lit != (s.Value(uint8(x), uint8(y)) != 0) {
+				differingPixels++
+			}
+		}
+	}
+
+	return differingPixels, nil
+}
@@ -0,0 +1,68 @@
+package chip8
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	palette, err := ParsePalette("#102030, FFFFFF")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if palette[0] != (color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xFF}) {
+		t.Fatalf("unexpected background color %+v", palette[0])
+	}
+	if palette.String() != "102030,FFFFFF" {
+		t.Errorf("expected palette \"102030,FFFFFF\", got \"%s\"", palette)
+	}
+
+	for _, illegalPaletteText := range []string{"", "000000", "000000,FFFFFZ", "000000,FFF"} {
+		if _, err := ParsePalette(illegalPaletteText); err == nil {
+			t.Errorf("expected error for palette \"%s\"", illegalPaletteText)
+		}
+	}
+}
+
+func TestScreenBufferImage(t *testing.T) {
+	screen := NewScreenBuffer()
+	screen.XorPixel(63, 31, 1)
+
+	img := screen.Image(2, DefaultPalette)
+
+	if (img.Bounds().Dx() != 128) || (img.Bounds().Dy() != 64) {
+		t.Fatalf("unexpected image size %v", img.Bounds())
+	}
+
+	if img.ColorIndexAt(127, 63) != 1 || img.ColorIndexAt(126, 62) != 1 || img.ColorIndexAt(125, 63) != 0 {
+		t.Fatalf("unexpected pixel values in scaled image")
+	}
+}
+
+func TestScreenBufferDiffPNG(t *testing.T) {
+	screen := NewScreenBuffer()
+	screen.XorPixel(10, 5, 1)
+	screen.XorPixel(63, 31, 1)
+
+	palette, _ := ParsePalette("102030,FFCC00")
+	screenshotFilepath := filepath.Join(t.TempDir(), "golden.png")
+	if err := screen.WritePNG(screenshotFilepath, 3, palette); err != nil {
+		t.Fatalf("could not write screenshot: %s", err)
+	}
+
+	if differingPixels, err := screen.DiffPNG(screenshotFilepath, palette); (err != nil) || (differingPixels != 0) {
+		t.Errorf("expected identical screen, got %d differing pixels (%v)", differingPixels, err)
+	}
+
+	screen.XorPixel(10, 5, 1)
+	screen.XorPixel(0, 0, 1)
+	if differingPixels, _ := screen.DiffPNG(screenshotFilepath, palette); differingPixels != 2 {
+		t.Errorf("expected 2 differing pixels, got %d", differingPixels)
+	}
+
+	if _, err := screen.DiffPNG(screenshotFilepath, DefaultPalette); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,100 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SelfModificationKind is the kind of self-modifying code event.
+type SelfModificationKind int
+
+const (
+	SelfModificationWrite     SelfModificationKind = iota // SelfModificationWrite is a write to memory executed as instructions before
+	SelfModificationExecution                             // SelfModificationExecution is the execution of an instruction written at runtime
+)
+
+// SelfModification is a self-modifying code event, the program writing memory executed as instructions,
+// before or after the write.
+type SelfModification struct {
+	Kind    SelfModificationKind
+	Cycle   uint64 // Cycle is the number of instructions executed before the event
+	Address uint16 // Address is the modified memory address
+	Value   uint8  // Value is the value written
+	PC      uint16 // PC is the address of the instruction writing the memory
+}
+
+func (m SelfModification) String() string {
+	if m.Kind == SelfModificationExecution {
+		return fmt.Sprintf("Executing code at 0x%03X modified at runtime (0x%02X written by instruction at 0x%03X)", m.Address, m.Value, m.PC)
+	}
+	return fmt.Sprintf("Self-modifying code: instruction at 0x%03X writes 0x%02X to code at 0x%03X", m.PC, m.Value, m.Address)
+}
+
+// Memory marks of the bytes of memory
+const (
+	memoryExecuted = 1 << iota // memoryExecuted marks bytes executed as instructions
+	memoryWritten              // memoryWritten marks bytes written at runtime and not executed since
+	memoryModified             // memoryModified marks bytes written at runtime and executed, before or after the write
+)
+
+// AddSelfModificationListener adds a listener that is called for every self-modifying code event.
+func (chip8 *Chip8) AddSelfModificationListener(selfModificationListener func(selfModification SelfModification)) {
+	chip8.selfModificationListeners = append(chip8.selfModificationListeners, selfModificationListener)
+}
+
+// ModifiedAddresses are the memory addresses executed as instructions and written at runtime, in address order.
+func (chip8 *Chip8) ModifiedAddresses() []uint16 {
+	var addresses []uint16
+	for address, marks := range chip8.memoryMarks {
+		if marks&memoryModified != 0 {
+			addresses = append(addresses, uint16(address))
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	return addresses
+}
+
+// markExecuted marks the instruction bytes at the address executed
+func (chip8 *Chip8) markExecuted(address uint16) {
+	chip8.markExecutedByte(address)
+	chip8.markExecutedByte(chip8.memoryAddress(address + 1))
+}
+
+// markExecutedByte marks the byte at the address executed, queueing an event if written at runtime since executed
+func (chip8 *Chip8) markExecutedByte(address uint16) {
+	marks := chip8.memoryMarks[address]
+	if marks&memoryWritten != 0 {
+		chip8.selfModifications = append(chip8.selfModifications, SelfModification{
+			Kind: SelfModificationExecution, Cycle: chip8.Cycles, Address: address, Value: chip8.Memory[address], PC: chip8.memoryWriters[address],
+		})
+		marks = (marks &^ memoryWritten) | memoryModified
+	}
+	chip8.memoryMarks[address] = marks | memoryExecuted
+}
+
+// markWritten marks the byte at the address written by the executing instruction (already counted in the cycles),
+// queueing an event if executed before
+func (chip8 *Chip8) markWritten(address uint16, value byte) {
+	marks := chip8.memoryMarks[address]
+	if marks&memoryExecuted != 0 {
+		chip8.selfModifications = append(chip8.selfModifications, SelfModification{
+			Kind: SelfModificationWrite, Cycle: chip8.Cycles - 1, Address: address, Value: value, PC: chip8.executingAddress,
+		})
+		marks |= memoryModified
+	}
+	chip8.memoryMarks[address] = marks | memoryWritten
+	chip8.memoryWriters[address] = chip8.executingAddress
+}
+
+// reportSelfModifications prints (in debug mode) and notifies the listeners of the self-modifying code events of the last instruction
+func (chip8 *Chip8) reportSelfModifications(configuration Configuration) {
+	for _, selfModification := range chip8.selfModifications {
+		if configuration.Debug {
+			fmt.Printf("0x%03X: ****   # %s\n", chip8.executingAddress, selfModification)
+		}
+		for _, selfModificationListener := range chip8.selfModificationListeners {
+			selfModificationListener(selfModification)
+		}
+	}
+	chip8.selfModifications = chip8.selfModifications[:0]
+}
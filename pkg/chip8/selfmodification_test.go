@@ -0,0 +1,68 @@
+package chip8
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelfModification(t *testing.T) {
+	program := []byte{
+		0x60, 0x12, // 0x200: V0 = 0x12
+		0x61, 0x06, // 0x202: V1 = 0x06
+		0xA2, 0x06, // 0x204: I = 0x206
+		0xF1, 0x55, // 0x206: store V0..V1 at 0x206, over itself with "1206"
+		0x12, 0x06, // 0x208: jump 0x206, to the written infinite loop
+	}
+
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes(program, romAddressDefault)
+
+	var selfModifications []SelfModification
+	machine.AddSelfModificationListener(func(selfModification SelfModification) {
+		selfModifications = append(selfModifications, selfModification)
+	})
+
+	if err := machine.Run(Configuration{ModeRomCompatibility: true, EndOnInfiniteLoop: true, Headless: true}); err != ErrInfiniteLoop {
+		t.Fatalf("expected infinite loop, got %v", err)
+	}
+
+	expectedSelfModifications := []SelfModification{
+		{Kind: SelfModificationWrite, Cycle: 3, Address: 0x206, Value: 0x12, PC: 0x206},
+		{Kind: SelfModificationWrite, Cycle: 3, Address: 0x207, Value: 0x06, PC: 0x206},
+		{Kind: SelfModificationExecution, Cycle: 5, Address: 0x206, Value: 0x12, PC: 0x206},
+		{Kind: SelfModificationExecution, Cycle: 5, Address: 0x207, Value: 0x06, PC: 0x206},
+	}
+	if !reflect.DeepEqual(selfModifications, expectedSelfModifications) {
+		t.Errorf("expected self-modifications %v, got %v", expectedSelfModifications, selfModifications)
+	}
+
+	if modifiedAddresses := machine.ModifiedAddresses(); !reflect.DeepEqual(modifiedAddresses, []uint16{0x206, 0x207}) {
+		t.Errorf("expected modified addresses [0x206 0x207], got %v", modifiedAddresses)
+	}
+}
+
+func TestSelfModificationDataWrite(t *testing.T) {
+	program := []byte{
+		0xA3, 0x00, // 0x200: I = 0x300
+		0xF0, 0x55, // 0x202: store V0 at 0x300, never executed
+		0x12, 0x04, // 0x204: infinite loop
+	}
+
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes(program, romAddressDefault)
+
+	var selfModifications []SelfModification
+	machine.AddSelfModificationListener(func(selfModification SelfModification) {
+		selfModifications = append(selfModifications, selfModification)
+	})
+
+	if err := machine.Run(Configuration{EndOnInfiniteLoop: true, Headless: true}); err != ErrInfiniteLoop {
+		t.Fatalf("expected infinite loop, got %v", err)
+	}
+
+	if (len(selfModifications) != 0) || (len(machine.ModifiedAddresses()) != 0) {
+		t.Errorf("expected no self-modifications of data writes, got %v", selfModifications)
+	}
+}
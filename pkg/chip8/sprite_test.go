@@ -0,0 +1,90 @@
+package chip8
+
+import "testing"
+
+// drawSprite draws the sprite with DXYN (X register V0, Y register V1) at the position, returning the machine.
+func drawSprite(t *testing.T, configuration Configuration, x, y uint8, sprite []byte, times int) *Chip8 {
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+
+	const spriteAddress = 0x300
+	copy(machine.Memory[spriteAddress:], sprite)
+	machine.I = spriteAddress
+	machine.V[0] = x
+	machine.V[1] = y
+
+	for i := 0; i < times; i++ {
+		machine.PC = romAddressDefault
+		machine.Memory[romAddressDefault+0] = 0xD0
+		machine.Memory[romAddressDefault+1] = 0x10 | uint8(len(sprite))
+		if err := machine.Step(configuration); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	return machine
+}
+
+func TestSpriteClippedOrWrappedAtRightEdge(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		machine := drawSprite(t, Configuration{QuirkWrapSprites: wrap}, 60, 0, []byte{0xFF}, 1)
+		screen := &machine.peripherals.state.screen
+
+		for x := uint8(60); x < 64; x++ {
+			if screen.Value(x, 0) != 1 {
+				t.Errorf("expected pixel (%d, 0) on (wrap %v)", x, wrap)
+			}
+		}
+		for x := uint8(0); x < 4; x++ {
+			if (screen.Value(x, 0) == 1) != wrap {
+				t.Errorf("expected pixel (%d, 0) on only when wrapping (wrap %v)", x, wrap)
+			}
+		}
+	}
+}
+
+func TestSpriteClippedOrWrappedAtBottomEdge(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		machine := drawSprite(t, Configuration{QuirkWrapSprites: wrap}, 0, 30, []byte{0x80, 0x80, 0x80, 0x80}, 1)
+		screen := &machine.peripherals.state.screen
+
+		for _, y := range []uint8{30, 31} {
+			if screen.Value(0, y) != 1 {
+				t.Errorf("expected pixel (0, %d) on (wrap %v)", y, wrap)
+			}
+		}
+		for _, y := range []uint8{0, 1} {
+			if (screen.Value(0, y) == 1) != wrap {
+				t.Errorf("expected pixel (0, %d) on only when wrapping (wrap %v)", y, wrap)
+			}
+		}
+	}
+}
+
+func TestSpriteStartPositionWrapsAround(t *testing.T) {
+	machine := drawSprite(t, Configuration{}, 64+2, 32+3, []byte{0x80}, 1)
+	if machine.peripherals.state.screen.Value(2, 3) != 1 {
+		t.Errorf("expected sprite start position (66, 35) to wrap around to (2, 3)")
+	}
+}
+
+func TestSpriteCollisionFlag(t *testing.T) {
+	sprite := []byte{0xC0, 0x00, 0xC0, 0x80}
+
+	if machine := drawSprite(t, Configuration{}, 0, 0, sprite, 1); machine.V[flagRegisterIndex] != 0 {
+		t.Errorf("expected no collision drawing on empty screen, VF is %d", machine.V[flagRegisterIndex])
+	}
+	if machine := drawSprite(t, Configuration{}, 0, 0, sprite, 2); machine.V[flagRegisterIndex] != 1 {
+		t.Errorf("expected collision drawing sprite twice, VF is %d", machine.V[flagRegisterIndex])
+	}
+
+	// Rows counted: 3 rows colliding (the empty row does not collide)
+	if machine := drawSprite(t, Configuration{QuirkCollisionRowCount: true}, 0, 0, sprite, 2); machine.V[flagRegisterIndex] != 3 {
+		t.Errorf("expected 3 colliding rows, VF is %d", machine.V[flagRegisterIndex])
+	}
+
+	// Rows counted: 1 row colliding (the empty row does not collide) and 2 rows clipped at the bottom edge
+	if machine := drawSprite(t, Configuration{QuirkCollisionRowCount: true}, 0, 30, sprite, 2); machine.V[flagRegisterIndex] != 3 {
+		t.Errorf("expected 1 colliding and 2 clipped rows, VF is %d", machine.V[flagRegisterIndex])
+	}
+}
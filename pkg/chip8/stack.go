@@ -4,7 +4,7 @@ import "fmt"
 
 type stack struct {
 	Stack []uint16
-	Top   int
+	Top   int // Top is the number of values on the stack, i.e. the index of the next free stack slot
 }
 
 func newStack(size int) stack {
@@ -19,8 +19,8 @@ func (s *stack) Push(value uint16) error {
 		return fmt.Errorf("stack overflow: could not push value %d to stack as limit %d is already reached", value, len(s.Stack))
 	}
 
-	s.Top++
 	s.Stack[s.Top] = value
+	s.Top++
 
 	return nil
 }
@@ -30,8 +30,8 @@ func (s *stack) Pop() (uint16, error) {
 		return 0, fmt.Errorf("stack underflow: could not pop value from stack as bottom is already reached")
 	}
 
-	value := s.Stack[s.Top]
 	s.Top--
+	value := s.Stack[s.Top]
 
 	return value, nil
 }
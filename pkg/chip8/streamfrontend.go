@@ -0,0 +1,219 @@
+package chip8
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamMaxMessageSize is the largest accepted message on a stream link
+const streamMaxMessageSize = 64 * 1024
+
+// streamReconnectInterval is the time between attempts to connect to the screen application
+const streamReconnectInterval = time.Second
+
+// streamWriteTimeout is the longest time a message may take to be written before the connection is considered broken
+const streamWriteTimeout = 2 * time.Second
+
+// StreamFrontend is the link to an external screen application (viewer) over a reliable, ordered stream (TCP or Unix domain socket).
+// Messages of the wire protocol (documentation/protocol.adoc) are sent in both directions on the same connection,
+// every message prefixed by its length as a big endian uint32.
+// The interpreter connects to the screen application, and reconnects if the connection is lost.
+type StreamFrontend struct {
+	network     string // network is "tcp" or "unix"
+	address     string
+	lock        sync.Mutex
+	connection  net.Conn       // connection is nil while not connected
+	session     *viewerSession // session is the protocol state of the connection
+	compression bool
+	closed      bool
+}
+
+func NewStreamFrontend(network string, address string, compression bool) *StreamFrontend {
+	return &StreamFrontend{
+		network:     network,
+		address:     address,
+		compression: compression,
+	}
+}
+
+// NewFrontendForAddress creates the screen application link for a URL style address:
+// "tcp://host:port", "unix:///path/to/socket", or "udp://host:port" (also the default for addresses without scheme, "host:port").
+func NewFrontendForAddress(screenAddress string, keyStateListenerPort int, compression bool) (Frontend, error) {
+	if !strings.Contains(screenAddress, "://") {
+		return NewUDPFrontend(screenAddress, keyStateListenerPort, compression)
+	}
+
+	screenURL, err := url.Parse(screenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("illegal screen address \"%s\": %w", screenAddress, err)
+	}
+
+	switch screenURL.Scheme {
+	case "udp":
+		return NewUDPFrontend(screenURL.Host, keyStateListenerPort, compression)
+	case "tcp":
+		return NewStreamFrontend("tcp", screenURL.Host, compression), nil
+	case "unix":
+		return NewStreamFrontend("unix", screenURL.Path, compression), nil
+	default:
+		return nil, fmt.Errorf("illegal screen address \"%s\": unknown transport \"%s\" (expected \"udp\", \"tcp\" or \"unix\")", screenAddress, screenURL.Scheme)
+	}
+}
+
+func (f *StreamFrontend) Start(p *Peripherals) error {
+	go f.connect(p)
+	return nil
+}
+
+// connect connects (and reconnects) to the screen application and reads its messages until the frontend is closed.
+func (f *StreamFrontend) connect(p *Peripherals) {
+	reportedFailure := false
+
+	for !f.isClosed() {
+		connection, err := net.Dial(f.network, f.address)
+		if err != nil {
+			if !reportedFailure {
+				fmt.Printf("could not connect to screen application at %s \"%s\", retrying: %s\n", f.network, f.address, err.Error())
+				reportedFailure = true
+			}
+			time.Sleep(streamReconnectInterval)
+			continue
+		}
+		reportedFailure = false
+
+		session := newStreamViewerSession(f.network+":"+f.address, f.compression, connection)
+		f.lock.Lock()
+		f.connection = connection
+		f.session = session
+		f.lock.Unlock()
+
+		session.sendWelcome(p)
+		readStreamViewerMessages(p, session, connection, f.isClosed)
+
+		f.lock.Lock()
+		if f.connection == connection {
+			f.connection = nil
+			f.session = nil
+		}
+		f.lock.Unlock()
+		connection.Close()
+
+		p.leaveViewer(session.id) // Release all keys of the disconnected screen application
+	}
+}
+
+// newStreamViewerSession creates the session of a viewer connected over a stream.
+// A connection that fails to be written is closed, the reading side notices and ends the session.
+func newStreamViewerSession(id string, compression bool, connection net.Conn) *viewerSession {
+	write := func(data []byte) error {
+		connection.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		err := writeStreamMessage(connection, data)
+		if err != nil {
+			connection.Close()
+		}
+		return err
+	}
+
+	return newViewerSession(id, compression, marshalMessage, write)
+}
+
+// readStreamViewerMessages reads and acts on the messages of a viewer until the connection is lost.
+func readStreamViewerMessages(p *Peripherals, session *viewerSession, connection net.Conn, isClosed func() bool) {
+	reader := bufio.NewReader(connection)
+
+	for {
+		data, err := readStreamMessage(reader)
+		if err != nil {
+			if !isClosed() && (err != io.EOF) {
+				fmt.Printf("lost connection to viewer %s: %s\n", session.id, err.Error())
+			}
+			return
+		}
+
+		message, err := decodeViewerMessage(data, msgpack.Unmarshal)
+		if err != nil {
+			fmt.Printf("rejected message from viewer %s: %s\n", session.id, err.Error())
+			continue
+		}
+
+		session.receive(p, message)
+	}
+}
+
+// readStreamMessage reads one length prefixed message.
+func readStreamMessage(reader io.Reader) ([]byte, error) {
+	lengthPrefix := make([]byte, 4)
+	if _, err := io.ReadFull(reader, lengthPrefix); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(lengthPrefix)
+	if length > streamMaxMessageSize {
+		return nil, fmt.Errorf("%w: message of %d bytes exceeds limit of %d bytes", errMalformedMessage, length, streamMaxMessageSize)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// writeStreamMessage writes one message prefixed by its length.
+func writeStreamMessage(writer io.Writer, data []byte) error {
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	frame = append(frame, data...)
+
+	_, err := writer.Write(frame)
+	return err
+}
+
+// send sends the message to the screen application, if connected.
+func (f *StreamFrontend) send(message interface{ setSeq(seq uint32) }) {
+	f.lock.Lock()
+	session := f.session
+	f.lock.Unlock()
+
+	if session == nil {
+		return
+	}
+
+	if err := session.send(message); err != nil {
+		fmt.Printf("could not update screen application: %s\n", err.Error())
+	}
+}
+
+func (f *StreamFrontend) isClosed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.closed
+}
+
+func (f *StreamFrontend) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closed = true
+	if f.connection != nil {
+		return f.connection.Close()
+	}
+
+	return nil
+}
+
+func (f *StreamFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.send(getSoundAndKeysMessage(state))
+}
+
+func (f *StreamFrontend) UpdateScreen(state *PeripheralsState) {
+	f.send(getScreenMessage(state))
+}
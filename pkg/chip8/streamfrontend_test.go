@@ -0,0 +1,75 @@
+package chip8
+
+import (
+	"bufio"
+	"github.com/vmihailenco/msgpack/v5"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStreamFrontendOverUnixDomainSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "chip8.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix domain sockets not available: %s", err)
+	}
+	defer listener.Close()
+
+	frontend, err := NewFrontendForAddress("unix://"+socketPath, 0, false)
+	if err != nil {
+		t.Fatalf("could not create frontend: %s", err)
+	}
+	peripherals := NewPeripheralsWithFrontend(frontend)
+	peripherals.StartKeyPadListener()
+	defer peripherals.Close()
+
+	viewer, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("could not accept interpreter connection: %s", err)
+	}
+	defer viewer.Close()
+	viewer.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(viewer)
+
+	for _, expectedType := range []string{messageTypeHello, messageTypeState} {
+		data, err := readStreamMessage(reader)
+		if err != nil {
+			t.Fatalf("could not read %s message: %s", expectedType, err)
+		}
+
+		header := messageHeader{}
+		if err := msgpack.Unmarshal(data, &header); (err != nil) || (header.Type != expectedType) {
+			t.Fatalf("expected %s message, got %+v (%v)", expectedType, header, err)
+		}
+	}
+
+	keys, _ := msgpack.Marshal(&keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 1), Keys: 0x0030})
+	if err := writeStreamMessage(viewer, keys); err != nil {
+		t.Fatalf("could not write keys message: %s", err)
+	}
+
+	// The key state change is echoed back in a state message
+	data, err := readStreamMessage(reader)
+	if err != nil {
+		t.Fatalf("could not read state message: %s", err)
+	}
+	state := peripheralStateMessage{}
+	if err := msgpack.Unmarshal(data, &state); (err != nil) || (state.Keys != 0x0030) {
+		t.Fatalf("expected key state 0x0030, got %+v (%v)", state, err)
+	}
+}
+
+func TestNewFrontendForAddress(t *testing.T) {
+	for _, illegalAddress := range []string{"sctp://localhost:9999", "tcp://%zz"} {
+		if _, err := NewFrontendForAddress(illegalAddress, 0, false); err == nil {
+			t.Errorf("expected error for screen address \"%s\"", illegalAddress)
+		}
+	}
+
+	frontend, err := NewFrontendForAddress("tcp://localhost:9999", 0, false)
+	if streamFrontend, ok := frontend.(*StreamFrontend); (err != nil) || !ok || (streamFrontend.network != "tcp") || (streamFrontend.address != "localhost:9999") {
+		t.Fatalf("expected tcp stream frontend, got %+v (%v)", frontend, err)
+	}
+}
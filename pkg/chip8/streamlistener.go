@@ -0,0 +1,154 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// StreamListenerFrontend broadcasts the peripherals to any number of viewers connecting over a stream (TCP or Unix domain socket),
+// using the same length prefixed messages as StreamFrontend. Viewers may connect and disconnect at any time,
+// and get the full screen when they connect. Key input is accepted from one viewer only, the controller.
+type StreamListenerFrontend struct {
+	network     string // network is "tcp" or "unix"
+	address     string
+	compression bool
+	listener    net.Listener
+	lock        sync.Mutex
+	viewers     map[*viewerSession]net.Conn // viewers are the connected viewers
+	viewerCount int                         // viewerCount is the number of viewers connected so far, numbering viewer ids
+	closed      bool
+}
+
+func NewStreamListenerFrontend(network string, address string, compression bool) *StreamListenerFrontend {
+	return &StreamListenerFrontend{
+		network:     network,
+		address:     address,
+		compression: compression,
+		viewers:     map[*viewerSession]net.Conn{},
+	}
+}
+
+// NewStreamListenerForAddress creates a frontend listening for viewers on a URL style address:
+// "tcp://host:port" (or "tcp://:port" for all interfaces) or "unix:///path/to/socket".
+func NewStreamListenerForAddress(listenAddress string, compression bool) (*StreamListenerFrontend, error) {
+	listenURL, err := url.Parse(listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("illegal listen address \"%s\": %w", listenAddress, err)
+	}
+
+	switch listenURL.Scheme {
+	case "tcp":
+		return NewStreamListenerFrontend("tcp", listenURL.Host, compression), nil
+	case "unix":
+		return NewStreamListenerFrontend("unix", listenURL.Path, compression), nil
+	default:
+		return nil, fmt.Errorf("illegal listen address \"%s\": unknown transport \"%s\" (expected \"tcp\" or \"unix\")", listenAddress, listenURL.Scheme)
+	}
+}
+
+func (f *StreamListenerFrontend) Start(p *Peripherals) error {
+	listener, err := net.Listen(f.network, f.address)
+	if err != nil {
+		return fmt.Errorf("could not listen for viewers on %s \"%s\": %w", f.network, f.address, err)
+	}
+	f.listener = listener
+
+	go f.acceptViewers(p)
+
+	return nil
+}
+
+// Address is the address the frontend listens to for viewers.
+func (f *StreamListenerFrontend) Address() string {
+	if f.listener == nil {
+		return f.address
+	}
+	return f.listener.Addr().String()
+}
+
+func (f *StreamListenerFrontend) acceptViewers(p *Peripherals) {
+	for {
+		connection, err := f.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			fmt.Printf("could not accept viewer: %s\n", err.Error())
+			continue
+		}
+
+		go f.serveViewer(p, connection)
+	}
+}
+
+func (f *StreamListenerFrontend) serveViewer(p *Peripherals, connection net.Conn) {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		connection.Close()
+		return
+	}
+	f.viewerCount++
+	session := newStreamViewerSession(fmt.Sprintf("%s:%d", f.network, f.viewerCount), f.compression, connection)
+	f.viewers[session] = connection
+	f.lock.Unlock()
+
+	session.sendWelcome(p)
+	readStreamViewerMessages(p, session, connection, f.isClosed)
+
+	f.lock.Lock()
+	delete(f.viewers, session)
+	f.lock.Unlock()
+	connection.Close()
+
+	p.leaveViewer(session.id)
+}
+
+// broadcast sends the message to all connected viewers. Every viewer gets its own copy,
+// as the sequence number and screen encoding differ between viewers.
+func (f *StreamListenerFrontend) broadcast(newMessage func() interface{ setSeq(seq uint32) }) {
+	f.lock.Lock()
+	sessions := make([]*viewerSession, 0, len(f.viewers))
+	for session := range f.viewers {
+		sessions = append(sessions, session)
+	}
+	f.lock.Unlock()
+
+	for _, session := range sessions {
+		if err := session.send(newMessage()); err != nil {
+			fmt.Printf("could not update viewer %s: %s\n", session.id, err.Error())
+		}
+	}
+}
+
+func (f *StreamListenerFrontend) isClosed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.closed
+}
+
+func (f *StreamListenerFrontend) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closed = true
+	for _, connection := range f.viewers {
+		connection.Close()
+	}
+
+	if f.listener != nil {
+		return f.listener.Close()
+	}
+
+	return nil
+}
+
+func (f *StreamListenerFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.broadcast(func() interface{ setSeq(seq uint32) } { return getSoundAndKeysMessage(state) })
+}
+
+func (f *StreamListenerFrontend) UpdateScreen(state *PeripheralsState) {
+	f.broadcast(func() interface{ setSeq(seq uint32) } { return getScreenMessage(state) })
+}
@@ -0,0 +1,287 @@
+package chip8
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TraceRecord is the trace of one executed instruction.
+type TraceRecord struct {
+	Cycle    uint64             `json:"cycle"`    // Cycle is the number of instructions executed before the instruction
+	Frame    uint64             `json:"frame"`    // Frame is the 60 Hz frame the instruction is executed in
+	PC       uint16             `json:"pc"`       // PC is the address of the instruction
+	Opcode   uint16             `json:"opcode"`   // Opcode is the instruction code
+	Mnemonic string             `json:"mnemonic"` // Mnemonic is the instruction in Cowgod's mnemonics, like "LD V0, 0x0C"
+	V        [16]uint8          `json:"v"`        // V is the registers before the instruction
+	VAfter   [16]uint8          `json:"vAfter"`   // VAfter is the registers after the instruction
+	I        uint16             `json:"i"`        // I is the index register before the instruction
+	IAfter   uint16             `json:"iAfter"`   // IAfter is the index register after the instruction
+	Writes   []TraceMemoryWrite `json:"writes,omitempty"`
+	Keys     uint16             `json:"keys"` // Keys is the key state bitmask, bit 0 is key "0" through bit 15 being key "F"
+}
+
+// TraceMemoryWrite is a memory write of an instruction.
+type TraceMemoryWrite struct {
+	Address uint16 `json:"address"`
+	Value   uint8  `json:"value"`
+}
+
+// TraceFormat is the file format of traces.
+type TraceFormat int
+
+const (
+	TraceFormatJSONL  TraceFormat = iota // TraceFormatJSONL is one JSON object per line and instruction
+	TraceFormatBinary                    // TraceFormatBinary is fixed size big endian records after the header "CH8T" and a version byte
+)
+
+// traceBinaryHeader starts binary traces, followed by the format version
+const traceBinaryHeader = "CH8T"
+
+const traceBinaryVersion = 1
+
+// ParseTraceFormat parses the trace format name, "jsonl" or "binary".
+func ParseTraceFormat(name string) (TraceFormat, error) {
+	switch name {
+	case "jsonl":
+		return TraceFormatJSONL, nil
+	case "binary":
+		return TraceFormatBinary, nil
+	}
+	return TraceFormatJSONL, fmt.Errorf("unknown trace format \"%s\" (expected \"jsonl\" or \"binary\")", name)
+}
+
+// TraceFormatForFilepath is the binary format for ".bin" files and otherwise JSONL.
+func TraceFormatForFilepath(traceFilepath string) TraceFormat {
+	if strings.EqualFold(filepath.Ext(traceFilepath), ".bin") {
+		return TraceFormatBinary
+	}
+	return TraceFormatJSONL
+}
+
+// TraceFilter selects the traced instructions by address range and instruction type.
+type TraceFilter struct {
+	FromAddress uint16          // FromAddress is the first traced address
+	ToAddress   uint16          // ToAddress is the last traced address (inclusive)
+	Opcodes     map[string]bool // Opcodes are the traced instruction patterns, like "DXYN" (nil for all instructions)
+}
+
+// AllInstructions is the filter tracing every instruction
+var AllInstructions = TraceFilter{FromAddress: 0x000, ToAddress: 0xFFFF}
+
+// ParseTraceFilter parses an address range, like "0x200-0x2FF" ("" for all addresses),
+// and a comma separated list of instruction patterns, like "DXYN,FX33" ("" for all instructions).
+func ParseTraceFilter(addressRangeText string, opcodesText string) (TraceFilter, error) {
+	filter := AllInstructions
+
+	if addressRangeText != "" {
+		fromText, toText, found := strings.Cut(addressRangeText, "-")
+		if !found {
+			toText = fromText
+		}
+
+		from, fromErr := strconv.ParseUint(strings.TrimSpace(fromText), 0, 16)
+		to, toErr := strconv.ParseUint(strings.TrimSpace(toText), 0, 16)
+		if (fromErr != nil) || (toErr != nil) || (from > to) {
+			return TraceFilter{}, fmt.Errorf("illegal address range \"%s\" (expected format \"0x200-0x2FF\")", addressRangeText)
+		}
+		filter.FromAddress, filter.ToAddress = uint16(from), uint16(to)
+	}
+
+	if opcodesText != "" {
+		filter.Opcodes = map[string]bool{}
+		for _, opcode := range strings.Split(opcodesText, ",") {
+			opcode = strings.ToUpper(strings.TrimSpace(opcode))
+			if !isInstructionPattern(opcode) {
+				return TraceFilter{}, fmt.Errorf("unknown instruction type \"%s\" (expected an instruction pattern, like \"DXYN\")", opcode)
+			}
+			filter.Opcodes[opcode] = true
+		}
+	}
+
+	return filter, nil
+}
+
+// includes is true if the instruction at the address is traced
+func (f TraceFilter) includes(address uint16, instructionCode uint16) bool {
+	if (address < f.FromAddress) || (address > f.ToAddress) {
+		return false
+	}
+	if f.Opcodes == nil {
+		return true
+	}
+	instruction, _ := DecodeInstruction(instructionCode)
+	return f.Opcodes[instruction.Opcode]
+}
+
+func isInstructionPattern(opcode string) bool {
+	for _, pattern := range instructionPatterns {
+		if pattern.opcode == opcode {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracer writes a trace record of every executed instruction passing the filter, see Chip8.SetTracer.
+type Tracer struct {
+	writer *bufio.Writer
+	closer io.Closer
+	format TraceFormat
+	filter TraceFilter
+	err    error // err is the first write error, returned by Close
+}
+
+// NewTracer creates a tracer writing to the writer.
+func NewTracer(writer io.Writer, format TraceFormat, filter TraceFilter) *Tracer {
+	tracer := &Tracer{writer: bufio.NewWriter(writer), format: format, filter: filter}
+	if format == TraceFormatBinary {
+		_, tracer.err = tracer.writer.WriteString(traceBinaryHeader + string(rune(traceBinaryVersion)))
+	}
+	return tracer
+}
+
+// CreateTracer creates a tracer writing to the trace file.
+func CreateTracer(traceFilepath string, format TraceFormat, filter TraceFilter) (*Tracer, error) {
+	file, err := os.Create(traceFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create trace file \"%s\": %w", traceFilepath, err)
+	}
+
+	tracer := NewTracer(file, format, filter)
+	tracer.closer = file
+	return tracer, nil
+}
+
+// Close flushes the trace and closes the trace file. The error is the first error writing the trace.
+func (t *Tracer) Close() error {
+	if err := t.writer.Flush(); (err != nil) && (t.err == nil) {
+		t.err = err
+	}
+	if t.closer != nil {
+		if err := t.closer.Close(); (err != nil) && (t.err == nil) {
+			t.err = err
+		}
+	}
+	return t.err
+}
+
+// trace writes the record, if passing the filter
+func (t *Tracer) trace(record *TraceRecord) {
+	if (t.err != nil) || !t.filter.includes(record.PC, record.Opcode) {
+		return
+	}
+
+	switch t.format {
+	case TraceFormatBinary:
+		t.err = writeBinaryTraceRecord(t.writer, record)
+	default:
+		var recordJSON []byte
+		if recordJSON, t.err = json.Marshal(record); t.err == nil {
+			recordJSON = append(recordJSON, '\n')
+			_, t.err = t.writer.Write(recordJSON)
+		}
+	}
+}
+
+// binaryTraceRecord is the fixed size part of a binary trace record, followed by the memory writes
+type binaryTraceRecord struct {
+	Cycle      uint64
+	Frame      uint64
+	PC         uint16
+	Opcode     uint16
+	V          [16]uint8
+	VAfter     [16]uint8
+	I          uint16
+	IAfter     uint16
+	Keys       uint16
+	WriteCount uint8
+}
+
+func writeBinaryTraceRecord(writer io.Writer, record *TraceRecord) error {
+	if len(record.Writes) > 0xFF {
+		return fmt.Errorf("too many memory writes (%d) in instruction at address 0x%03X", len(record.Writes), record.PC)
+	}
+
+	binaryRecord := binaryTraceRecord{
+		Cycle: record.Cycle, Frame: record.Frame, PC: record.PC, Opcode: record.Opcode, V: record.V, VAfter: record.VAfter,
+		I: record.I, IAfter: record.IAfter, Keys: record.Keys, WriteCount: uint8(len(record.Writes)),
+	}
+	if err := binary.Write(writer, binary.BigEndian, &binaryRecord); err != nil {
+		return err
+	}
+	return binary.Write(writer, binary.BigEndian, record.Writes)
+}
+
+// ReadTrace reads a trace in JSONL or binary format.
+func ReadTrace(reader io.Reader) ([]TraceRecord, error) {
+	bufferedReader := bufio.NewReader(reader)
+	var records []TraceRecord
+
+	header, _ := bufferedReader.Peek(len(traceBinaryHeader) + 1)
+	if bytes.HasPrefix(header, []byte(traceBinaryHeader)) {
+		bufferedReader.Discard(len(header))
+		if header[len(traceBinaryHeader)] != traceBinaryVersion {
+			return nil, fmt.Errorf("unsupported binary trace version %d", header[len(traceBinaryHeader)])
+		}
+
+		for {
+			var binaryRecord binaryTraceRecord
+			if err := binary.Read(bufferedReader, binary.BigEndian, &binaryRecord); errors.Is(err, io.EOF) {
+				return records, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("could not read trace record %d: %w", len(records)+1, err)
+			}
+
+			instruction, _ := DecodeInstruction(binaryRecord.Opcode)
+			record := TraceRecord{
+				Cycle: binaryRecord.Cycle, Frame: binaryRecord.Frame, PC: binaryRecord.PC, Opcode: binaryRecord.Opcode, Mnemonic: instruction.Mnemonic(),
+				V: binaryRecord.V, VAfter: binaryRecord.VAfter, I: binaryRecord.I, IAfter: binaryRecord.IAfter, Keys: binaryRecord.Keys,
+			}
+			if binaryRecord.WriteCount > 0 {
+				record.Writes = make([]TraceMemoryWrite, binaryRecord.WriteCount)
+				if err := binary.Read(bufferedReader, binary.BigEndian, record.Writes); err != nil {
+					return nil, fmt.Errorf("could not read trace record %d: %w", len(records)+1, err)
+				}
+			}
+			records = append(records, record)
+		}
+	}
+
+	scanner := bufio.NewScanner(bufferedReader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// ReadTraceFile reads a trace file in JSONL or binary format.
+func ReadTraceFile(traceFilepath string) ([]TraceRecord, error) {
+	file, err := os.Open(traceFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open trace file \"%s\": %w", traceFilepath, err)
+	}
+	defer file.Close()
+
+	records, err := ReadTrace(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read trace file \"%s\": %w", traceFilepath, err)
+	}
+	return records, nil
+}
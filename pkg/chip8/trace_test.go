@@ -0,0 +1,74 @@
+package chip8
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTraceRoundTrip(t *testing.T) {
+	rom := []byte{0x60, 0x2A, 0xA3, 0x00, 0xF0, 0x33, 0xD0, 0x01, 0x12, 0x08} // V0 = 42, I = 0x300, BCD of V0, draw, loop
+
+	for _, format := range []TraceFormat{TraceFormatJSONL, TraceFormatBinary} {
+		peripherals := NewHeadlessPeripherals()
+		machine := NewChip8(&peripherals)
+		machine.loadROMBytes(rom, romAddressDefault)
+
+		var trace bytes.Buffer
+		tracer := NewTracer(&trace, format, AllInstructions)
+		machine.SetTracer(tracer)
+		for i := 0; i < 5; i++ {
+			machine.Step(Configuration{})
+		}
+		if err := tracer.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		records, err := ReadTrace(&trace)
+		if err != nil {
+			t.Fatalf("unexpected error reading trace (format %d): %s", format, err)
+		}
+		if len(records) != 5 {
+			t.Fatalf("expected 5 trace records (format %d), got %d", format, len(records))
+		}
+
+		bcd := records[2]
+		expectedWrites := []TraceMemoryWrite{{0x300, 0}, {0x301, 4}, {0x302, 2}}
+		if (bcd.Cycle != 2) || (bcd.PC != 0x204) || (bcd.Opcode != 0xF033) || (bcd.Mnemonic != "LD B, V0") || (bcd.I != 0x300) || !reflect.DeepEqual(bcd.Writes, expectedWrites) {
+			t.Errorf("unexpected trace record (format %d) %+v", format, bcd)
+		}
+		if (records[0].V[0] != 0) || (records[0].VAfter[0] != 0x2A) || (records[1].IAfter != 0x300) {
+			t.Errorf("expected register changes (format %d), got %+v and %+v", format, records[0], records[1])
+		}
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	filter, err := ParseTraceFilter("0x202-0x206", "fx33,DXYN")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	peripherals := NewHeadlessPeripherals()
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes([]byte{0x60, 0x2A, 0xA3, 0x00, 0xF0, 0x33, 0xD0, 0x01, 0x12, 0x08}, romAddressDefault)
+
+	var trace bytes.Buffer
+	tracer := NewTracer(&trace, TraceFormatJSONL, filter)
+	machine.SetTracer(tracer)
+	for i := 0; i < 5; i++ {
+		machine.Step(Configuration{})
+	}
+	tracer.Close()
+
+	records, _ := ReadTrace(&trace)
+	if (len(records) != 2) || (records[0].PC != 0x204) || (records[1].PC != 0x206) {
+		t.Errorf("expected the traces of FX33 and DXYN, got %+v", records)
+	}
+
+	for _, illegalFilter := range [][2]string{{"0x300-0x200", ""}, {"start-end", ""}, {"", "FX99"}} {
+		if _, err := ParseTraceFilter(illegalFilter[0], illegalFilter[1]); err == nil {
+			t.Errorf("expected error for filter %q", illegalFilter)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package chip8
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TraceLog is a trace of our own, or the log of a reference emulator.
+type TraceLog struct {
+	Records    []TraceRecord
+	AfterState bool // AfterState is true if the records have the registers after, and the memory writes of, the instructions
+}
+
+// ReadTraceLog reads a trace file in JSONL or binary format, or a reference emulator log in CSV format (for "*.csv" files).
+//
+// The CSV log has a header row naming the columns, in any order: "pc", "opcode", "v0" through "vf" and "i", and optionally
+// "cycle" and "frame". Every row is an executed instruction and the registers before it, in hexadecimal with or without "0x".
+// Cycle and frame numbers are decimal.
+//
+//	cycle,pc,opcode,v0,v1,v2,v3,v4,v5,v6,v7,v8,v9,va,vb,vc,vd,ve,vf,i
+//	0,200,00E0,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,000
+//	1,202,A22A,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,000
+func ReadTraceLog(traceFilepath string) (*TraceLog, error) {
+	if !strings.EqualFold(filepath.Ext(traceFilepath), ".csv") {
+		records, err := ReadTraceFile(traceFilepath)
+		if err != nil {
+			return nil, err
+		}
+		return &TraceLog{Records: records, AfterState: true}, nil
+	}
+
+	file, err := os.Open(traceFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open trace file \"%s\": %w", traceFilepath, err)
+	}
+	defer file.Close()
+
+	records, err := readCSVTrace(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read trace file \"%s\": %w", traceFilepath, err)
+	}
+	return &TraceLog{Records: records}, nil
+}
+
+func readCSVTrace(reader io.Reader) ([]TraceRecord, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	requiredColumns := []string{"pc", "opcode", "i"}
+	for register := 0; register <= 0xF; register++ {
+		requiredColumns = append(requiredColumns, fmt.Sprintf("v%x", register))
+	}
+	for _, name := range requiredColumns {
+		if _, defined := columns[name]; !defined {
+			return nil, fmt.Errorf("missing column \"%s\"", name)
+		}
+	}
+
+	var records []TraceRecord
+	for rowNumber := 2; ; rowNumber++ {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var rowErr error
+		value := func(name string, base int, bitSize int) uint64 {
+			column, defined := columns[name]
+			if !defined || (rowErr != nil) {
+				return 0
+			}
+			text := strings.TrimSpace(row[column])
+			if base == 16 {
+				text = strings.TrimPrefix(strings.TrimPrefix(text, "0x"), "0X")
+			}
+			number, err := strconv.ParseUint(text, base, bitSize)
+			if err != nil {
+				rowErr = fmt.Errorf("row %d: illegal %s \"%s\"", rowNumber, name, row[column])
+			}
+			return number
+		}
+
+		record := TraceRecord{
+			Cycle:  value("cycle", 10, 64),
+			Frame:  value("frame", 10, 64),
+			PC:     uint16(value("pc", 16, 16)),
+			Opcode: uint16(value("opcode", 16, 16)),
+			I:      uint16(value("i", 16, 16)),
+		}
+		if _, defined := columns["cycle"]; !defined {
+			record.Cycle = uint64(len(records))
+		}
+		for register := range record.V {
+			record.V[register] = uint8(value(fmt.Sprintf("v%x", register), 16, 8))
+		}
+		if rowErr != nil {
+			return nil, rowErr
+		}
+
+		instruction, _ := DecodeInstruction(record.Opcode)
+		record.Mnemonic = instruction.Mnemonic()
+		records = append(records, record)
+	}
+}
+
+// TraceDivergence is the first difference between two traces.
+type TraceDivergence struct {
+	Index     int    // Index is the index of the diverging records in the traces
+	Field     string // Field is the differing part of the records, like "PC", "V3", "I after", "memory 0x300" or "length" (of the traces)
+	Ours      string // Ours is the value in our trace
+	Reference string // Reference is the value in the reference trace
+}
+
+// DiffTraces finds the first divergence of our trace from the reference trace, nil if the traces are equal.
+// The records are compared in order: the address, opcode and registers before every instruction,
+// and, if both traces have them, the registers after and the memory writes of every instruction.
+func DiffTraces(ours *TraceLog, reference *TraceLog) *TraceDivergence {
+	compareAfterState := ours.AfterState && reference.AfterState
+
+	for index := 0; (index < len(ours.Records)) || (index < len(reference.Records)); index++ {
+		if (index >= len(ours.Records)) || (index >= len(reference.Records)) {
+			return &TraceDivergence{Index: index, Field: "length", Ours: fmt.Sprintf("%d records", len(ours.Records)), Reference: fmt.Sprintf("%d records", len(reference.Records))}
+		}
+
+		if field, oursValue, referenceValue, differ := diffTraceRecords(&ours.Records[index], &reference.Records[index], compareAfterState); differ {
+			return &TraceDivergence{Index: index, Field: field, Ours: oursValue, Reference: referenceValue}
+		}
+	}
+
+	return nil
+}
+
+// diffTraceRecords finds the first differing field of the records
+func diffTraceRecords(ours *TraceRecord, reference *TraceRecord, compareAfterState bool) (field string, oursValue string, referenceValue string, differ bool) {
+	if ours.PC != reference.PC {
+		return "PC", fmt.Sprintf("0x%03X", ours.PC), fmt.Sprintf("0x%03X", reference.PC), true
+	}
+	if ours.Opcode != reference.Opcode {
+		return "opcode", fmt.Sprintf("%04X", ours.Opcode), fmt.Sprintf("%04X", reference.Opcode), true
+	}
+	if field, oursValue, referenceValue, differ := diffRegisters(ours.V, ours.I, reference.V, reference.I, ""); differ {
+		return field, oursValue, referenceValue, true
+	}
+
+	if !compareAfterState {
+		return "", "", "", false
+	}
+
+	if field, oursValue, referenceValue, differ := diffRegisters(ours.VAfter, ours.IAfter, reference.VAfter, reference.IAfter, " after"); differ {
+		return field, oursValue, referenceValue, true
+	}
+
+	for i := 0; (i < len(ours.Writes)) || (i < len(reference.Writes)); i++ {
+		switch {
+		case i >= len(reference.Writes):
+			return fmt.Sprintf("memory 0x%03X", ours.Writes[i].Address), fmt.Sprintf("0x%02X", ours.Writes[i].Value), "not written", true
+		case i >= len(ours.Writes):
+			return fmt.Sprintf("memory 0x%03X", reference.Writes[i].Address), "not written", fmt.Sprintf("0x%02X", reference.Writes[i].Value), true
+		case ours.Writes[i].Address != reference.Writes[i].Address:
+			return fmt.Sprintf("memory write %d", i+1), fmt.Sprintf("0x%03X", ours.Writes[i].Address), fmt.Sprintf("0x%03X", reference.Writes[i].Address), true
+		case ours.Writes[i].Value != reference.Writes[i].Value:
+			return fmt.Sprintf("memory 0x%03X", ours.Writes[i].Address), fmt.Sprintf("0x%02X", ours.Writes[i].Value), fmt.Sprintf("0x%02X", reference.Writes[i].Value), true
+		}
+	}
+
+	return "", "", "", false
+}
+
+func diffRegisters(oursV [16]uint8, oursI uint16, referenceV [16]uint8, referenceI uint16, suffix string) (field string, oursValue string, referenceValue string, differ bool) {
+	for register := range oursV {
+		if oursV[register] != referenceV[register] {
+			return fmt.Sprintf("V%X%s", register, suffix), fmt.Sprintf("0x%02X", oursV[register]), fmt.Sprintf("0x%02X", referenceV[register]), true
+		}
+	}
+	if oursI != referenceI {
+		return "I" + suffix, fmt.Sprintf("0x%03X", oursI), fmt.Sprintf("0x%03X", referenceI), true
+	}
+	return "", "", "", false
+}
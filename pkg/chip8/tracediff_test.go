@@ -0,0 +1,61 @@
+package chip8
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCSVTrace(t *testing.T) {
+	csvTrace := "pc,opcode,v0,v1,v2,v3,v4,v5,v6,v7,v8,v9,va,vb,vc,vd,ve,vf,i\n" +
+		"0x200,602A,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,00,000\n" +
+		"202,A300,2A,00,00,00,00,00,00,00,00,00,00,00,00,00,00,FF,000\n"
+
+	records, err := readCSVTrace(strings.NewReader(csvTrace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if (len(records) != 2) || (records[1].Cycle != 1) || (records[1].PC != 0x202) || (records[1].V[0] != 0x2A) || (records[1].V[0xF] != 0xFF) || (records[1].Mnemonic != "LD I, 0x300") {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	for _, illegalCSVTrace := range []string{"pc,opcode\n200,00E0\n", strings.Replace(csvTrace, "A300", "A3G0", 1)} {
+		if _, err := readCSVTrace(strings.NewReader(illegalCSVTrace)); err == nil {
+			t.Errorf("expected error for %q", illegalCSVTrace)
+		}
+	}
+}
+
+func TestDiffTraces(t *testing.T) {
+	ours := &TraceLog{AfterState: true, Records: []TraceRecord{
+		{PC: 0x200, Opcode: 0x602A, VAfter: [16]uint8{0x2A}},
+		{PC: 0x202, Opcode: 0xF033, V: [16]uint8{0x2A}, VAfter: [16]uint8{0x2A}, I: 0x300, IAfter: 0x300, Writes: []TraceMemoryWrite{{0x300, 0}, {0x301, 4}, {0x302, 2}}},
+	}}
+	reference := &TraceLog{AfterState: true, Records: []TraceRecord{ours.Records[0], ours.Records[1]}}
+
+	if divergence := DiffTraces(ours, reference); divergence != nil {
+		t.Errorf("expected equal traces, got %+v", divergence)
+	}
+
+	reference.Records[1].Writes = []TraceMemoryWrite{{0x300, 0}, {0x301, 4}, {0x302, 3}}
+	expectedDivergence := TraceDivergence{Index: 1, Field: "memory 0x302", Ours: "0x02", Reference: "0x03"}
+	if divergence := DiffTraces(ours, reference); (divergence == nil) || (*divergence != expectedDivergence) {
+		t.Errorf("expected divergence %+v, got %+v", expectedDivergence, divergence)
+	}
+
+	reference.AfterState = false // Only the registers before the instructions are compared
+	if divergence := DiffTraces(ours, reference); divergence != nil {
+		t.Errorf("expected equal traces, got %+v", divergence)
+	}
+
+	reference.Records = append(reference.Records, TraceRecord{PC: 0x204})
+	expectedDivergence = TraceDivergence{Index: 2, Field: "length", Ours: "2 records", Reference: "3 records"}
+	if divergence := DiffTraces(ours, reference); (divergence == nil) || (*divergence != expectedDivergence) {
+		t.Errorf("expected divergence %+v, got %+v", expectedDivergence, divergence)
+	}
+
+	reference.Records[1].V[3] = 1
+	expectedDivergence = TraceDivergence{Index: 1, Field: "V3", Ours: "0x00", Reference: "0x01"}
+	if divergence := DiffTraces(ours, reference); (divergence == nil) || (*divergence != expectedDivergence) {
+		t.Errorf("expected divergence %+v, got %+v", expectedDivergence, divergence)
+	}
+}
@@ -0,0 +1,270 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ttyKeyHoldDuration is how long a key is considered pressed after a key stroke, by default.
+// Terminals only report key strokes (repeated while held down), never key releases. The hold is longer than the usual
+// delay before terminals start repeating a held key (250 to 500 ms), so a held key is not released and pressed again.
+const ttyKeyHoldDuration = 600 * time.Millisecond
+
+// ttyViewerID identifies the terminal among the sources of key input
+const ttyViewerID = "tty"
+
+// ttyEscapeSequenceKeys are the keyboard keys sent by the terminal as escape sequences (in normal and application cursor mode)
+var ttyEscapeSequenceKeys = map[string]string{
+	"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+	"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+}
+
+// ttyControlKeys are the keyboard keys sent by the terminal as control characters
+var ttyControlKeys = map[byte]string{
+	' ': "space", '\r': "enter", '\n': "enter", '\t': "tab",
+}
+
+// TTYFrontend renders the screen in the terminal using half block characters (two pixels per character cell)
+// and reads key strokes from the terminal in raw mode. Sound is signalled by the terminal bell.
+type TTYFrontend struct {
+	input         io.Reader
+	output        io.Writer
+	lock          sync.Mutex
+	cells         [][]rune // cells are the character cells currently shown in the terminal
+	sound         bool
+	keymap        Keymap
+	keyHold       time.Duration // keyHold is how long a key is considered pressed after a key stroke
+	keyDeadlines  [16]time.Time
+	terminalState string
+	stop          chan struct{} // stop is closed when the frontend is closed, stopping the key release ticker
+	closed        bool
+}
+
+// NewTTYFrontend creates a terminal frontend considering keys pressed for the key hold after every key stroke
+// (0 for the default ttyKeyHoldDuration).
+func NewTTYFrontend(keyHold time.Duration) *TTYFrontend {
+	if keyHold <= 0 {
+		keyHold = ttyKeyHoldDuration
+	}
+
+	return &TTYFrontend{
+		input:   os.Stdin,
+		output:  os.Stdout,
+		keyHold: keyHold,
+	}
+}
+
+func (f *TTYFrontend) Start(p *Peripherals) error {
+	terminalState, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("could not read terminal state: %w", err)
+	}
+	f.terminalState = strings.TrimSpace(terminalState)
+
+	if _, err := stty("raw", "-echo"); err != nil {
+		return fmt.Errorf("could not set terminal in raw mode: %w", err)
+	}
+
+	f.lock.Lock()
+	f.cells = nil
+	fmt.Fprint(f.output, "\x1b[?25l\x1b[2J") // Hide cursor and clear terminal
+	f.lock.Unlock()
+
+	f.keymap = p.Keymap()
+	f.stop = make(chan struct{})
+	p.joinViewer(ttyViewerID, viewerRoleController, 0)
+	go f.readKeys(p)
+	go f.releaseKeys(p, f.stop)
+
+	return nil
+}
+
+func (f *TTYFrontend) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.stop != nil {
+		close(f.stop)
+	}
+
+	fmt.Fprintf(f.output, "\x1b[?25h\x1b[%d;1H\r\n", len(f.cells)+2) // Show cursor and move it below the screen
+
+	if f.terminalState != "" {
+		if _, err := stty(f.terminalState); err != nil {
+			return fmt.Errorf("could not restore terminal state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (f *TTYFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if state.sound && !f.sound {
+		fmt.Fprint(f.output, "\a")
+	}
+	f.sound = state.sound
+}
+
+// UpdateScreen redraws the character cells that changed since the last update.
+func (f *TTYFrontend) UpdateScreen(state *PeripheralsState) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	screen := state.screen
+	rows := (int(screen.Height) + 1) / 2
+	columns := int(screen.Width)
+
+	redrawAll := (len(f.cells) != rows) || ((rows > 0) && (len(f.cells[0]) != columns))
+	if redrawAll {
+		f.cells = make([][]rune, rows)
+		for row := range f.cells {
+			f.cells[row] = make([]rune, columns)
+		}
+	}
+
+	var buffer bytes.Buffer
+	for row := 0; row < rows; row++ {
+		cursorColumn := -1 // cursorColumn is the column where the cursor is, -1 if cursor needs to be positioned
+		for column := 0; column < columns; column++ {
+			upper := screen.Value(uint8(column), uint8(row*2)) != 0
+			lower := screen.Value(uint8(column), uint8(row*2+1)) != 0
+			cell := halfBlockCharacter(upper, lower)
+
+			if !redrawAll && (f.cells[row][column] == cell) {
+				cursorColumn = -1
+				continue
+			}
+
+			if cursorColumn != column {
+				fmt.Fprintf(&buffer, "\x1b[%d;%dH", row+1, column+1)
+			}
+			buffer.WriteRune(cell)
+			f.cells[row][column] = cell
+			cursorColumn = column + 1
+		}
+	}
+
+	if redrawAll {
+		fmt.Fprintf(&buffer, "\x1b[%d;1HKeymap: %s    Quit: Ctrl-C", rows+2, f.keymap.Name)
+	}
+
+	if buffer.Len() > 0 {
+		f.output.Write(buffer.Bytes())
+	}
+}
+
+func halfBlockCharacter(upper, lower bool) rune {
+	switch {
+	case upper && lower:
+		return '█'
+	case upper:
+		return '▀'
+	case lower:
+		return '▄'
+	default:
+		return ' '
+	}
+}
+
+func (f *TTYFrontend) readKeys(p *Peripherals) {
+	buffer := make([]byte, 64)
+
+	for {
+		numBytes, err := f.input.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		input := buffer[:numBytes]
+		for len(input) > 0 {
+			if input[0] == 0x03 { // Ctrl-C, not delivered as a signal in raw mode
+				p.RequestQuit()
+				return
+			}
+
+			keyName, length := ttyKeyName(input)
+			input = input[length:]
+
+			if keyCode, ok := f.keymap.HexKey(keyName); ok {
+				f.lock.Lock()
+				f.keyDeadlines[keyCode] = time.Now().Add(f.keyHold)
+				f.lock.Unlock()
+				f.updateKeys(p)
+			}
+		}
+	}
+}
+
+// releaseKeys releases keys that have not been stroke again within the key hold duration, until the frontend is closed.
+func (f *TTYFrontend) releaseKeys(p *Peripherals, stop <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.updateKeys(p)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (f *TTYFrontend) updateKeys(p *Peripherals) {
+	now := time.Now()
+	keys := uint16(0)
+
+	f.lock.Lock()
+	for keyCode, deadline := range f.keyDeadlines {
+		if now.Before(deadline) {
+			keys |= 1 << keyCode
+		}
+	}
+	f.lock.Unlock()
+
+	p.updateViewerKeys(ttyViewerID, keys)
+}
+
+// ttyKeyName is the keymap name of the keyboard key first in the terminal input, and the number of input bytes of the key.
+func ttyKeyName(input []byte) (keyName string, length int) {
+	if input[0] == 0x1b {
+		for sequence, keyName := range ttyEscapeSequenceKeys {
+			if bytes.HasPrefix(input, []byte(sequence)) {
+				return keyName, len(sequence)
+			}
+		}
+	}
+
+	if keyName, isControlKey := ttyControlKeys[input[0]]; isControlKey {
+		return keyName, 1
+	}
+
+	return string(toLowerASCII(input[0])), 1
+}
+
+func toLowerASCII(key byte) byte {
+	if key >= 'A' && key <= 'Z' {
+		return key + ('a' - 'A')
+	}
+	return key
+}
+
+func stty(arguments ...string) (string, error) {
+	command := exec.Command("stty", arguments...)
+	command.Stdin = os.Stdin
+	output, err := command.Output()
+	return string(output), err
+}
@@ -0,0 +1,153 @@
+package chip8
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHalfBlockCharacter(t *testing.T) {
+	tests := []struct {
+		upper, lower bool
+		expected     rune
+	}{
+		{false, false, ' '},
+		{true, false, '▀'},
+		{false, true, '▄'},
+		{true, true, '█'},
+	}
+	for _, test := range tests {
+		if cell := halfBlockCharacter(test.upper, test.lower); cell != test.expected {
+			t.Errorf("upper %v lower %v: expected '%c', got '%c'", test.upper, test.lower, test.expected, cell)
+		}
+	}
+}
+
+func TestTTYFrontendRedrawsChangedCells(t *testing.T) {
+	var output bytes.Buffer
+	frontend := &TTYFrontend{output: &output, keymap: DefaultKeymap()}
+	state := newPeripheralsState()
+
+	frontend.UpdateScreen(state)
+	firstUpdate := output.String()
+	if !strings.HasPrefix(firstUpdate, "\x1b[1;1H"+strings.Repeat(" ", 64)+"\x1b[2;1H") {
+		t.Errorf("expected first update to draw every cell row by row, got %q", firstUpdate[:minInt(len(firstUpdate), 80)])
+	}
+	if !strings.HasSuffix(firstUpdate, "\x1b[18;1HKeymap: "+DefaultKeymap().Name+"    Quit: Ctrl-C") {
+		t.Errorf("expected first update to end with the status line, got %q", firstUpdate[len(firstUpdate)-40:])
+	}
+
+	tests := []struct {
+		name     string
+		pixels   [][2]uint8
+		expected string
+	}{
+		{"unchanged screen", nil, ""},
+		{"lower half of a cell", [][2]uint8{{3, 1}}, "\x1b[1;4H▄"},
+		{"upper half of the same cell", [][2]uint8{{3, 0}}, "\x1b[1;4H█"},
+		{"adjacent cells", [][2]uint8{{5, 30}, {6, 30}}, "\x1b[16;6H▀▀"},
+		{"separate cells", [][2]uint8{{5, 30}, {63, 31}}, "\x1b[16;6H \x1b[16;64H▄"},
+	}
+	for _, test := range tests {
+		for _, pixel := range test.pixels {
+			state.screen.XorPixel(pixel[0], pixel[1], 1)
+		}
+		output.Reset()
+		frontend.UpdateScreen(state)
+		if output.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, output.String())
+		}
+	}
+}
+
+func TestTTYKeyName(t *testing.T) {
+	tests := []struct {
+		input   string
+		keyName string
+		length  int
+	}{
+		{"\x1b[A", "up", 3},
+		{"\x1b[Bq", "down", 3},
+		{"\x1bOC", "right", 3},
+		{"\x1bOD", "left", 3},
+		{"\x1b", "\x1b", 1},
+		{"\x1b[Z", "\x1b", 1},
+		{" ", "space", 1},
+		{"\r", "enter", 1},
+		{"\n", "enter", 1},
+		{"\t", "tab", 1},
+		{"Q", "q", 1},
+		{"4w", "4", 1},
+	}
+	for _, test := range tests {
+		if keyName, length := ttyKeyName([]byte(test.input)); (keyName != test.keyName) || (length != test.length) {
+			t.Errorf("%q: expected key \"%s\" of %d bytes, got \"%s\" of %d bytes", test.input, test.keyName, test.length, keyName, length)
+		}
+	}
+}
+
+func TestTTYKeyNames(t *testing.T) {
+	input := []byte("Q\x1b[A \x1bOD1")
+	expectedKeyNames := []string{"q", "up", "space", "left", "1"}
+
+	for _, expectedKeyName := range expectedKeyNames {
+		keyName, length := ttyKeyName(input)
+		if keyName != expectedKeyName {
+			t.Fatalf("expected key \"%s\", got \"%s\"", expectedKeyName, keyName)
+		}
+		input = input[length:]
+	}
+}
+
+func TestTTYFrontendHoldsKeysLongerThanKeyRepeatDelay(t *testing.T) {
+	for _, keyHold := range []time.Duration{0, 2 * time.Second} {
+		peripherals := NewHeadlessPeripherals()
+		frontend := NewTTYFrontend(keyHold)
+		frontend.input = strings.NewReader("1")
+		frontend.output = &bytes.Buffer{}
+		frontend.keymap = DefaultKeymap()
+
+		stroke := time.Now()
+		frontend.readKeys(&peripherals)
+		if peripherals.pressedKeys() != 0x0002 {
+			t.Fatalf("expected key 1 pressed after its key stroke, got %016b", peripherals.pressedKeys())
+		}
+
+		expectedHold := keyHold
+		if keyHold == 0 {
+			expectedHold = ttyKeyHoldDuration
+		}
+		if hold := frontend.keyDeadlines[0x1].Sub(stroke); (hold < expectedHold) || (hold > expectedHold+time.Second) {
+			t.Errorf("expected key held for %s after its key stroke, got %s", expectedHold, hold)
+		}
+	}
+
+	if ttyKeyHoldDuration <= 500*time.Millisecond {
+		t.Errorf("expected default key hold longer than the terminal key repeat delay (up to 500 ms), got %s", ttyKeyHoldDuration)
+	}
+}
+
+func TestTTYFrontendQuitsOnCtrlC(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+	frontend := &TTYFrontend{input: strings.NewReader("1\x03"), output: &bytes.Buffer{}, keymap: DefaultKeymap()}
+	frontend.readKeys(&peripherals)
+
+	if !peripherals.QuitRequested() {
+		t.Fatal("expected Ctrl-C to request quit")
+	}
+
+	machine := NewChip8(&peripherals)
+	machine.loadROMBytes([]byte{0x12, 0x00}, romAddressDefault) // Loop forever
+	done := make(chan error)
+	go func() { done <- machine.Run(Configuration{}) }()
+
+	select {
+	case err := <-done:
+		if (err != nil) || (machine.Frame != 1) {
+			t.Errorf("expected run to end without error after the frame, got %v after %d frames", err, machine.Frame)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected run to end on quit request")
+	}
+}
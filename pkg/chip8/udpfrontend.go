@@ -0,0 +1,244 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// udpViewerKeyTimeout is the time after which the keys of a viewer joined with a hello that has not been heard from are released.
+// These viewers repeat their key state while keys are held, as there is no connection to notice a viewer going away.
+// Legacy viewers only send key state changes, their keys are kept until their next datagram.
+const udpViewerKeyTimeout = 3 * time.Second
+
+// UDPFrontend is the link to an external screen application (viewer), using the wire protocol in documentation/protocol.adoc.
+// Hello and state messages are sent to the screen application and hello and key messages are received on the key state listener port.
+// Every sender of messages to the key state listener port is a viewer of its own, identified by its address,
+// so several players can send key input from their own controllers. Only hello and ack messages sent from the screen address
+// (by a screen application sending from the socket it receives on) negotiate and acknowledge the screen encoding,
+// other viewers get their granted role in a hello message sent back to them.
+type UDPFrontend struct {
+	screenConnection     net.Conn
+	keyStateListenerPort int
+	keyStateListener     *net.UDPConn
+	lock                 sync.Mutex
+	session              *viewerSession       // session is the link to the screen application
+	viewerSeqs           map[string]uint32    // viewerSeqs is the sequence number of the last received message, by viewer address
+	viewerLastSeen       map[string]time.Time // viewerLastSeen is the time of the last received message, by address of viewers joined with a hello
+	helloViewers         map[string]bool      // helloViewers is the addresses of the viewers joined with a hello, repeating their key state
+	closed               chan struct{}
+}
+
+// NewUDPFrontend creates a link to the screen application. Compression enables compressed screen updates,
+// used only if the screen application announces support for it.
+func NewUDPFrontend(screenAddress string, keyStateListenerPort int, compression bool) (*UDPFrontend, error) {
+	screenConnection, err := net.Dial("udp", screenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("could not create screenConnection to screen %w", err)
+	}
+
+	write := func(data []byte) error {
+		_, err := screenConnection.Write(data)
+		return err
+	}
+
+	return &UDPFrontend{
+		screenConnection:     screenConnection,
+		keyStateListenerPort: keyStateListenerPort,
+		session:              newViewerSession("udp:"+screenAddress, compression, marshalMessage, write),
+		viewerSeqs:           map[string]uint32{},
+		viewerLastSeen:       map[string]time.Time{},
+		helloViewers:         map[string]bool{},
+		closed:               make(chan struct{}),
+	}, nil
+}
+
+func (f *UDPFrontend) Start(p *Peripherals) error {
+	keyPadMaxDatagramSize := 256
+
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", f.keyStateListenerPort))
+	if err != nil {
+		return fmt.Errorf("could not resolve key state listener port %d: %w", f.keyStateListenerPort, err)
+	}
+
+	sock, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen for key states on port %d: %w", f.keyStateListenerPort, err)
+	}
+	sock.SetReadBuffer(keyPadMaxDatagramSize)
+	f.keyStateListener = sock
+
+	f.send(getHelloMessage(p.snapshot()), "hello")
+
+	go f.listenForViewerMessages(p, keyPadMaxDatagramSize)
+	go f.releaseSilentViewerKeys(p)
+
+	return nil
+}
+
+func (f *UDPFrontend) listenForViewerMessages(p *Peripherals, keyPadMaxDatagramSize int) {
+	buffer := make([]byte, keyPadMaxDatagramSize)
+
+	// Loop forever reading from the socket
+	for {
+		numBytes, viewerAddress, err := f.keyStateListener.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			fmt.Printf("chip-8 key state listener: read from UDP failed: %s\n", err.Error())
+			continue
+		}
+
+		message, err := decodeViewerDatagram(buffer[:numBytes])
+		if err != nil {
+			fmt.Printf("chip-8 key state listener: rejected message from %s: %s\n", viewerAddress, err.Error())
+			continue
+		}
+
+		if !f.acceptViewerSequence(viewerAddress.String(), message.Header) {
+			continue // Stale or out of order datagram
+		}
+
+		viewerID := "udp:" + viewerAddress.String()
+
+		switch {
+		case message.Hello != nil:
+			p.joinViewer(viewerID, message.Hello.Role, message.Hello.Keypad)
+			state := p.snapshot()
+			hello := getHelloMessage(state)
+			hello.Role = p.viewerRole(viewerID)
+
+			if !f.isScreenAddress(viewerAddress) {
+				// Other viewers only send key input, they get the granted role but do not change the screen encoding
+				f.sendTo(viewerAddress, hello)
+				continue
+			}
+
+			// A (re)connected screen application gets the interpreter capabilities and a full screen
+			f.session.lock.Lock()
+			f.session.screenEncoder.negotiate(message.Hello)
+			f.session.lock.Unlock()
+			f.send(hello, "hello")
+			f.UpdateScreen(state)
+		case message.Keys != nil:
+			p.updateViewerKeys(viewerID, message.Keys.Keys)
+		case (message.Ack != nil) && f.isScreenAddress(viewerAddress):
+			f.session.lock.Lock()
+			f.session.screenEncoder.acknowledge(message.Ack.Ack)
+			f.session.lock.Unlock()
+		}
+	}
+}
+
+// isScreenAddress reports if the viewer address is the screen address, the screen application sending from the socket it receives on.
+func (f *UDPFrontend) isScreenAddress(viewerAddress *net.UDPAddr) bool {
+	screenAddress, isUDPAddress := f.screenConnection.RemoteAddr().(*net.UDPAddr)
+	return isUDPAddress && screenAddress.IP.Equal(viewerAddress.IP) && (screenAddress.Port == viewerAddress.Port)
+}
+
+// acceptViewerSequence reports if a message is newer than the last message received from the viewer.
+// Unsequenced messages (sequence number 0) and hello messages (that restart the sequence) are always accepted.
+func (f *UDPFrontend) acceptViewerSequence(viewerAddress string, header messageHeader) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if header.Type == messageTypeHello {
+		f.helloViewers[viewerAddress] = true
+	}
+	if f.helloViewers[viewerAddress] {
+		f.viewerLastSeen[viewerAddress] = time.Now()
+	}
+
+	if header.Type == messageTypeHello {
+		f.viewerSeqs[viewerAddress] = header.Seq
+		return true
+	}
+
+	if header.Seq == 0 {
+		return true
+	}
+
+	previousSeq, seen := f.viewerSeqs[viewerAddress]
+	if seen && !isNewerSequence(header.Seq, previousSeq) {
+		return false
+	}
+
+	f.viewerSeqs[viewerAddress] = header.Seq
+	return true
+}
+
+// releaseSilentViewerKeys releases the keys of viewers joined with a hello not heard from within the key timeout.
+func (f *UDPFrontend) releaseSilentViewerKeys(p *Peripherals) {
+	ticker := time.NewTicker(udpViewerKeyTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.closed:
+			return
+		case now := <-ticker.C:
+			f.releaseViewerKeysSilentAt(p, now)
+		}
+	}
+}
+
+// releaseViewerKeysSilentAt releases the keys of the viewers joined with a hello not heard from within the key timeout at the time.
+func (f *UDPFrontend) releaseViewerKeysSilentAt(p *Peripherals, now time.Time) {
+	f.lock.Lock()
+	var silentViewers []string
+	for viewerAddress, lastSeen := range f.viewerLastSeen {
+		if now.Sub(lastSeen) > udpViewerKeyTimeout {
+			silentViewers = append(silentViewers, viewerAddress)
+			delete(f.viewerLastSeen, viewerAddress)
+		}
+	}
+	f.lock.Unlock()
+
+	for _, viewerAddress := range silentViewers {
+		p.releaseViewerKeys("udp:" + viewerAddress)
+	}
+}
+
+// send sends the message to the screen application.
+func (f *UDPFrontend) send(message interface{ setSeq(seq uint32) }, description string) {
+	if err := f.session.send(message); err != nil {
+		fmt.Printf("could not update peripherals %s: %s\n", description, err.Error())
+		fmt.Println("(is screen application up and running?)")
+	}
+}
+
+// sendTo sends the unsequenced message to a viewer other than the screen application.
+func (f *UDPFrontend) sendTo(viewerAddress *net.UDPAddr, message interface{}) {
+	serializedMessage, err := marshalMessage(message)
+	if err == nil {
+		_, err = f.keyStateListener.WriteToUDP(serializedMessage, viewerAddress)
+	}
+	if err != nil {
+		fmt.Printf("could not send message to viewer %s: %s\n", viewerAddress, err.Error())
+	}
+}
+
+func (f *UDPFrontend) Close() error {
+	f.lock.Lock()
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+	f.lock.Unlock()
+
+	if f.keyStateListener != nil {
+		f.keyStateListener.Close()
+	}
+	return f.screenConnection.Close()
+}
+
+func (f *UDPFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.send(getSoundAndKeysMessage(state), "sound and key state")
+}
+
+func (f *UDPFrontend) UpdateScreen(state *PeripheralsState) {
+	f.send(getScreenMessage(state), "screen (plus sound and key) state")
+}
@@ -0,0 +1,143 @@
+package chip8
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+	"net"
+	"testing"
+	"time"
+)
+
+// startUDPFrontend starts a UDP frontend sending to a screen application socket, returning the frontend,
+// its peripherals, the screen application socket and the address of the key state listener.
+func startUDPFrontend(t *testing.T, compression bool) (*UDPFrontend, *Peripherals, *net.UDPConn, *net.UDPAddr) {
+	screenApplication, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("could not listen for screen updates: %s", err)
+	}
+	t.Cleanup(func() { screenApplication.Close() })
+
+	frontend, err := NewUDPFrontend(screenApplication.LocalAddr().String(), 0, compression)
+	if err != nil {
+		t.Fatalf("could not create UDP frontend: %s", err)
+	}
+	peripherals := NewPeripheralsWithFrontend(frontend)
+	if err := frontend.Start(&peripherals); err != nil {
+		t.Fatalf("could not start UDP frontend: %s", err)
+	}
+	t.Cleanup(func() { frontend.Close() })
+
+	keyStateAddress := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: frontend.keyStateListener.LocalAddr().(*net.UDPAddr).Port}
+	return frontend, &peripherals, screenApplication, keyStateAddress
+}
+
+// dialUDPViewer is a viewer sending datagrams to the key state listener.
+func dialUDPViewer(t *testing.T, keyStateAddress *net.UDPAddr) *net.UDPConn {
+	viewer, err := net.DialUDP("udp", nil, keyStateAddress)
+	if err != nil {
+		t.Fatalf("could not connect to key state listener: %s", err)
+	}
+	t.Cleanup(func() { viewer.Close() })
+	return viewer
+}
+
+// sendViewerMessage sends the msgpack serialized message from the viewer.
+func sendViewerMessage(t *testing.T, viewer *net.UDPConn, message interface{}) {
+	if _, err := viewer.Write(serializeMessage(message)); err != nil {
+		t.Fatalf("could not send viewer message: %s", err)
+	}
+}
+
+// waitForKeys waits until the merged key state of the viewers is the keys.
+func waitForKeys(t *testing.T, p *Peripherals, keys uint16) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.viewersLock.Lock()
+		mergedKeys := p.state.keys
+		p.viewersLock.Unlock()
+
+		if mergedKeys == keys {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected key state %016b, got %016b", keys, mergedKeys)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUDPFrontendReleasesOnlyKeysOfSilentHelloViewers(t *testing.T) {
+	frontend, peripherals, _, keyStateAddress := startUDPFrontend(t, false)
+
+	legacyViewer := dialUDPViewer(t, keyStateAddress)
+	if _, err := legacyViewer.Write([]byte{0x00, 0x01}); err != nil {
+		t.Fatalf("could not send legacy key state: %s", err)
+	}
+	waitForKeys(t, peripherals, 0x0001)
+
+	player := dialUDPViewer(t, keyStateAddress)
+	sendViewerMessage(t, player, &helloMessage{messageHeader: newMessageHeader(messageTypeHello, 1), Role: viewerRolePlayer, Keypad: 0x0002})
+	sendViewerMessage(t, player, &keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 2), Keys: 0x0002})
+	waitForKeys(t, peripherals, 0x0003)
+
+	// The legacy viewer holds its key without sending, the player is silent too
+	frontend.releaseViewerKeysSilentAt(peripherals, time.Now().Add(2*udpViewerKeyTimeout))
+	waitForKeys(t, peripherals, 0x0001)
+
+	if _, err := legacyViewer.Write([]byte{0x00, 0x00}); err != nil {
+		t.Fatalf("could not send legacy key state: %s", err)
+	}
+	waitForKeys(t, peripherals, 0x0000)
+}
+
+// readUDPMessage reads the next message from the socket into the message.
+func readUDPMessage(t *testing.T, connection *net.UDPConn, message interface{}) {
+	buffer := make([]byte, 65536)
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	numBytes, _, err := connection.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatalf("could not read message: %s", err)
+	}
+	if err := msgpack.Unmarshal(buffer[:numBytes], message); err != nil {
+		t.Fatalf("could not unmarshal message: %s", err)
+	}
+}
+
+func TestUDPFrontendNegotiatesScreenEncodingWithScreenApplicationOnly(t *testing.T) {
+	frontend, peripherals, screenApplication, keyStateAddress := startUDPFrontend(t, true)
+	readUDPMessage(t, screenApplication, &helloMessage{}) // The interpreter hello on start
+
+	// Another viewer announcing deflate gets its role, the screen application still gets uncompressed screens
+	player := dialUDPViewer(t, keyStateAddress)
+	sendViewerMessage(t, player, &helloMessage{messageHeader: newMessageHeader(messageTypeHello, 1), Role: viewerRolePlayer, Keypad: 0x0002,
+		Encodings: []string{screenEncodingFull, screenEncodingXor, screenCompressionDeflate}})
+
+	hello := helloMessage{}
+	readUDPMessage(t, player, &hello)
+	if (hello.Type != messageTypeHello) || (hello.Role != viewerRolePlayer) {
+		t.Fatalf("expected hello message granting the player role to the viewer, got %+v", hello)
+	}
+
+	frontend.UpdateScreen(peripherals.state)
+	message := peripheralStateMessage{}
+	readUDPMessage(t, screenApplication, &message)
+	if (message.Type != messageTypeState) || (message.Compression != "") || (message.Encoding != screenEncodingFull) {
+		t.Fatalf("expected full uncompressed screen for the screen application, got %+v", message.messageHeader)
+	}
+
+	// The screen application announcing deflate from its screen socket gets compressed screens
+	helloBytes := serializeMessage(&helloMessage{messageHeader: newMessageHeader(messageTypeHello, 1),
+		Encodings: []string{screenEncodingFull, screenCompressionDeflate}})
+	if _, err := screenApplication.WriteToUDP(helloBytes, keyStateAddress); err != nil {
+		t.Fatalf("could not send hello message: %s", err)
+	}
+
+	readUDPMessage(t, screenApplication, &hello)
+	if hello.Type != messageTypeHello {
+		t.Fatalf("expected hello message, got %+v", hello.messageHeader)
+	}
+	message = peripheralStateMessage{}
+	readUDPMessage(t, screenApplication, &message)
+	if (message.Type != messageTypeState) || (message.Compression != screenCompressionDeflate) {
+		t.Fatalf("expected deflate compressed screen for the screen application, got %+v", message)
+	}
+}
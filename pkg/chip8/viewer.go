@@ -0,0 +1,248 @@
+package chip8
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	viewerRoleController = "controller" // viewerRoleController is the viewer whose key input is accepted, for all keys not owned by players
+	viewerRolePlayer     = "player"     // viewerRolePlayer is a viewer whose key input is accepted for the keys it owns
+	viewerRoleSpectator  = "spectator"  // viewerRoleSpectator is a viewer that only watches, its key input is ignored
+)
+
+// viewerSession is the protocol state of the link to one remote viewer: sequence numbers and screen encoding.
+// Messages are serialized and written by the link specific functions.
+type viewerSession struct {
+	id            string // id identifies the viewer among all viewers of the peripherals, like "tcp:127.0.0.1:50123"
+	lock          sync.Mutex
+	seq           uint32 // seq is the sequence number of the last sent message
+	viewerSeq     uint32 // viewerSeq is the sequence number of the last accepted message from the viewer
+	screenEncoder *screenEncoder
+	serialize     func(message interface{}) ([]byte, error)
+	write         func(data []byte) error
+}
+
+func newViewerSession(id string, compression bool, serialize func(message interface{}) ([]byte, error), write func(data []byte) error) *viewerSession {
+	return &viewerSession{
+		id:            id,
+		screenEncoder: newScreenEncoder(compression),
+		serialize:     serialize,
+		write:         write,
+	}
+}
+
+// send stamps the message with the next sequence number, encodes the screen of state messages and writes it to the viewer.
+func (s *viewerSession) send(message interface{ setSeq(seq uint32) }) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seq = nextSequence(s.seq)
+	message.setSeq(s.seq)
+	if stateMessage, isStateMessage := message.(*peripheralStateMessage); isStateMessage {
+		s.screenEncoder.encode(stateMessage)
+	}
+
+	serializedMessage, err := s.serialize(message)
+	if err != nil {
+		return fmt.Errorf("could not marshal data: %w", err)
+	}
+
+	return s.write(serializedMessage)
+}
+
+// sendWelcome sends the interpreter hello message, telling a joined viewer its role, followed by the full screen (a keyframe).
+func (s *viewerSession) sendWelcome(p *Peripherals) error {
+	state := p.snapshot()
+	hello := getHelloMessage(state)
+	hello.Role = p.viewerRole(s.id)
+
+	if err := s.send(hello); err != nil {
+		return err
+	}
+
+	return s.send(getScreenMessage(state))
+}
+
+// accept reports if a message is newer than the last accepted message from the viewer.
+// Unsequenced messages (sequence number 0) and hello messages (that restart the sequence) are always accepted.
+func (s *viewerSession) accept(header messageHeader) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if (header.Type != messageTypeHello) && (header.Seq != 0) && (s.viewerSeq != 0) && !isNewerSequence(header.Seq, s.viewerSeq) {
+		return false
+	}
+
+	s.viewerSeq = header.Seq
+	return true
+}
+
+// receive acts on a message from the viewer.
+func (s *viewerSession) receive(p *Peripherals, message *viewerMessage) {
+	if !s.accept(message.Header) {
+		return // Stale or out of order message
+	}
+
+	switch {
+	case message.Hello != nil:
+		s.lock.Lock()
+		s.screenEncoder.negotiate(message.Hello)
+		s.lock.Unlock()
+		p.joinViewer(s.id, message.Hello.Role, message.Hello.Keypad)
+		s.sendWelcome(p)
+	case message.Keys != nil:
+		p.updateViewerKeys(s.id, message.Keys.Keys)
+	case message.Ack != nil:
+		s.lock.Lock()
+		s.screenEncoder.acknowledge(message.Ack.Ack)
+		s.lock.Unlock()
+	}
+}
+
+// viewerRegistration is a remote viewer joined to the peripherals, a source of key input.
+type viewerRegistration struct {
+	id           string
+	wantsControl bool
+	keypad       uint16 // keypad is the keys owned by a player, 0 for other roles
+	keys         uint16 // keys is the latest key state from the viewer
+}
+
+// joinViewer registers a remote viewer, or updates its registration, with the requested role ("" asks for control).
+// A player owns the keys of the keypad mask. The viewer becomes the controller if it asks for control and there is no controller,
+// otherwise it is a spectator.
+func (p *Peripherals) joinViewer(viewerID string, role string, keypad uint16) {
+	p.viewersLock.Lock()
+	defer p.viewersLock.Unlock()
+
+	registration := viewerRegistration{id: viewerID}
+	switch {
+	case (role == viewerRolePlayer) && (keypad != 0):
+		registration.keypad = keypad
+	case (role == viewerRoleController) || (role == ""):
+		registration.wantsControl = true
+	}
+
+	joined := false
+	for i := range p.viewers {
+		if p.viewers[i].id == viewerID {
+			registration.keys = p.viewers[i].keys
+			p.viewers[i] = registration
+			joined = true
+		}
+	}
+	if !joined {
+		p.viewers = append(p.viewers, registration)
+	}
+
+	if (p.controller == viewerID) && !registration.wantsControl {
+		p.controller = ""
+	}
+	p.assignController()
+	p.mergeViewerKeys()
+}
+
+// leaveViewer unregisters a remote viewer and releases its keys.
+// If it was the controller, control passes on to the earliest joined viewer asking for it.
+func (p *Peripherals) leaveViewer(viewerID string) {
+	p.viewersLock.Lock()
+	defer p.viewersLock.Unlock()
+
+	for i := range p.viewers {
+		if p.viewers[i].id == viewerID {
+			p.viewers = append(p.viewers[:i], p.viewers[i+1:]...)
+			break
+		}
+	}
+
+	if p.controller == viewerID {
+		p.controller = ""
+		p.assignController()
+	}
+	p.mergeViewerKeys()
+}
+
+// assignController gives control to the earliest joined viewer asking for it, if there is no controller.
+func (p *Peripherals) assignController() {
+	if p.controller != "" {
+		return
+	}
+
+	for _, viewer := range p.viewers {
+		if viewer.wantsControl {
+			p.controller = viewer.id
+			return
+		}
+	}
+}
+
+// viewerRole is the role of a remote viewer, "controller", "player" or "spectator", or "" if the viewer has not joined.
+func (p *Peripherals) viewerRole(viewerID string) string {
+	p.viewersLock.Lock()
+	defer p.viewersLock.Unlock()
+
+	if p.controller == viewerID {
+		return viewerRoleController
+	}
+
+	for _, viewer := range p.viewers {
+		if viewer.id == viewerID {
+			if viewer.keypad != 0 {
+				return viewerRolePlayer
+			}
+			return viewerRoleSpectator
+		}
+	}
+	return ""
+}
+
+// updateViewerKeys updates the key state from a remote viewer, merged with the key state of all other viewers.
+// A viewer sending keys without having joined (like legacy viewers without handshake) joins asking for control.
+func (p *Peripherals) updateViewerKeys(viewerID string, keys uint16) {
+	if p.viewerRole(viewerID) == "" {
+		p.joinViewer(viewerID, viewerRoleController, 0)
+	}
+
+	p.viewersLock.Lock()
+	defer p.viewersLock.Unlock()
+
+	for i := range p.viewers {
+		if p.viewers[i].id == viewerID {
+			p.viewers[i].keys = keys
+		}
+	}
+	p.mergeViewerKeys()
+}
+
+// releaseViewerKeys releases all keys of a remote viewer, like a viewer that has not been heard from for a while.
+func (p *Peripherals) releaseViewerKeys(viewerID string) {
+	p.viewersLock.Lock()
+	defer p.viewersLock.Unlock()
+
+	for i := range p.viewers {
+		if p.viewers[i].id == viewerID {
+			p.viewers[i].keys = 0b0000000000000000
+		}
+	}
+	p.mergeViewerKeys()
+}
+
+// mergeViewerKeys updates the key state to the keys pressed by any viewer, counting only the keys a viewer owns:
+// players own the keys of their keypad mask and the controller owns all other keys. Spectators own no keys.
+func (p *Peripherals) mergeViewerKeys() {
+	playerKeypads := uint16(0)
+	for _, viewer := range p.viewers {
+		playerKeypads |= viewer.keypad
+	}
+
+	keys := uint16(0)
+	for _, viewer := range p.viewers {
+		if viewer.id == p.controller {
+			keys |= viewer.keys &^ playerKeypads
+		} else {
+			keys |= viewer.keys & viewer.keypad
+		}
+	}
+
+	p.UpdateKeys(keys)
+}
@@ -0,0 +1,203 @@
+package chip8
+
+import (
+	"bufio"
+	"github.com/vmihailenco/msgpack/v5"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestControllerIsEarliestViewerAskingForControl(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+
+	peripherals.joinViewer("big screen", viewerRoleSpectator, 0)
+	peripherals.joinViewer("laptop", viewerRoleController, 0)
+	peripherals.joinViewer("phone", "", 0)
+
+	if role := peripherals.viewerRole("laptop"); role != viewerRoleController {
+		t.Fatalf("expected laptop to be controller, got \"%s\"", role)
+	}
+
+	peripherals.updateViewerKeys("big screen", 0x0001)
+	peripherals.updateViewerKeys("phone", 0x0002)
+	if peripherals.state.keys != 0 {
+		t.Fatalf("expected spectator keys to be ignored, got key state %016b", peripherals.state.keys)
+	}
+
+	peripherals.updateViewerKeys("laptop", 0x0004)
+	if peripherals.state.keys != 0x0004 {
+		t.Fatalf("expected controller keys to be accepted, got key state %016b", peripherals.state.keys)
+	}
+
+	// The keys held by the new controller take effect
+	peripherals.leaveViewer("laptop")
+	if peripherals.state.keys != 0x0002 {
+		t.Fatalf("expected keys of leaving controller to be released, got key state %016b", peripherals.state.keys)
+	}
+	if role := peripherals.viewerRole("phone"); role != viewerRoleController {
+		t.Fatalf("expected control to pass on to phone, got \"%s\"", role)
+	}
+	if role := peripherals.viewerRole("big screen"); role != viewerRoleSpectator {
+		t.Fatalf("expected big screen to remain spectator, got \"%s\"", role)
+	}
+}
+
+// dialStreamViewer connects a viewer to a stream listener frontend and says hello, asking for the role.
+func dialStreamViewer(t *testing.T, address string, role string) (net.Conn, *bufio.Reader) {
+	connection, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("could not connect viewer: %s", err)
+	}
+	connection.SetDeadline(time.Now().Add(5 * time.Second))
+
+	hello, _ := msgpack.Marshal(&helloMessage{messageHeader: newMessageHeader(messageTypeHello, 1), Name: "test", Role: role})
+	if err := writeStreamMessage(connection, hello); err != nil {
+		t.Fatalf("could not write hello message: %s", err)
+	}
+
+	return connection, bufio.NewReader(connection)
+}
+
+// readStreamHello reads messages until the interpreter hello granting a role, and the screen following it.
+func readStreamHello(t *testing.T, reader *bufio.Reader) (*helloMessage, *peripheralStateMessage) {
+	for {
+		data, err := readStreamMessage(reader)
+		if err != nil {
+			t.Fatalf("could not read hello message: %s", err)
+		}
+
+		hello := helloMessage{}
+		if err := msgpack.Unmarshal(data, &hello); (err != nil) || (hello.Type != messageTypeHello) || (hello.Role == "") {
+			continue // Welcome message sent before the viewer hello was received
+		}
+
+		data, err = readStreamMessage(reader)
+		if err != nil {
+			t.Fatalf("could not read screen message: %s", err)
+		}
+		state := peripheralStateMessage{}
+		if err := msgpack.Unmarshal(data, &state); (err != nil) || (state.Type != messageTypeState) {
+			t.Fatalf("expected state message following hello message, got %+v (%v)", state, err)
+		}
+
+		return &hello, &state
+	}
+}
+
+func TestStreamListenerBroadcastsToControllerAndSpectators(t *testing.T) {
+	frontend := NewStreamListenerFrontend("tcp", "127.0.0.1:0", false)
+	peripherals := NewPeripheralsWithFrontend(frontend)
+	peripherals.state.screen.XorPixel(0, 0, 1)
+	peripherals.StartKeyPadListener()
+	defer peripherals.Close()
+
+	controller, controllerReader := dialStreamViewer(t, frontend.Address(), viewerRoleController)
+	defer controller.Close()
+	if hello, _ := readStreamHello(t, controllerReader); hello.Role != viewerRoleController {
+		t.Fatalf("expected first viewer to be controller, got \"%s\"", hello.Role)
+	}
+
+	spectator, spectatorReader := dialStreamViewer(t, frontend.Address(), viewerRoleSpectator)
+	defer spectator.Close()
+	hello, screen := readStreamHello(t, spectatorReader)
+	if hello.Role != viewerRoleSpectator {
+		t.Fatalf("expected second viewer to be spectator, got \"%s\"", hello.Role)
+	}
+	if (screen.Encoding != screenEncodingFull) || (len(screen.Screen) != 64*32/8) || (screen.Screen[0] != 0b10000000) {
+		t.Fatalf("expected late joining viewer to get a keyframe, got %+v", screen)
+	}
+
+	spectatorKeys, _ := msgpack.Marshal(&keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 2), Keys: 0x0001})
+	writeStreamMessage(spectator, spectatorKeys)
+	controllerKeys, _ := msgpack.Marshal(&keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 2), Keys: 0x0030})
+	writeStreamMessage(controller, controllerKeys)
+
+	// Only the controller key state is accepted, and broadcast to all viewers
+	for _, reader := range []*bufio.Reader{controllerReader, spectatorReader} {
+		data, err := readStreamMessage(reader)
+		if err != nil {
+			t.Fatalf("could not read state message: %s", err)
+		}
+		state := peripheralStateMessage{}
+		if err := msgpack.Unmarshal(data, &state); (err != nil) || (state.Keys != 0x0030) {
+			t.Fatalf("expected key state 0x0030, got %+v (%v)", state, err)
+		}
+	}
+}
+
+func TestPlayerKeysAreMergedWithControllerKeys(t *testing.T) {
+	peripherals := NewHeadlessPeripherals()
+
+	// Pong: the left player uses keys 1 and 4, the right player owns keys C and D
+	peripherals.joinViewer("left", viewerRoleController, 0)
+	peripherals.joinViewer("right", viewerRolePlayer, 0x3000)
+	if role := peripherals.viewerRole("right"); role != viewerRolePlayer {
+		t.Fatalf("expected right to be player, got \"%s\"", role)
+	}
+
+	peripherals.updateViewerKeys("left", 0x0002)
+	peripherals.updateViewerKeys("right", 0x1000)
+	if peripherals.state.keys != 0x1002 {
+		t.Fatalf("expected merged key state %016b, got %016b", 0x1002, peripherals.state.keys)
+	}
+
+	// Keys owned by a player are ignored from the controller, keys not owned by a player are ignored from the player
+	peripherals.updateViewerKeys("left", 0x2002)
+	peripherals.updateViewerKeys("right", 0x1010)
+	if peripherals.state.keys != 0x1002 {
+		t.Fatalf("expected key state %016b for owned keys only, got %016b", 0x1002, peripherals.state.keys)
+	}
+
+	peripherals.releaseViewerKeys("right")
+	if peripherals.state.keys != 0x0002 {
+		t.Fatalf("expected released player keys, got %016b", peripherals.state.keys)
+	}
+
+	peripherals.updateViewerKeys("right", 0x2000)
+	peripherals.leaveViewer("left")
+	if peripherals.state.keys != 0x2000 {
+		t.Fatalf("expected keys of leaving controller to be released, got %016b", peripherals.state.keys)
+	}
+}
+
+// TestViewersJoiningWhileRunning connects viewers, sending keys, while the interpreter draws.
+// Run with "go test -race" to check the frontends encode snapshots of the state.
+func TestViewersJoiningWhileRunning(t *testing.T) {
+	streamListener := NewStreamListenerFrontend("tcp", "127.0.0.1:0", true)
+	webFrontend := NewWebFrontend("127.0.0.1:0", true)
+	peripherals := NewPeripheralsWithFrontend(streamListener, webFrontend)
+	peripherals.StartKeyPadListener()
+	defer peripherals.Close()
+
+	machine := NewChip8(&peripherals)
+	machine.LoadROM("../../roms/BRIX.ch8")
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- machine.Run(Configuration{ModeRomCompatibility: true, CyclesPerFrame: 100, Headless: true})
+	}()
+
+	for viewer := uint16(0); viewer < 10; viewer++ {
+		connection, reader := dialStreamViewer(t, streamListener.Address(), viewerRoleController)
+		readStreamHello(t, reader)
+		keys, _ := msgpack.Marshal(&keysMessage{messageHeader: newMessageHeader(messageTypeKeys, 2), Keys: 1 << (viewer % 16)})
+		writeStreamMessage(connection, keys)
+		connection.Close()
+
+		client := dialWebSocket(t, webFrontend.Address())
+		if _, _, err := client.ReadMessage(); err != nil {
+			t.Fatalf("could not read hello message: %s", err)
+		}
+		writeMaskedMessage(client, []byte(`{"version": 1, "type": "keys", "seq": 1, "keys": 1}`))
+		client.Close()
+	}
+
+	peripherals.RequestQuit()
+	if err := <-runErr; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if machine.Frame == 0 {
+		t.Fatal("expected the interpreter to run while viewers joined")
+	}
+}
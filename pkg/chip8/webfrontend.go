@@ -0,0 +1,148 @@
+package chip8
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"sync"
+)
+
+//go:embed web
+var webContent embed.FS
+
+// WebFrontend serves a browser frontend (an HTML5 canvas page) over HTTP.
+// The browser and the interpreter exchange the messages of the wire protocol (documentation/protocol.adoc) over a WebSocket,
+// JSON encoded rather than msgpack encoded. Any number of browsers may be connected, key input is accepted from the controller only.
+type WebFrontend struct {
+	address     string
+	compression bool // compression enables deflate compression of screens for browsers supporting it
+	listener    net.Listener
+	lock        sync.Mutex
+	clients     map[*viewerSession]*webSocketConnection // clients are the connected browsers
+}
+
+func NewWebFrontend(address string, compression bool) *WebFrontend {
+	return &WebFrontend{
+		address:     address,
+		compression: compression,
+		clients:     map[*viewerSession]*webSocketConnection{},
+	}
+}
+
+func (f *WebFrontend) Start(p *Peripherals) error {
+	listener, err := net.Listen("tcp", f.address)
+	if err != nil {
+		return fmt.Errorf("could not listen for http connections on \"%s\": %w", f.address, err)
+	}
+	f.listener = listener
+
+	webRoot, _ := fs.Sub(webContent, "web")
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(webRoot)))
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		f.serveWebSocket(p, w, r)
+	})
+	mux.HandleFunc("/keymap.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Keymap().Keys())
+	})
+
+	go http.Serve(listener, mux)
+
+	return nil
+}
+
+// Address is the address the HTTP server listens to.
+func (f *WebFrontend) Address() string {
+	if f.listener == nil {
+		return f.address
+	}
+	return f.listener.Addr().String()
+}
+
+func (f *WebFrontend) serveWebSocket(p *Peripherals, w http.ResponseWriter, r *http.Request) {
+	client, err := upgradeWebSocket(w, r)
+	if err != nil {
+		fmt.Printf("could not accept browser connection: %s\n", err.Error())
+		return
+	}
+
+	write := func(data []byte) error {
+		err := client.WriteMessage(webSocketOpcodeText, data)
+		if err != nil {
+			client.Close()
+		}
+		return err
+	}
+	session := newViewerSession("web:"+r.RemoteAddr, f.compression, json.Marshal, write)
+
+	f.lock.Lock()
+	f.clients[session] = client
+	f.lock.Unlock()
+
+	session.sendWelcome(p)
+
+	for {
+		_, payload, err := client.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		message, err := decodeViewerMessage(payload, json.Unmarshal)
+		if err != nil {
+			fmt.Printf("rejected message from browser: %s\n", err.Error())
+			continue
+		}
+
+		session.receive(p, message)
+	}
+
+	f.lock.Lock()
+	delete(f.clients, session)
+	f.lock.Unlock()
+	client.Close()
+
+	p.leaveViewer(session.id) // Release all keys of a disconnected controlling browser
+}
+
+// broadcast sends the message to all connected browsers. Every browser gets its own copy,
+// as the sequence number and screen encoding differ between browsers.
+func (f *WebFrontend) broadcast(newMessage func() interface{ setSeq(seq uint32) }) {
+	f.lock.Lock()
+	sessions := make([]*viewerSession, 0, len(f.clients))
+	for session := range f.clients {
+		sessions = append(sessions, session)
+	}
+	f.lock.Unlock()
+
+	for _, session := range sessions {
+		session.send(newMessage())
+	}
+}
+
+func (f *WebFrontend) UpdateScreen(state *PeripheralsState) {
+	f.broadcast(func() interface{ setSeq(seq uint32) } { return getScreenMessage(state) })
+}
+
+func (f *WebFrontend) UpdateSoundAndKeys(state *PeripheralsState) {
+	f.broadcast(func() interface{ setSeq(seq uint32) } { return getSoundAndKeysMessage(state) })
+}
+
+func (f *WebFrontend) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, client := range f.clients {
+		client.Close()
+	}
+
+	if f.listener != nil {
+		return f.listener.Close()
+	}
+
+	return nil
+}
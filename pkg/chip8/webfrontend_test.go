@@ -0,0 +1,158 @@
+package chip8
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// dialWebSocket is a minimal WebSocket client, performing the opening handshake of RFC 6455.
+func dialWebSocket(t *testing.T, address string) *webSocketConnection {
+	connection, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("could not connect to web frontend: %s", err)
+	}
+	connection.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(connection, "GET /ws HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", address)
+
+	reader := bufio.NewReader(connection)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("could not read websocket handshake response: %s", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected websocket handshake response status %s", response.Status)
+	}
+	if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected websocket accept key \"%s\"", accept)
+	}
+
+	return &webSocketConnection{connection: connection, reader: reader}
+}
+
+// writeMaskedMessage writes a message the way a client must, masked.
+func writeMaskedMessage(c *webSocketConnection, payload []byte) error {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | webSocketOpcodeText, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.connection.Write(frame)
+	return err
+}
+
+func TestWebFrontendStreamsScreenAndReceivesKeys(t *testing.T) {
+	frontend := NewWebFrontend("127.0.0.1:0", true)
+	peripherals := NewPeripheralsWithFrontend(frontend)
+	peripherals.state.screen.XorPixel(0, 0, 1)
+
+	if err := frontend.Start(&peripherals); err != nil {
+		t.Fatalf("could not start web frontend: %s", err)
+	}
+	defer frontend.Close()
+
+	client := dialWebSocket(t, frontend.Address())
+	defer client.Close()
+
+	_, payload, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read hello message: %s", err)
+	}
+
+	hello := helloMessage{}
+	if err := json.Unmarshal(payload, &hello); (err != nil) || (hello.Type != messageTypeHello) || (hello.Version != protocolVersion) {
+		t.Fatalf("expected hello message, got %+v (%v)", hello, err)
+	}
+
+	_, payload, err = client.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read initial screen message: %s", err)
+	}
+
+	message := peripheralStateMessage{}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatalf("could not unmarshal screen message: %s", err)
+	}
+	if (message.Type != messageTypeState) || (message.Seq <= hello.Seq) {
+		t.Fatalf("expected state message following hello message, got %+v", message.messageHeader)
+	}
+	if (message.ScreenWidth != 64) || (message.ScreenHeight != 32) || (len(message.Screen) != 64*32/8) || (message.Screen[0] != 0b10000000) {
+		t.Fatalf("unexpected screen message %+v", message)
+	}
+
+	if err := writeMaskedMessage(client, []byte(`{"version": 1, "type": "keys", "seq": 1, "keys": 4660}`)); err != nil {
+		t.Fatalf("could not write key message: %s", err)
+	}
+
+	// Key state changes are echoed back as sound and keys messages
+	_, payload, err = client.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read key state message: %s", err)
+	}
+	if err := json.Unmarshal(payload, &message); (err != nil) || (message.Keys != 0x1234) {
+		t.Fatalf("expected key state 0x1234, got %+v (%v)", message, err)
+	}
+}
+
+func TestWebFrontendCompressesScreenAfterDeflateHello(t *testing.T) {
+	for _, compression := range []bool{true, false} {
+		frontend := NewWebFrontend("127.0.0.1:0", compression)
+		peripherals := NewPeripheralsWithFrontend(frontend)
+		peripherals.state.screen.XorPixel(0, 0, 1)
+
+		if err := frontend.Start(&peripherals); err != nil {
+			t.Fatalf("could not start web frontend: %s", err)
+		}
+
+		client := dialWebSocket(t, frontend.Address())
+		for i := 0; i < 2; i++ { // The hello and screen messages of the interpreter
+			if _, _, err := client.ReadMessage(); err != nil {
+				t.Fatalf("could not read welcome message: %s", err)
+			}
+		}
+
+		if err := writeMaskedMessage(client, []byte(`{"version": 1, "type": "hello", "seq": 1, "encodings": ["full", "deflate"]}`)); err != nil {
+			t.Fatalf("could not write hello message: %s", err)
+		}
+
+		message := peripheralStateMessage{}
+		for message.Type != messageTypeState {
+			_, payload, err := client.ReadMessage()
+			if err != nil {
+				t.Fatalf("could not read state message: %s", err)
+			}
+			if err := json.Unmarshal(payload, &message); err != nil {
+				t.Fatalf("could not unmarshal state message: %s", err)
+			}
+		}
+
+		screen := message.Screen
+		if compression {
+			if message.Compression != screenCompressionDeflate {
+				t.Fatalf("expected deflate compressed screen, got compression \"%s\"", message.Compression)
+			}
+			var err error
+			if screen, err = io.ReadAll(flate.NewReader(bytes.NewReader(message.Screen))); err != nil {
+				t.Fatalf("could not inflate screen: %s", err)
+			}
+		} else if message.Compression != "" {
+			t.Fatalf("expected uncompressed screen with compression disabled, got compression \"%s\"", message.Compression)
+		}
+		if (len(screen) != 64*32/8) || (screen[0] != 0b10000000) {
+			t.Errorf("unexpected screen % X", screen)
+		}
+
+		client.Close()
+		frontend.Close()
+	}
+}
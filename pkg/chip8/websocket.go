@@ -0,0 +1,237 @@
+package chip8
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Minimal WebSocket (RFC 6455) server side implementation, enough for the browser frontend:
+// text and binary messages (fragmented or not), ping/pong and close.
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+const webSocketMaxMessageSize = 64 * 1024
+
+const (
+	webSocketOpcodeContinuation = 0x0
+	webSocketOpcodeText         = 0x1
+	webSocketOpcodeBinary       = 0x2
+	webSocketOpcodeClose        = 0x8
+	webSocketOpcodePing         = 0x9
+	webSocketOpcodePong         = 0xA
+)
+
+// webSocketStatusProtocolError is the close status code of a connection closed for a protocol violation
+const webSocketStatusProtocolError = 1002
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+type webSocketConnection struct {
+	connection net.Conn
+	reader     *bufio.Reader
+	writeLock  sync.Mutex
+	server     bool // server is true for the server side of the connection, reading frames masked by the client
+}
+
+// upgradeWebSocket performs the WebSocket opening handshake on an HTTP request and takes over the connection.
+//
+// Requests from web pages of other origins than the served host are rejected, so that no web page the user visits
+// can connect to the frontend (cross-site WebSocket hijacking). Requests without origin do not come from browsers.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocketConnection, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || !strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "expected websocket upgrade request", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	}
+
+	if origin := r.Header.Get("Origin"); (origin != "") && !sameOriginHost(origin, r.Host) {
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket origin \"%s\" does not match host \"%s\"", origin, r.Host)
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing websocket key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("http connection can not be taken over")
+	}
+
+	connection, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("could not take over http connection: %w", err)
+	}
+
+	acceptHash := sha1.Sum([]byte(key + webSocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(acceptHash[:]) + "\r\n\r\n"
+
+	if _, err := connection.Write([]byte(response)); err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("could not complete websocket handshake: %w", err)
+	}
+
+	return &webSocketConnection{connection: connection, reader: readWriter.Reader, server: true}, nil
+}
+
+// sameOriginHost is true if the host (and port) of the origin URL is the host
+func sameOriginHost(origin string, host string) bool {
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(originURL.Host, host)
+}
+
+// ReadMessage reads the next text or binary message, reassembled from its fragments. Control frames are handled transparently.
+func (c *webSocketConnection) ReadMessage() (opcode byte, payload []byte, err error) {
+	fragmented := false
+	for {
+		frameOpcode, final, framePayload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOpcode {
+		case webSocketOpcodeText, webSocketOpcodeBinary:
+			if fragmented {
+				return 0, nil, c.closeWithProtocolError("new message before the final fragment of the previous message")
+			}
+			opcode, payload = frameOpcode, framePayload
+			fragmented = !final
+		case webSocketOpcodeContinuation:
+			if !fragmented {
+				return 0, nil, c.closeWithProtocolError("continuation frame without fragmented message")
+			}
+			if len(payload)+len(framePayload) > webSocketMaxMessageSize {
+				return 0, nil, c.closeWithProtocolError(fmt.Sprintf("websocket message exceeds limit of %d bytes", webSocketMaxMessageSize))
+			}
+			payload = append(payload, framePayload...)
+			fragmented = !final
+		case webSocketOpcodePing:
+			if err := c.writeFrame(webSocketOpcodePong, framePayload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case webSocketOpcodeClose:
+			c.writeFrame(webSocketOpcodeClose, nil)
+			return 0, nil, errWebSocketClosed
+		case webSocketOpcodePong:
+			continue
+		default:
+			return 0, nil, c.closeWithProtocolError(fmt.Sprintf("unknown opcode 0x%X", frameOpcode))
+		}
+
+		if !fragmented {
+			return opcode, payload, nil
+		}
+	}
+}
+
+// closeWithProtocolError closes the connection for a protocol violation, returning the violation as error
+func (c *webSocketConnection) closeWithProtocolError(violation string) error {
+	status := binary.BigEndian.AppendUint16(nil, webSocketStatusProtocolError)
+	c.writeFrame(webSocketOpcodeClose, status)
+	c.connection.Close()
+	return fmt.Errorf("websocket protocol error: %s", violation)
+}
+
+// readFrame reads a frame, and whether it is the final fragment of its message
+func (c *webSocketConnection) readFrame() (opcode byte, final bool, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, false, nil, err
+	}
+
+	final = (header[0] & 0x80) != 0
+	opcode = header[0] & 0x0F
+	masked := (header[1] & 0x80) != 0
+	length := uint64(header[1] & 0x7F)
+
+	if c.server && !masked {
+		return 0, false, nil, c.closeWithProtocolError("unmasked client frame")
+	}
+
+	switch length {
+	case 126:
+		extendedLength := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extendedLength); err != nil {
+			return 0, false, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extendedLength))
+	case 127:
+		extendedLength := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extendedLength); err != nil {
+			return 0, false, nil, err
+		}
+		length = binary.BigEndian.Uint64(extendedLength)
+	}
+
+	if length > webSocketMaxMessageSize {
+		return 0, false, nil, fmt.Errorf("websocket message of %d bytes exceeds limit of %d bytes", length, webSocketMaxMessageSize)
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask); err != nil {
+			return 0, false, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, false, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return opcode, final, payload, nil
+}
+
+// WriteMessage writes a complete (unfragmented) message.
+func (c *webSocketConnection) WriteMessage(opcode byte, payload []byte) error {
+	return c.writeFrame(opcode, payload)
+}
+
+func (c *webSocketConnection) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	frame := []byte{0x80 | opcode} // Final frame of message
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	_, err := c.connection.Write(frame)
+	return err
+}
+
+func (c *webSocketConnection) Close() error {
+	return c.connection.Close()
+}
@@ -0,0 +1,144 @@
+package chip8
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webSocketFrame is a frame as written by a client, masked or not.
+func webSocketFrame(opcode byte, final bool, payload string, masked bool) []byte {
+	frame := []byte{opcode, byte(len(payload))}
+	if final {
+		frame[0] |= 0x80
+	}
+	if !masked {
+		return append(frame, payload...)
+	}
+
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame[1] |= 0x80
+	frame = append(frame, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	return frame
+}
+
+// readFromClient reads a message on the server side of a connection the client writes the frames to,
+// returning the frames written back by the server.
+func readFromClient(t *testing.T, frames ...[]byte) (opcode byte, payload []byte, err error, written []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	defer listener.Close()
+	clientSide, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect: %s", err)
+	}
+	serverSide, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("could not accept: %s", err)
+	}
+	serverSide.SetDeadline(time.Now().Add(5 * time.Second))
+	clientSide.SetDeadline(time.Now().Add(5 * time.Second))
+
+	responses := make(chan []byte)
+	go func() {
+		for _, frame := range frames {
+			clientSide.Write(frame)
+		}
+		response, _ := io.ReadAll(clientSide)
+		responses <- response
+	}()
+
+	server := &webSocketConnection{connection: serverSide, reader: bufio.NewReader(serverSide), server: true}
+	opcode, payload, err = server.ReadMessage()
+	server.Close()
+	return opcode, payload, err, <-responses
+}
+
+func TestWebSocketReassemblesFragmentedMessages(t *testing.T) {
+	opcode, payload, err, written := readFromClient(t,
+		webSocketFrame(webSocketOpcodeText, false, "ab", true),
+		webSocketFrame(webSocketOpcodePing, true, "p", true), // Control frames may come between fragments
+		webSocketFrame(webSocketOpcodeContinuation, false, "cd", true),
+		webSocketFrame(webSocketOpcodeContinuation, true, "ef", true),
+	)
+
+	if (err != nil) || (opcode != webSocketOpcodeText) || (string(payload) != "abcdef") {
+		t.Fatalf("expected text message \"abcdef\", got opcode %d \"%s\" (%v)", opcode, payload, err)
+	}
+	if !bytes.Equal(written, []byte{0x80 | webSocketOpcodePong, 1, 'p'}) {
+		t.Errorf("expected pong, got % X", written)
+	}
+}
+
+func TestWebSocketClosesOnProtocolErrors(t *testing.T) {
+	protocolErrorClose := []byte{0x80 | webSocketOpcodeClose, 2, 0x03, 0xEA} // Status 1002
+
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"unmasked frame", [][]byte{webSocketFrame(webSocketOpcodeText, true, "keys", false)}},
+		{"continuation without message", [][]byte{webSocketFrame(webSocketOpcodeContinuation, true, "ab", true)}},
+		{"new message before final fragment", [][]byte{
+			webSocketFrame(webSocketOpcodeText, false, "ab", true),
+			webSocketFrame(webSocketOpcodeText, true, "cd", true),
+		}},
+		{"unknown opcode", [][]byte{webSocketFrame(0x3, true, "", true)}},
+	}
+
+	for _, test := range tests {
+		_, _, err, written := readFromClient(t, test.frames...)
+		if err == nil {
+			t.Errorf("%s: expected protocol error", test.name)
+		}
+		if !bytes.Equal(written, protocolErrorClose) {
+			t.Errorf("%s: expected close frame with status 1002, got % X", test.name, written)
+		}
+	}
+}
+
+func TestWebSocketRejectsOtherOrigins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client, err := upgradeWebSocket(w, r); err == nil {
+			client.Close()
+		}
+	}))
+	defer server.Close()
+
+	host := server.Listener.Addr().String()
+	for origin, expectedStatus := range map[string]int{
+		"":                           http.StatusSwitchingProtocols, // Not a browser
+		"http://" + host:             http.StatusSwitchingProtocols,
+		"http://evil.example":        http.StatusForbidden,
+		"http://" + host + ".evil":   http.StatusForbidden,
+		"http://localhost:1/" + host: http.StatusForbidden,
+	} {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+		request.Header.Set("Upgrade", "websocket")
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		request.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("origin \"%s\": request failed: %s", origin, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expectedStatus {
+			t.Errorf("origin \"%s\": expected status %d, got %d", origin, expectedStatus, response.StatusCode)
+		}
+	}
+}
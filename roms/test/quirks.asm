@@ -0,0 +1,75 @@
+; Quirks test of the sprite drawing (DXYN) of the interpreter.
+;
+; Every test leaves its result in a register, stored at "results" (V0 to V4) when all tests ran:
+;   V0  clipping:       1 if a sprite beyond the right and bottom edges wraps around to the top left corner, 0 if clipped
+;   V1  collision rows: VF after drawing a 4 rows sprite over itself, 4 if collisions are counted by row, else 1
+;   V2  bottom clip:    VF after drawing a 4 rows sprite with 2 rows below the bottom edge on an empty screen
+;   V3  display wait:   1 if a sprite draw waits for the vertical blank (ending the frame), 0 if not
+;   V4  start position: 1 if a sprite drawn at (69, 35) is drawn at (5, 3), the start position wrapping around
+;
+; The results are probed with a 1 pixel sprite, drawn twice to restore the screen.
+; Run with enough instructions per frame (like 1000) for the display wait test to end within a frame.
+
+start:      CLS
+            LD   I, block
+
+; Clipping: a 4x4 block at (62, 30) covers (0, 0) only if wrapped
+            LD   V6, 62
+            LD   V7, 30
+            DRW  V6, V7, 4
+            LD   I, pixel
+            LD   V6, 0
+            LD   V7, 0
+            DRW  V6, V7, 1
+            LD   V0, VF
+            DRW  V6, V7, 1
+
+; Collision rows: a 4 rows block drawn over itself
+            CLS
+            LD   I, block
+            LD   V6, 10
+            LD   V7, 10
+            DRW  V6, V7, 4
+            DRW  V6, V7, 4
+            LD   V1, VF
+
+; Bottom clip: a 4 rows block at row 30 on an empty screen
+            CLS
+            LD   V6, 20
+            LD   V7, 30
+            DRW  V6, V7, 4
+            LD   V2, VF
+
+; Display wait: the delay timer set right after a vertical blank ends at the draw if the draw waits for the next one
+            CLS
+            LD   V5, 1
+            LD   DT, V5
+sync:       LD   V5, DT
+            SE   V5, 0
+            JP   sync
+            LD   V5, 1
+            LD   DT, V5
+            DRW  V6, V7, 4
+            LD   V5, DT
+            LD   V3, 1
+            SE   V5, 0
+            LD   V3, 0
+
+; Start position: a pixel drawn at (69, 35) is at (5, 3)
+            CLS
+            LD   I, pixel
+            LD   V6, 69
+            LD   V7, 35
+            DRW  V6, V7, 1
+            LD   V6, 5
+            LD   V7, 3
+            DRW  V6, V7, 1
+            LD   V4, VF
+
+            LD   I, results
+            LD   [I], V4
+end:        JP   end
+
+block:      DB   0xF0, 0xF0, 0xF0, 0xF0
+pixel:      DB   0x80
+results:    DB   0, 0, 0, 0, 0